@@ -0,0 +1,310 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rulego/rulego/api/types/metrics"
+)
+
+// Overflow policies of Bulkhead, used when all workers are busy and the queue is full.
+const (
+	// OverflowPolicyReject rejects the task, Submit returns ErrNoIdleWorkers.
+	OverflowPolicyReject = "reject"
+	// OverflowPolicyCallerRuns runs the task in the caller goroutine.
+	OverflowPolicyCallerRuns = "callerRuns"
+	// OverflowPolicyBlock blocks the caller until the task can be queued.
+	OverflowPolicyBlock = "block"
+)
+
+// ErrBulkheadReleased is returned when a task is submitted to a released Bulkhead.
+var ErrBulkheadReleased = errors.New("bulkhead is released")
+
+// DefaultBulkheadMaxWorkers is the default maximum number of workers of Bulkhead.
+const DefaultBulkheadMaxWorkers = 256
+
+// blockCheckInterval is the interval used to retry starting a worker when the caller is blocked.
+var blockCheckInterval = time.Millisecond
+
+// BulkheadConfig is the configuration of Bulkhead.
+type BulkheadConfig struct {
+	// MaxWorkers is the maximum number of concurrent workers, default DefaultBulkheadMaxWorkers.
+	MaxWorkers int
+	// QueueSize is the number of tasks that can wait for an idle worker.
+	QueueSize int
+	// OverflowPolicy is the policy used when all workers are busy and the queue is full:
+	// reject (default), callerRuns or block.
+	OverflowPolicy string
+}
+
+// Validate checks the overflow policy.
+func (c BulkheadConfig) Validate() error {
+	switch c.OverflowPolicy {
+	case "", OverflowPolicyReject, OverflowPolicyCallerRuns, OverflowPolicyBlock:
+		return nil
+	default:
+		return fmt.Errorf("unknown overflow policy: %s", c.OverflowPolicy)
+	}
+}
+
+// Bulkhead is a bounded worker pool with a waiting queue, used to isolate the load of a rule chain from others.
+// Workers are started on demand up to MaxWorkers and exit when there are no queued tasks,
+// so an idle Bulkhead holds no goroutines.
+type Bulkhead struct {
+	Config BulkheadConfig
+	queue  chan func()
+	// backlog holds the tasks deferred by SubmitOrDefer when the queue is full, they are served by the workers
+	// before the queued tasks.
+	backlog     []func()
+	backlogLock sync.Mutex
+	// released is 1 after Release is called.
+	released int32
+	// workers is the number of running worker goroutines.
+	workers int64
+	// active is the number of tasks being executed, including the tasks run by callers.
+	active    int64
+	completed int64
+	rejected  int64
+}
+
+// NewBulkhead creates a Bulkhead with the given configuration.
+func NewBulkhead(config BulkheadConfig) *Bulkhead {
+	if config.MaxWorkers <= 0 {
+		config.MaxWorkers = DefaultBulkheadMaxWorkers
+	}
+	if config.QueueSize < 0 {
+		config.QueueSize = 0
+	}
+	if config.OverflowPolicy == "" {
+		config.OverflowPolicy = OverflowPolicyReject
+	}
+	return &Bulkhead{
+		Config: config,
+		queue:  make(chan func(), config.QueueSize),
+	}
+}
+
+// Submit submits a task. If all workers are busy, the task is queued;
+// if the queue is also full, the task is handled by the overflow policy.
+func (b *Bulkhead) Submit(fn func()) error {
+	if b.isReleased() {
+		return ErrBulkheadReleased
+	}
+	if b.tryStartWorker(fn) {
+		return nil
+	}
+	select {
+	case b.queue <- fn:
+		b.tryStartWorker(nil)
+		return nil
+	default:
+	}
+	switch b.Config.OverflowPolicy {
+	case OverflowPolicyCallerRuns:
+		b.run(fn)
+		return nil
+	case OverflowPolicyBlock:
+		for {
+			if b.isReleased() {
+				return ErrBulkheadReleased
+			}
+			if b.tryStartWorker(fn) {
+				return nil
+			}
+			select {
+			case b.queue <- fn:
+				b.tryStartWorker(nil)
+				return nil
+			case <-time.After(blockCheckInterval):
+			}
+		}
+	default:
+		atomic.AddInt64(&b.rejected, 1)
+		return ErrNoIdleWorkers
+	}
+}
+
+// SubmitOrDefer submits a task regardless of the reject and block policies. If all workers are busy and the queue is full,
+// the task is deferred to the backlog and served by the workers of the pool once they are idle, or runs in the caller
+// goroutine if the policy is callerRuns. It is used for the tasks that must not be rejected or blocked,
+// e.g. the tasks of the messages already admitted by Acquire, which would otherwise be lost or wait for themselves.
+func (b *Bulkhead) SubmitOrDefer(fn func()) error {
+	if b.isReleased() {
+		return ErrBulkheadReleased
+	}
+	if b.tryStartWorker(fn) {
+		return nil
+	}
+	select {
+	case b.queue <- fn:
+		b.tryStartWorker(nil)
+		return nil
+	default:
+	}
+	if b.Config.OverflowPolicy == OverflowPolicyCallerRuns {
+		b.run(fn)
+		return nil
+	}
+	b.backlogLock.Lock()
+	b.backlog = append(b.backlog, fn)
+	b.backlogLock.Unlock()
+	// A worker may have exited in the meantime.
+	b.tryStartWorker(nil)
+	return nil
+}
+
+// Acquire applies the overflow policy before new input enters the pool.
+// It returns ErrNoIdleWorkers if the pool is saturated and the policy is reject,
+// and blocks until the pool is not saturated if the policy is block.
+func (b *Bulkhead) Acquire() error {
+	if b.isReleased() {
+		return ErrBulkheadReleased
+	}
+	switch b.Config.OverflowPolicy {
+	case OverflowPolicyReject:
+		if b.Saturated() {
+			atomic.AddInt64(&b.rejected, 1)
+			return ErrNoIdleWorkers
+		}
+	case OverflowPolicyBlock:
+		for b.Saturated() {
+			if b.isReleased() {
+				return ErrBulkheadReleased
+			}
+			time.Sleep(blockCheckInterval)
+		}
+	}
+	return nil
+}
+
+// Release stops the pool: the tasks that are waiting in the queue or the backlog are discarded,
+// the workers exit after their running tasks complete, and subsequent submissions return ErrBulkheadReleased.
+func (b *Bulkhead) Release() {
+	if !atomic.CompareAndSwapInt32(&b.released, 0, 1) {
+		return
+	}
+	for len(b.queue) > 0 {
+		select {
+		case <-b.queue:
+		default:
+		}
+	}
+	b.backlogLock.Lock()
+	b.backlog = nil
+	b.backlogLock.Unlock()
+}
+
+// Saturated reports whether all workers are busy and the queue is full, or there are deferred tasks in the backlog.
+func (b *Bulkhead) Saturated() bool {
+	return atomic.LoadInt64(&b.workers) >= int64(b.Config.MaxWorkers) && len(b.queue) >= cap(b.queue) || b.backlogLen() > 0
+}
+
+// Metrics returns the utilisation of the pool.
+func (b *Bulkhead) Metrics() metrics.PoolMetrics {
+	return metrics.PoolMetrics{
+		MaxWorkers: int64(b.Config.MaxWorkers),
+		QueueSize:  int64(b.Config.QueueSize),
+		Active:     atomic.LoadInt64(&b.active),
+		Queued:     int64(len(b.queue) + b.backlogLen()),
+		Completed:  atomic.LoadInt64(&b.completed),
+		Rejected:   atomic.LoadInt64(&b.rejected),
+	}
+}
+
+// tryStartWorker starts a worker if the number of workers does not reach MaxWorkers.
+func (b *Bulkhead) tryStartWorker(fn func()) bool {
+	if !b.reserveWorker() {
+		return false
+	}
+	go b.worker(fn)
+	return true
+}
+
+func (b *Bulkhead) worker(fn func()) {
+	for {
+		if fn != nil {
+			b.run(fn)
+		}
+		if fn = b.next(); fn != nil {
+			continue
+		}
+		atomic.AddInt64(&b.workers, -1)
+		// Check the queue again after exiting, the task queued in the meantime may have no worker to serve it.
+		if b.isReleased() || (len(b.queue) == 0 && b.backlogLen() == 0) || !b.reserveWorker() {
+			return
+		}
+	}
+}
+
+// next returns the next task to run, the deferred tasks first. It returns nil if there are no tasks or the pool is released.
+func (b *Bulkhead) next() func() {
+	if b.isReleased() {
+		return nil
+	}
+	b.backlogLock.Lock()
+	if len(b.backlog) > 0 {
+		fn := b.backlog[0]
+		b.backlog[0] = nil
+		b.backlog = b.backlog[1:]
+		b.backlogLock.Unlock()
+		return fn
+	}
+	b.backlogLock.Unlock()
+	select {
+	case fn := <-b.queue:
+		return fn
+	default:
+		return nil
+	}
+}
+
+func (b *Bulkhead) backlogLen() int {
+	b.backlogLock.Lock()
+	defer b.backlogLock.Unlock()
+	return len(b.backlog)
+}
+
+func (b *Bulkhead) isReleased() bool {
+	return atomic.LoadInt32(&b.released) == 1
+}
+
+// reserveWorker increases the number of workers if it does not reach MaxWorkers.
+func (b *Bulkhead) reserveWorker() bool {
+	for {
+		n := atomic.LoadInt64(&b.workers)
+		if n >= int64(b.Config.MaxWorkers) {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.workers, n, n+1) {
+			return true
+		}
+	}
+}
+
+func (b *Bulkhead) run(fn func()) {
+	atomic.AddInt64(&b.active, 1)
+	defer func() {
+		atomic.AddInt64(&b.active, -1)
+		atomic.AddInt64(&b.completed, 1)
+	}()
+	fn()
+}
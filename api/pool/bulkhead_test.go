@@ -0,0 +1,166 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pool
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBulkhead(t *testing.T) {
+	t.Run("Submit", func(t *testing.T) {
+		b := NewBulkhead(BulkheadConfig{MaxWorkers: 4, QueueSize: 10000})
+		var n int32
+		var wg sync.WaitGroup
+		wg.Add(10000)
+		for i := 0; i < 10000; i++ {
+			if err := b.Submit(func() {
+				atomic.AddInt32(&n, 1)
+				wg.Done()
+			}); err != nil {
+				t.Fatalf("cannot submit function #%d: %v", i, err)
+			}
+		}
+		wg.Wait()
+		if n != 10000 {
+			t.Fatalf("unexpected number of served functions: %d. Expecting %d", n, 10000)
+		}
+		time.Sleep(time.Millisecond * 10)
+		m := b.Metrics()
+		if m.Completed != 10000 || m.Active != 0 || m.Queued != 0 {
+			t.Fatalf("unexpected metrics: %+v", m)
+		}
+		if w := atomic.LoadInt64(&b.workers); w != 0 {
+			t.Fatalf("workers should exit when idle, got %d", w)
+		}
+	})
+
+	t.Run("OverflowPolicy", func(t *testing.T) {
+		release := make(chan struct{})
+		blocking := func() {
+			<-release
+		}
+		//reject
+		b := NewBulkhead(BulkheadConfig{MaxWorkers: 1, QueueSize: 1})
+		_ = b.Submit(blocking)
+		_ = b.Submit(blocking)
+		time.Sleep(time.Millisecond * 20)
+		if !b.Saturated() {
+			t.Fatalf("pool should be saturated")
+		}
+		if err := b.Submit(blocking); err != ErrNoIdleWorkers {
+			t.Fatalf("expecting ErrNoIdleWorkers, got %v", err)
+		}
+		if m := b.Metrics(); m.Rejected != 1 || m.Active != 1 || m.Queued != 1 || m.Utilization() != 1 {
+			t.Fatalf("unexpected metrics: %+v", m)
+		}
+
+		if err := b.Acquire(); err != ErrNoIdleWorkers {
+			t.Fatalf("expecting ErrNoIdleWorkers, got %v", err)
+		}
+		//已经接收的任务延迟到积压队列，不在调用方协程执行
+		var deferred int32
+		if err := b.SubmitOrDefer(func() {
+			atomic.StoreInt32(&deferred, 1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if atomic.LoadInt32(&deferred) != 0 {
+			t.Fatalf("task should not run in the caller goroutine")
+		}
+		if m := b.Metrics(); m.Queued != 2 {
+			t.Fatalf("unexpected metrics: %+v", m)
+		}
+
+		//callerRuns
+		c := NewBulkhead(BulkheadConfig{MaxWorkers: 1, OverflowPolicy: OverflowPolicyCallerRuns})
+		_ = c.Submit(blocking)
+		var ran bool
+		_ = c.Submit(func() {
+			ran = true
+		})
+		if !ran {
+			t.Fatalf("task should run in the caller goroutine")
+		}
+
+		//block
+		d := NewBulkhead(BulkheadConfig{MaxWorkers: 1, OverflowPolicy: OverflowPolicyBlock})
+		_ = d.Submit(blocking)
+		var done int32
+		go func() {
+			_ = d.Submit(func() {
+				atomic.StoreInt32(&done, 1)
+			})
+		}()
+		time.Sleep(time.Millisecond * 50)
+		if atomic.LoadInt32(&done) != 0 {
+			t.Fatalf("task should wait for an idle worker")
+		}
+		close(release)
+		time.Sleep(time.Millisecond * 50)
+		if atomic.LoadInt32(&done) != 1 {
+			t.Fatalf("task should run after the worker is released")
+		}
+		if atomic.LoadInt32(&deferred) != 1 {
+			t.Fatalf("deferred task should run after the worker is released")
+		}
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		release := make(chan struct{})
+		b := NewBulkhead(BulkheadConfig{MaxWorkers: 1, QueueSize: 1})
+		var n int32
+		task := func() {
+			<-release
+			atomic.AddInt32(&n, 1)
+		}
+		_ = b.Submit(task)
+		_ = b.Submit(task)
+		_ = b.SubmitOrDefer(task)
+		time.Sleep(time.Millisecond * 20)
+		b.Release()
+		if err := b.Submit(task); err != ErrBulkheadReleased {
+			t.Fatalf("expecting ErrBulkheadReleased, got %v", err)
+		}
+		if err := b.SubmitOrDefer(task); err != ErrBulkheadReleased {
+			t.Fatalf("expecting ErrBulkheadReleased, got %v", err)
+		}
+		if err := b.Acquire(); err != ErrBulkheadReleased {
+			t.Fatalf("expecting ErrBulkheadReleased, got %v", err)
+		}
+		close(release)
+		time.Sleep(time.Millisecond * 20)
+		//只完成正在执行的任务，等待中的任务被丢弃，协程退出
+		if v := atomic.LoadInt32(&n); v != 1 {
+			t.Fatalf("unexpected number of served functions: %d. Expecting 1", v)
+		}
+		if w := atomic.LoadInt64(&b.workers); w != 0 {
+			t.Fatalf("workers should exit after release, got %d", w)
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		if err := (BulkheadConfig{OverflowPolicy: "drop"}).Validate(); err == nil {
+			t.Fatalf("expecting error for unknown overflow policy")
+		}
+		if err := (BulkheadConfig{OverflowPolicy: OverflowPolicyBlock}).Validate(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -28,6 +28,9 @@ import (
 	"time"
 )
 
+// ErrNoIdleWorkers is returned by Submit when all workers are busy and MaxWorkersCount has been reached.
+var ErrNoIdleWorkers = errors.New("no idle workers")
+
 // WorkerPool serves incoming functions using a pool of workers
 // in FILO order, i.e. the most recently stopped worker will serve the next incoming function.
 //
@@ -161,7 +164,7 @@ func (wp *WorkerPool) clean(scratch *[]*workerChan) {
 func (wp *WorkerPool) Submit(fn func()) error {
 	ch := wp.getCh()
 	if ch == nil {
-		return errors.New("no idle workers")
+		return ErrNoIdleWorkers
 	}
 	ch.ch <- fn
 	return nil
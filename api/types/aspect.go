@@ -164,6 +164,16 @@ type OnDestroyAspect interface {
 	OnDestroy(chainCtx NodeCtx)
 }
 
+// OnShutdownAspect is the interface for rule engine graceful shutdown advice
+// OnShutdownAspect 规则引擎优雅停机开始时增强点接口，用于停止接收新的输入，例如停止endpoint
+type OnShutdownAspect interface {
+	Aspect
+	// OnShutdown is the advice that executes when the rule engine starts a graceful shutdown,
+	// before waiting for in-flight messages to complete.
+	// OnShutdown 规则引擎开始优雅停机，等待正在处理的消息完成之前执行的增强点
+	OnShutdown(chainCtx NodeCtx)
+}
+
 type AspectList []Aspect
 
 // GetNodeAspects 获取节点执行类型增强点切面列表
@@ -253,3 +263,18 @@ func (list AspectList) GetEngineAspects() ([]OnChainBeforeInitAspect, []OnNodeBe
 
 	return chainBeforeInitAspects, nodeBeforeInitAspects, createdAspects, afterReloadAspects, destroyAspects
 }
+
+// GetShutdownAspects 获取规则引擎优雅停机增强点切面列表
+func (list AspectList) GetShutdownAspects() []OnShutdownAspect {
+	//从小到大排序
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Order() < list[j].Order()
+	})
+	var shutdownAspects []OnShutdownAspect
+	for _, item := range list {
+		if a, ok := item.(OnShutdownAspect); ok {
+			shutdownAspects = append(shutdownAspects, a)
+		}
+	}
+	return shutdownAspects
+}
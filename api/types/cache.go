@@ -0,0 +1,41 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import "time"
+
+// Cache is the key-value storage interface with expiration, used by components and aspects
+// that need to share state between runs, such as message deduplication and idempotency.
+// The default implementation is the in-memory LRU cache `cache.MemoryCache`,
+// which can be replaced by a distributed implementation (e.g. redis) through `types.WithCache`.
+//
+// Cache 带过期时间的键值存储接口，用于消息去重、幂等等需要在多次执行之间共享状态的组件和切面
+// 默认实现为进程内的LRU缓存 `cache.MemoryCache`，可以通过 `types.WithCache` 替换为分布式实现(例如：redis)
+type Cache interface {
+	// Set sets the value of the key. ttl<=0 means the key never expires.
+	// Set 设置缓存，ttl<=0表示不过期
+	Set(key string, value interface{}, ttl time.Duration) error
+	// SetIfAbsent sets the value only if the key does not exist or has expired, and returns whether it is set.
+	// SetIfAbsent 如果key不存在或者已经过期则设置，并返回是否设置成功
+	SetIfAbsent(key string, value interface{}, ttl time.Duration) (bool, error)
+	// Get returns the value of the key, and false if the key does not exist or has expired.
+	// Get 获取缓存，如果key不存在或者已经过期返回false
+	Get(key string) (interface{}, bool)
+	// Delete deletes the key.
+	// Delete 删除缓存
+	Delete(key string) error
+}
@@ -17,7 +17,9 @@
 package types
 
 import (
+	"reflect"
 	"sort"
+	"strconv"
 	"sync"
 )
 
@@ -102,10 +104,146 @@ type ComponentFormField struct {
 	Desc string `json:"desc"`
 	//Validate 校验规则，通过tag:validate获取
 	Validate string `json:"validate"`
-	//Fields 嵌套字段
+	//Required 是否必填，通过tag:required获取，或者validate包含required
+	Required bool `json:"required,omitempty"`
+	//Enum 可选值列表，通过tag:enum获取，多个值使用逗号分隔
+	Enum []string `json:"enum,omitempty"`
+	//Min 数值类型的最小值，字符串、数组和map类型的最小长度，通过tag:min获取
+	Min *float64 `json:"min,omitempty"`
+	//Max 数值类型的最大值，字符串、数组和map类型的最大长度，通过tag:max获取
+	Max *float64 `json:"max,omitempty"`
+	//Widget 编辑器使用的控件，例如：code/js/sql/textarea/password，通过tag:widget获取
+	Widget string `json:"widget,omitempty"`
+	//I18n 国际化key，通过tag:i18n获取，编辑器使用 ${i18n}.label 和 ${i18n}.desc 翻译Label和Desc
+	I18n string `json:"i18n,omitempty"`
+	//Fields 嵌套字段，结构体、结构体指针以及结构体数组的字段
 	Fields ComponentFormFieldList `json:"fields"`
 }
 
+// JSONSchemaVersion 导出的JSON Schema版本
+const JSONSchemaVersion = "http://json-schema.org/draft-07/schema#"
+
+// JSONSchema 把组件配置表单转换成JSON Schema，用于DSL编辑器校验节点的configuration
+func (c ComponentForm) JSONSchema() map[string]interface{} {
+	schema := c.Fields.jsonSchema()
+	schema["$schema"] = JSONSchemaVersion
+	schema["title"] = c.Type
+	if c.Label != "" {
+		schema["title"] = c.Label
+	}
+	if c.Desc != "" {
+		schema["description"] = c.Desc
+	}
+	return schema
+}
+
+// jsonSchema 字段列表转换成object类型的JSON Schema
+func (c ComponentFormFieldList) jsonSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(c))
+	var required []string
+	for _, field := range c {
+		properties[field.Name] = field.JSONSchema()
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// JSONSchema 把字段转换成JSON Schema
+func (f ComponentFormField) JSONSchema() map[string]interface{} {
+	var schema map[string]interface{}
+	jsonType := f.jsonType()
+	switch jsonType {
+	case "object":
+		if f.Type == "struct" {
+			schema = f.Fields.jsonSchema()
+		} else {
+			schema = map[string]interface{}{"type": jsonType}
+		}
+	case "array":
+		schema = map[string]interface{}{"type": jsonType}
+		if len(f.Fields) > 0 {
+			schema["items"] = f.Fields.jsonSchema()
+		}
+	case "":
+		//未知类型，例如time.Duration，不限制类型
+		schema = map[string]interface{}{}
+	default:
+		schema = map[string]interface{}{"type": jsonType}
+	}
+	if f.Label != "" {
+		schema["title"] = f.Label
+	}
+	if f.Desc != "" {
+		schema["description"] = f.Desc
+	}
+	if f.DefaultValue != nil && f.Type != "struct" && !reflect.ValueOf(f.DefaultValue).IsZero() {
+		schema["default"] = f.DefaultValue
+	}
+	if len(f.Enum) > 0 {
+		var enum []interface{}
+		for _, item := range f.Enum {
+			if jsonType == "integer" || jsonType == "number" {
+				if v, err := strconv.ParseFloat(item, 64); err == nil {
+					enum = append(enum, v)
+					continue
+				}
+			}
+			enum = append(enum, item)
+		}
+		schema["enum"] = enum
+	}
+	minKey, maxKey := "minimum", "maximum"
+	if jsonType == "string" {
+		minKey, maxKey = "minLength", "maxLength"
+	} else if jsonType == "array" {
+		minKey, maxKey = "minItems", "maxItems"
+	} else if jsonType == "object" {
+		minKey, maxKey = "minProperties", "maxProperties"
+	}
+	if f.Min != nil {
+		schema[minKey] = *f.Min
+	}
+	if f.Max != nil {
+		schema[maxKey] = *f.Max
+	}
+	if f.Widget != "" {
+		schema["x-widget"] = f.Widget
+	}
+	if f.I18n != "" {
+		schema["x-i18n"] = f.I18n
+	}
+	return schema
+}
+
+// jsonType 字段类型对应的JSON Schema类型，未知类型返回空
+func (f ComponentFormField) jsonType() string {
+	switch f.Type {
+	case "string":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return "integer"
+	case "float32", "float64":
+		return "number"
+	case "map", "struct":
+		return "object"
+	case "array":
+		return "array"
+	default:
+		return ""
+	}
+}
+
 // SafeComponentSlice 安全的组件列表切片
 type SafeComponentSlice struct {
 	//组件列表
@@ -65,6 +65,19 @@ type Config struct {
 	// EndpointEnabled indicates whether the endpoint module in the rule chain DSL is enabled.
 	EndpointEnabled bool
 	NetPool         NodePool
+	// OnShutdownPendingMsg is called for every message still pending in a node (e.g. the delay node) during graceful shutdown.
+	// It can be used to persist the pending messages. If not set, pending messages are immediately sent to the next nodes.
+	// - ruleChainId: The ID of the rule chain.
+	// - nodeId: The ID of the node holding the message.
+	OnShutdownPendingMsg func(ruleChainId string, nodeId string, msg RuleMsg)
+	// Cache is the key-value storage shared by components and aspects, such as the dedup node and the idempotency aspect.
+	// If not set, the in-memory LRU cache `cache.MemoryCache` is used.
+	// Keys may be evicted when the cache is full, so it should not hold state that must not be lost.
+	Cache Cache
+	// StateStore is the key-value storage for the durable state of stateful components, such as the alarm, fsm and geofence nodes.
+	// Unlike Cache, the state is kept until the component deletes it, so the implementation must not evict keys.
+	// If not set, an unbounded in-memory store is used, and the state is lost when the process restarts.
+	StateStore Cache
 }
 
 // RegisterUdf registers a custom function. Function names can be repeated for different script types.
@@ -38,9 +38,14 @@ const (
 const (
 	//NodeConfigurationKeyIsInitNetResource 组件配置key是否是初始化网络资源，用于节点组件初始化参数校验区分
 	NodeConfigurationKeyIsInitNetResource = "$initNetResource"
+	//ChainConfigurationKeyPool 规则链配置key，规则链专用协程池配置，用于隔离不同规则链之间的负载
+	//例如：{"pool":{"maxWorkers":10,"queueSize":100,"overflowPolicy":"reject"}}
+	ChainConfigurationKeyPool = "pool"
 )
 
 var (
 	// ErrConcurrencyLimitReached is the error returned when the concurrency limit has been reached
 	ErrConcurrencyLimitReached = errors.New("concurrency limit reached")
+	// ErrDuplicateMsg is the error returned when a duplicate message arrives while the first one is still being processed
+	ErrDuplicateMsg = errors.New("duplicate message")
 )
@@ -0,0 +1,200 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parameter types of sub rule chain contracts, the same as the template parameter types.
+const (
+	ParamTypeString = TemplateParamTypeString
+	ParamTypeNumber = TemplateParamTypeNumber
+	ParamTypeBool   = TemplateParamTypeBool
+	ParamTypeObject = TemplateParamTypeObject
+)
+
+// ChainContract declares the input parameters and output fields of a sub rule chain,
+// so that it can be invoked by `flow` and `ref` nodes like a function.
+// The inputs are mapped by the caller and passed to the sub rule chain as the only metadata of the message,
+// the outputs are the metadata of the sub rule chain end messages that are merged back into the caller message.
+// Example:
+//
+//	"ruleChain": {
+//	  "id": "alarmLevel",
+//	  "contract": {
+//	    "inputs": [{"name": "deviceId", "type": "string", "required": true}, {"name": "threshold", "type": "number", "default": 50}],
+//	    "outputs": [{"name": "level", "type": "string"}]
+//	  }
+//	}
+type ChainContract struct {
+	// Inputs are the input parameters of the rule chain.
+	Inputs []ContractParam `json:"inputs,omitempty"`
+	// Outputs are the output fields of the rule chain.
+	Outputs []ContractParam `json:"outputs,omitempty"`
+}
+
+// ContractParam defines an input parameter or an output field of a rule chain contract.
+type ContractParam struct {
+	// Name is the name of the parameter, it is also the metadata key.
+	Name string `json:"name"`
+	// Type is the type of the parameter: string, number, bool or object. Empty means any type.
+	Type string `json:"type,omitempty"`
+	// Default is the default value of an input parameter if the caller does not provide it.
+	Default interface{} `json:"default,omitempty"`
+	// Required indicates whether the caller must provide the input parameter.
+	Required bool `json:"required,omitempty"`
+	// Description is the description of the parameter.
+	Description string `json:"description,omitempty"`
+}
+
+// Validate checks that the parameter names are not empty or duplicate, and the types and default values are valid.
+func (c *ChainContract) Validate() error {
+	if err := validateContractParams(c.Inputs); err != nil {
+		return fmt.Errorf("contract inputs: %w", err)
+	}
+	if err := validateContractParams(c.Outputs); err != nil {
+		return fmt.Errorf("contract outputs: %w", err)
+	}
+	return nil
+}
+
+// ValidateMapping checks the mapping of a caller.
+// inputs: input parameter name -> caller expression; outputs: caller metadata key -> output field name.
+// All required inputs without default value must be mapped, and all mapped inputs and outputs must be declared.
+func (c *ChainContract) ValidateMapping(inputs map[string]string, outputs map[string]string) error {
+	for _, param := range c.Inputs {
+		if _, ok := inputs[param.Name]; !ok && param.Required && param.Default == nil {
+			return fmt.Errorf("contract input %s is required", param.Name)
+		}
+	}
+	for name := range inputs {
+		if _, ok := c.GetInput(name); !ok {
+			return fmt.Errorf("contract input %s is not declared", name)
+		}
+	}
+	for _, name := range outputs {
+		if _, ok := c.GetOutput(name); !ok {
+			return fmt.Errorf("contract output %s is not declared", name)
+		}
+	}
+	return nil
+}
+
+// GetInput returns the input parameter by name.
+func (c *ChainContract) GetInput(name string) (ContractParam, bool) {
+	return getContractParam(c.Inputs, name)
+}
+
+// GetOutput returns the output field by name.
+func (c *ChainContract) GetOutput(name string) (ContractParam, bool) {
+	return getContractParam(c.Outputs, name)
+}
+
+func getContractParam(params []ContractParam, name string) (ContractParam, bool) {
+	for _, param := range params {
+		if param.Name == name {
+			return param, true
+		}
+	}
+	return ContractParam{}, false
+}
+
+func validateContractParams(params []ContractParam) error {
+	var names = make(map[string]struct{})
+	for _, param := range params {
+		if param.Name == "" {
+			return errors.New("param name can not empty")
+		}
+		if _, ok := names[param.Name]; ok {
+			return fmt.Errorf("duplicate param: %s", param.Name)
+		}
+		names[param.Name] = struct{}{}
+		if !IsValidParamType(param.Type) {
+			return fmt.Errorf("param %s has unknown type: %s", param.Name, param.Type)
+		}
+		if param.Default != nil {
+			if _, err := ConvertParamValue(param.Type, param.Default); err != nil {
+				return fmt.Errorf("param %s: %w", param.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// IsValidParamType reports whether the parameter type is known. Empty means any type.
+func IsValidParamType(paramType string) bool {
+	switch paramType {
+	case "", ParamTypeString, ParamTypeNumber, ParamTypeBool, ParamTypeObject:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConvertParamValue converts the value to the parameter type. Strings are parsed for number and bool types.
+func ConvertParamValue(paramType string, v interface{}) (interface{}, error) {
+	switch paramType {
+	case "", ParamTypeObject:
+		return v, nil
+	case ParamTypeString:
+		return ParamValueToString(v), nil
+	case ParamTypeNumber:
+		switch value := v.(type) {
+		case float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return value, nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("must be a number")
+	case ParamTypeBool:
+		switch value := v.(type) {
+		case bool:
+			return value, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("must be a bool")
+	default:
+		return nil, fmt.Errorf("unknown type: %s", paramType)
+	}
+}
+
+// ParamValueToString converts the parameter value to string, maps and slices are converted to JSON.
+func ParamValueToString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(value)
+		return string(b)
+	default:
+		return fmt.Sprint(value)
+	}
+}
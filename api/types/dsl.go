@@ -39,6 +39,8 @@ type RuleChainBaseInfo struct {
 	Disabled bool `json:"disabled"`
 	// Configuration contains the configuration information of the rule chain.
 	Configuration Configuration `json:"configuration,omitempty"`
+	// Contract declares the input parameters and output fields of the rule chain when it is invoked as a sub rule chain.
+	Contract *ChainContract `json:"contract,omitempty"`
 	// AdditionalInfo is an extension field.
 	AdditionalInfo map[string]interface{} `json:"additionalInfo,omitempty"`
 }
@@ -93,6 +95,13 @@ type RuleNode struct {
 	// For example, a JS filter node might have a `jsScript` field defining the filtering logic,
 	// while a REST API call node might have a `restEndpointUrlPattern` field defining the URL to call.
 	Configuration Configuration `json:"configuration"`
+	// DisabledWhen is an expression evaluated against `global` properties, rule chain `vars` and message `metadata` before the node is executed.
+	// If it is true, the node is disabled and acts as a pass-through, for example: global.maintenance == 'true'
+	DisabledWhen string `json:"disabledWhen,omitempty"`
+	// EnabledWhen is an expression like DisabledWhen. If it is false, the node is disabled and acts as a pass-through.
+	EnabledWhen string `json:"enabledWhen,omitempty"`
+	// DisabledRelationType is the relation type used to send the message to the next nodes when the node is disabled, default is Success.
+	DisabledRelationType string `json:"disabledRelationType,omitempty"`
 }
 
 // NodeAdditionalInfo is used for visualization position information (reserved field).
@@ -16,7 +16,11 @@
 
 package types
 
-import "github.com/rulego/rulego/api/types/metrics"
+import (
+	"context"
+
+	"github.com/rulego/rulego/api/types/metrics"
+)
 
 // RuleEngineOption defines a function type for configuring a RuleEngine.
 type RuleEngineOption func(RuleEngine) error
@@ -101,3 +105,19 @@ type RuleEnginePool interface {
 	// Range iterates over all RuleEngine instances.
 	Range(f func(key, value any) bool)
 }
+
+// GracefulShutdowner is implemented by rule engines and rule engine pools that support graceful shutdown.
+// It is optional: callers should type-assert for it and fall back to Stop when it is not implemented.
+type GracefulShutdowner interface {
+	// Shutdown gracefully stops the instance. It stops the endpoints from accepting new input,
+	// waits for in-flight messages to complete or ctx to be done, drains pending messages and then destroys the nodes.
+	Shutdown(ctx context.Context) error
+}
+
+// LoadReporter is implemented by components that can report how many messages they are currently processing.
+// Endpoints use it as a backpressure signal: when the load reaches the configured high watermark,
+// they stop accepting new input until it drops below the low watermark.
+type LoadReporter interface {
+	// InFlight returns the number of messages currently being processed.
+	InFlight() int64
+}
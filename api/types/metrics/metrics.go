@@ -28,6 +28,24 @@ type EngineMetrics struct {
 	Success int64 // Number of successful chains executions
 }
 
+// PoolMetrics holds the utilisation of a worker pool, see RuleEngine.PoolMetrics.
+type PoolMetrics struct {
+	MaxWorkers int64 // Maximum number of workers
+	QueueSize  int64 // Capacity of the waiting queue
+	Active     int64 // Number of tasks being executed
+	Queued     int64 // Number of tasks waiting in the queue
+	Completed  int64 // Number of completed tasks
+	Rejected   int64 // Number of tasks rejected because the pool is full
+}
+
+// Utilization returns the ratio of active tasks to the maximum number of workers.
+func (p PoolMetrics) Utilization() float64 {
+	if p.MaxWorkers <= 0 {
+		return 0
+	}
+	return float64(p.Active) / float64(p.MaxWorkers)
+}
+
 // NewEngineMetrics creates a new instance of EngineMetrics.
 func NewEngineMetrics() *EngineMetrics {
 	m := &EngineMetrics{}
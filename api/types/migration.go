@@ -0,0 +1,50 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import "strings"
+
+// Migratable 该接口是可选的，废弃或者变更了配置的组件实现该接口，
+// 提供旧版本规则链DSL的迁移器，用于把旧的节点类型和配置升级到新版本
+type Migratable interface {
+	Migrations() []Migration
+}
+
+// Migration 组件配置迁移器
+type Migration struct {
+	// Version 引入该变更的版本，例如：v0.23.0
+	Version string
+	// Desc 变更说明，会记录到迁移报告
+	Desc string
+	// Migrate 迁移节点，可以修改节点类型、配置，也可以往规则链增加节点和修改连接
+	// 迁移必须是幂等的，如果节点不需要迁移，返回false
+	Migrate func(chain *RuleChain, node *RuleNode) (bool, error)
+}
+
+// GetConfigurationValue 不区分大小写获取节点配置的值，返回实际的key
+// 组件配置通过mapstructure解析，key不区分大小写，迁移器需要使用该方法读取配置
+func GetConfigurationValue(configuration Configuration, key string) (string, interface{}, bool) {
+	if v, ok := configuration[key]; ok {
+		return key, v, true
+	}
+	for k, v := range configuration {
+		if strings.EqualFold(k, key) {
+			return k, v, true
+		}
+	}
+	return key, nil, false
+}
@@ -98,6 +98,30 @@ func WithSecretKey(secretKey string) Option {
 	}
 }
 
+// WithCache creates an Option to set the cache used by components and aspects such as dedup and idempotency.
+func WithCache(cache Cache) Option {
+	return func(c *Config) error {
+		c.Cache = cache
+		return nil
+	}
+}
+
+// WithStateStore creates an Option to set the storage for the durable state of stateful components such as alarm, fsm and geofence.
+func WithStateStore(store Cache) Option {
+	return func(c *Config) error {
+		c.StateStore = store
+		return nil
+	}
+}
+
+// WithOnShutdownPendingMsg creates an Option to set the callback used to persist pending messages during graceful shutdown.
+func WithOnShutdownPendingMsg(onShutdownPendingMsg func(ruleChainId string, nodeId string, msg RuleMsg)) Option {
+	return func(c *Config) error {
+		c.OnShutdownPendingMsg = onShutdownPendingMsg
+		return nil
+	}
+}
+
 // WithEndpointEnabled creates an Option to enable or disable the endpoint functionality in the Config.
 func WithEndpointEnabled(endpointEnabled bool) Option {
 	return func(c *Config) error {
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+// Template parameter types.
+const (
+	TemplateParamTypeString = "string"
+	TemplateParamTypeNumber = "number"
+	TemplateParamTypeBool   = "bool"
+	TemplateParamTypeObject = "object"
+)
+
+// RuleChainTemplate defines a parameterised rule chain template.
+// The definition is a rule chain DSL, in which string values can reference the declared parameters by `${params.name}`.
+// If a string value is exactly a placeholder, it is replaced with the typed parameter value,
+// otherwise the placeholder is replaced with the string form of the value.
+// Example:
+//
+//	{
+//	  "template": {"id": "deviceTemplate", "name": "设备数据处理模板"},
+//	  "params": [
+//	    {"name": "topic", "type": "string", "required": true},
+//	    {"name": "threshold", "type": "number", "default": 50}
+//	  ],
+//	  "definition": {
+//	    "ruleChain": {"name": "设备 ${params.topic}"},
+//	    "metadata": {
+//	      "nodes": [
+//	        {"id": "s1", "type": "jsFilter", "configuration": {"jsScript": "return msg.temperature > ${params.threshold};"}},
+//	        {"id": "s2", "type": "mqttClient", "configuration": {"topic": "${params.topic}"}}
+//	      ],
+//	      "connections": [{"fromId": "s1", "toId": "s2", "type": "True"}]
+//	    }
+//	  }
+//	}
+type RuleChainTemplate struct {
+	// Template contains the basic information of the template.
+	Template RuleChainTemplateInfo `json:"template"`
+	// Params are the declared parameters of the template.
+	Params []TemplateParam `json:"params,omitempty"`
+	// Definition is the rule chain DSL with parameter placeholders.
+	Definition map[string]interface{} `json:"definition"`
+}
+
+// RuleChainTemplateInfo defines the basic information of a rule chain template.
+type RuleChainTemplateInfo struct {
+	// ID is the unique identifier of the template.
+	ID string `json:"id"`
+	// Name is the name of the template.
+	Name string `json:"name,omitempty"`
+	// AdditionalInfo is an extension field.
+	AdditionalInfo map[string]interface{} `json:"additionalInfo,omitempty"`
+}
+
+// TemplateParam defines a parameter of a rule chain template.
+type TemplateParam struct {
+	// Name is the name of the parameter, referenced by `${params.name}`.
+	Name string `json:"name"`
+	// Type is the type of the parameter: string, number, bool or object. Empty means any type.
+	Type string `json:"type,omitempty"`
+	// Default is the default value used if the parameter is not provided.
+	Default interface{} `json:"default,omitempty"`
+	// Required indicates whether the parameter must be provided.
+	Required bool `json:"required,omitempty"`
+	// Description is the description of the parameter.
+	Description string `json:"description,omitempty"`
+}
@@ -29,6 +29,27 @@ const (
 	Failure = "Failure"
 	True    = "True"
 	False   = "False"
+	// Duplicate is the relation of duplicate messages, e.g. from the dedup node.
+	Duplicate = "Duplicate"
+	// Late is the relation of messages arriving after their event time window closed, e.g. from the aggregate node.
+	Late = "Late"
+	// Timeout is the relation of messages whose processing timed out, e.g. partial results from the merge node.
+	Timeout = "Timeout"
+	// Suppressed is the relation of messages suppressed by rate limiting, e.g. from the throttle node.
+	Suppressed = "Suppressed"
+	// Created, Updated, Cleared and Unchanged are the relations of alarm lifecycle events, e.g. from the alarm node.
+	Created   = "Created"
+	Updated   = "Updated"
+	Cleared   = "Cleared"
+	Unchanged = "Unchanged"
+	// Anomaly and Normal are the relations of anomaly detection results, e.g. from the anomaly node.
+	Anomaly = "Anomaly"
+	Normal  = "Normal"
+	// Entered, Left, Inside and Outside are the relations of geofence presence events, e.g. from the geofence node.
+	Entered = "Entered"
+	Left    = "Left"
+	Inside  = "Inside"
+	Outside = "Outside"
 )
 
 // Flow direction types indicate the direction of message flow into and out of nodes.
@@ -125,6 +146,17 @@ type Node interface {
 	Destroy()
 }
 
+// DrainableNode is implemented by components that hold pending messages, such as the delay node.
+// During graceful shutdown the rule engine drains them before the nodes are destroyed, so that
+// pending messages are not lost.
+type DrainableNode interface {
+	Node
+	// Drain releases all pending messages. If persist is nil, the messages are immediately sent to the next nodes,
+	// otherwise they are handed over to persist and their processing ends in this component.
+	// After Drain, messages arriving at the component are handled the same way instead of being held.
+	Drain(persist func(msg RuleMsg))
+}
+
 // NodeCtx is the context for instantiating rule nodes.
 type NodeCtx interface {
 	Node
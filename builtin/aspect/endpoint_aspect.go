@@ -25,9 +25,10 @@ import (
 )
 
 var (
-	_ types.OnCreatedAspect = (*EndpointAspect)(nil)
-	_ types.OnReloadAspect  = (*EndpointAspect)(nil)
-	_ types.OnDestroyAspect = (*EndpointAspect)(nil)
+	_ types.OnCreatedAspect  = (*EndpointAspect)(nil)
+	_ types.OnReloadAspect   = (*EndpointAspect)(nil)
+	_ types.OnDestroyAspect  = (*EndpointAspect)(nil)
+	_ types.OnShutdownAspect = (*EndpointAspect)(nil)
 )
 
 type EndpointAspect struct {
@@ -77,6 +78,14 @@ func (aspect *EndpointAspect) OnReload(_ types.NodeCtx, ctx types.NodeCtx) error
 	}
 	return nil
 }
+
+// OnShutdown 优雅停机时，停止规则链的endpoint，不再接收新的输入
+func (aspect *EndpointAspect) OnShutdown(ctx types.NodeCtx) {
+	if aspect.ruleChainEndpoint != nil {
+		aspect.ruleChainEndpoint.Destroy()
+	}
+}
+
 func (aspect *EndpointAspect) OnDestroy(ctx types.NodeCtx) {
 	if aspect.ruleChainEndpoint != nil {
 		aspect.ruleChainEndpoint.Destroy()
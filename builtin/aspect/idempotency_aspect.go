@@ -0,0 +1,212 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aspect
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/cache"
+	"github.com/rulego/rulego/utils/str"
+)
+
+// DefaultIdempotencyWindow 默认幂等时间窗口
+const DefaultIdempotencyWindow = time.Minute
+
+var (
+	_ types.StartAspect             = (*IdempotencyAspect)(nil)
+	_ types.AroundAspect            = (*IdempotencyAspect)(nil)
+	_ types.EndAspect               = (*IdempotencyAspect)(nil)
+	_ types.OnChainBeforeInitAspect = (*IdempotencyAspect)(nil)
+)
+
+// IdempotencyAspect 规则链幂等切面
+// 时间窗口内，相同key的消息只执行一次规则链，重复消息不会执行任何节点：
+//  1. 首次执行已经结束，重复消息直接返回首次执行的结果(消息和relationType)，请求/响应模式下调用方得到与首次执行相同的响应
+//  2. 首次执行还未结束，重复消息以 types.ErrDuplicateMsg 错误通过`Duplicate`结束
+//
+// 首次执行失败(err!=nil)不会缓存结果，后续重试会重新执行规则链。
+// 执行记录保存在 Cache 中，默认使用 types.Config.Cache
+type IdempotencyAspect struct {
+	// Key 幂等key表达式，可以访问msg、metadata、id、type等变量，为空则使用消息ID(RuleMsg.Id)
+	Key string
+	// Window 幂等时间窗口，默认 DefaultIdempotencyWindow
+	Window time.Duration
+	// Cache 执行记录存储，为空使用 types.Config.Cache
+	Cache     types.Cache
+	program   *vm.Program
+	err       error
+	cacheOnce sync.Once
+}
+
+// IdempotencyResult 幂等执行记录
+type IdempotencyResult struct {
+	// Done 首次执行是否已经结束
+	Done bool
+	// Msg 首次执行结束时的消息
+	Msg types.RuleMsg
+	// RelationType 首次执行结束时的relationType
+	RelationType string
+}
+
+// idempotencyRunKey 当前执行记录在 context 中的key
+type idempotencyRunKey struct{}
+
+// idempotencyRun 一次规则链执行的幂等状态
+type idempotencyRun struct {
+	key   string
+	cache types.Cache
+	// duplicate 不为空表示重复消息
+	duplicate *IdempotencyResult
+	// handled 重复消息是否已经处理
+	handled int32
+	// recorded 是否已经记录执行结果
+	recorded int32
+}
+
+// NewIdempotencyAspect 创建幂等切面，key为空使用消息ID
+func NewIdempotencyAspect(key string, window time.Duration) *IdempotencyAspect {
+	return &IdempotencyAspect{
+		Key:    key,
+		Window: window,
+	}
+}
+
+func (a *IdempotencyAspect) Order() int {
+	return 20
+}
+
+func (a *IdempotencyAspect) New() types.Aspect {
+	newAspect := &IdempotencyAspect{
+		Key:    a.Key,
+		Window: a.Window,
+		Cache:  a.Cache,
+	}
+	if newAspect.Window <= 0 {
+		newAspect.Window = DefaultIdempotencyWindow
+	}
+	if newAspect.Key != "" {
+		newAspect.program, newAspect.err = expr.Compile(newAspect.Key, expr.AllowUndefinedVariables())
+	}
+	return newAspect
+}
+
+// OnChainBeforeInit key表达式错误，规则链初始化失败
+func (a *IdempotencyAspect) OnChainBeforeInit(def *types.RuleChain) error {
+	return a.err
+}
+
+func (a *IdempotencyAspect) PointCut(ctx types.RuleContext, msg types.RuleMsg, relationType string) bool {
+	return true
+}
+
+// Start 记录首次执行，或者标记重复消息
+func (a *IdempotencyAspect) Start(ctx types.RuleContext, msg types.RuleMsg) (types.RuleMsg, error) {
+	key, err := a.getKey(ctx, msg)
+	if err != nil {
+		return msg, err
+	}
+	c := a.getCache(ctx)
+	run := &idempotencyRun{key: key, cache: c}
+	if ok, err := c.SetIfAbsent(key, &IdempotencyResult{}, a.Window); err != nil {
+		return msg, err
+	} else if !ok {
+		if v, ok := c.Get(key); ok {
+			run.duplicate, _ = v.(*IdempotencyResult)
+		}
+		if run.duplicate == nil {
+			run.duplicate = &IdempotencyResult{}
+		}
+	}
+	parent := ctx.GetContext()
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx.SetContext(context.WithValue(parent, idempotencyRunKey{}, run))
+	return msg, nil
+}
+
+// Around 重复消息不执行节点，直接结束
+func (a *IdempotencyAspect) Around(ctx types.RuleContext, msg types.RuleMsg, relationType string) (types.RuleMsg, bool) {
+	run := getIdempotencyRun(ctx)
+	if run == nil || run.duplicate == nil || !atomic.CompareAndSwapInt32(&run.handled, 0, 1) {
+		return msg, true
+	}
+	if run.duplicate.Done {
+		ctx.DoOnEnd(run.duplicate.Msg.Copy(), nil, run.duplicate.RelationType)
+	} else {
+		ctx.DoOnEnd(msg, types.ErrDuplicateMsg, types.Duplicate)
+	}
+	return msg, false
+}
+
+// End 记录首次执行的结果，执行失败则删除记录，允许重试
+func (a *IdempotencyAspect) End(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) types.RuleMsg {
+	run := getIdempotencyRun(ctx)
+	if run == nil || run.duplicate != nil || !atomic.CompareAndSwapInt32(&run.recorded, 0, 1) {
+		return msg
+	}
+	if err != nil {
+		_ = run.cache.Delete(run.key)
+	} else {
+		_ = run.cache.Set(run.key, &IdempotencyResult{Done: true, Msg: msg.Copy(), RelationType: relationType}, a.Window)
+	}
+	return msg
+}
+
+func (a *IdempotencyAspect) getKey(ctx types.RuleContext, msg types.RuleMsg) (string, error) {
+	var key = msg.Id
+	if a.program != nil {
+		out, err := vm.Run(a.program, base.NodeUtils.GetEvn(ctx, msg))
+		if err != nil {
+			return "", err
+		}
+		key = str.ToString(out)
+	}
+	var chainId string
+	if ctx.RuleChain() != nil {
+		chainId = ctx.RuleChain().GetNodeId().Id
+	}
+	return "idempotency:" + chainId + ":" + key, nil
+}
+
+func (a *IdempotencyAspect) getCache(ctx types.RuleContext) types.Cache {
+	a.cacheOnce.Do(func() {
+		if a.Cache == nil {
+			if c := ctx.Config().Cache; c != nil {
+				a.Cache = c
+			} else {
+				a.Cache = cache.NewMemoryCache(cache.DefaultMaxSize)
+			}
+		}
+	})
+	return a.Cache
+}
+
+func getIdempotencyRun(ctx types.RuleContext) *idempotencyRun {
+	if ctx.GetContext() == nil {
+		return nil
+	}
+	run, _ := ctx.GetContext().Value(idempotencyRunKey{}).(*idempotencyRun)
+	return run
+}
@@ -0,0 +1,384 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cluster routes messages across several rule engine instances.
+// The partition key of each message is consistently hashed to an owner member,
+// so that the related messages are always processed by the same instance
+// and stateful nodes, such as `delay`, `join` and `dedup`, work behind a load balancer.
+//
+//	c, err := cluster.New(cluster.Config{
+//		NodeId:            "node1",
+//		PartitionKey:      "metadata.deviceId",
+//		Transport:         cluster.NewHttpTransport("127.0.0.1:9090", token),
+//		Members:           []cluster.Member{{Id: "node1", Addr: "127.0.0.1:9090"}, {Id: "node2", Addr: "127.0.0.1:9091"}},
+//		HeartbeatInterval: time.Second,
+//	})
+//	err = c.Start()
+//	//endpoints route the messages through the cluster
+//	router := impl.NewRouter(endpoint.RouterOptions.WithRuleGo(c.Pool())).From("/api/v1/msg").To("chain:rule01").End()
+//	//or send the messages directly
+//	err = c.OnMsg("rule01", msg)
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/engine"
+	"github.com/rulego/rulego/utils/str"
+)
+
+// Member is an engine instance of the cluster.
+type Member struct {
+	// Id is the unique id of the member.
+	Id string `json:"id"`
+	// Addr is the transport address of the member.
+	Addr string `json:"addr"`
+}
+
+// Config is the configuration of the cluster.
+type Config struct {
+	// NodeId is the member id of this instance.
+	NodeId string
+	// Members are the cluster members, it can include this instance. It can be changed by Cluster.SetMembers.
+	Members []Member
+	// HeartbeatInterval is the interval of checking whether the members are alive. The members that do not respond
+	// are removed from the hash ring until they respond again. <=0 means all members are considered alive.
+	HeartbeatInterval time.Duration
+	// PartitionKey is an expression evaluated against the message, for example: metadata.deviceId
+	// The messages with the same key are processed by the same member.
+	// If it is empty, or evaluated to empty, the message is processed by this instance.
+	PartitionKey string
+	// Replicas is the number of virtual nodes of each member on the hash ring. Default: DefaultReplicas.
+	Replicas int
+	// Transport forwards messages between members.
+	Transport Transport
+	// Pool is the rule engine instance pool that processes the messages. Default: engine.DefaultPool.
+	Pool types.RuleEnginePool
+	// OnMembersChanged is called with the alive members after the membership is changed. Optional.
+	OnMembersChanged func(members []Member)
+}
+
+// Cluster routes messages to the owner member of their partition key.
+type Cluster struct {
+	config  Config
+	program *vm.Program
+	ring    *HashRing
+	// members are the configured members
+	members []Member
+	// alive are the members on the hash ring
+	alive []Member
+	lock  sync.RWMutex
+	// stopCh stops the heartbeat
+	stopCh chan struct{}
+}
+
+// New creates a cluster instance.
+func New(config Config) (*Cluster, error) {
+	if config.NodeId == "" {
+		return nil, errors.New("nodeId can not empty")
+	}
+	if config.Transport == nil {
+		return nil, errors.New("transport can not nil")
+	}
+	if config.Pool == nil {
+		config.Pool = engine.DefaultPool
+	}
+	c := &Cluster{
+		config: config,
+		ring:   NewHashRing(config.Replicas),
+	}
+	if config.PartitionKey != "" {
+		program, err := expr.Compile(config.PartitionKey, expr.AllowUndefinedVariables())
+		if err != nil {
+			return nil, fmt.Errorf("partitionKey: %w", err)
+		}
+		c.program = program
+	}
+	c.setMembers(config.Members)
+	return c, nil
+}
+
+// Start starts the transport to receive the messages forwarded by other members, and the heartbeat if it is enabled.
+func (c *Cluster) Start() error {
+	if err := c.config.Transport.Start(c.handle); err != nil {
+		return err
+	}
+	if c.config.HeartbeatInterval > 0 {
+		c.stopCh = make(chan struct{})
+		go c.heartbeat(c.stopCh)
+	}
+	return nil
+}
+
+// Stop stops the heartbeat and the transport.
+func (c *Cluster) Stop() error {
+	if c.stopCh != nil {
+		close(c.stopCh)
+		c.stopCh = nil
+	}
+	return c.config.Transport.Stop()
+}
+
+// Pool returns a rule engine pool that routes the messages through the cluster.
+// It can be used as the rule engine pool of endpoint routers, see RoutedPool.
+func (c *Cluster) Pool() *RoutedPool {
+	return &RoutedPool{RuleEnginePool: c.config.Pool, cluster: c}
+}
+
+// Addr returns the transport address of this instance.
+func (c *Cluster) Addr() string {
+	return c.config.Transport.Addr()
+}
+
+// Self returns the member of this instance.
+func (c *Cluster) Self() Member {
+	return Member{Id: c.config.NodeId, Addr: c.Addr()}
+}
+
+// Members returns the configured cluster members.
+func (c *Cluster) Members() []Member {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return append([]Member(nil), c.members...)
+}
+
+// AliveMembers returns the members on the hash ring, i.e. the configured members that respond to the heartbeat.
+func (c *Cluster) AliveMembers() []Member {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return append([]Member(nil), c.alive...)
+}
+
+// SetMembers replaces the cluster members and rebalances the partitions.
+// The keys owned by the removed members are moved to the remaining members,
+// the state of stateful nodes on the previous owner is not migrated.
+// The members are considered alive until the next heartbeat.
+func (c *Cluster) SetMembers(members []Member) {
+	c.setMembers(members)
+	c.onMembersChanged()
+}
+
+// AddMember adds or updates a member and rebalances the partitions.
+func (c *Cluster) AddMember(member Member) {
+	members := c.Members()
+	for i, item := range members {
+		if item.Id == member.Id {
+			members[i] = member
+			c.SetMembers(members)
+			return
+		}
+	}
+	c.SetMembers(append(members, member))
+}
+
+// RemoveMember removes a member and rebalances the partitions.
+func (c *Cluster) RemoveMember(memberId string) {
+	var members []Member
+	for _, item := range c.Members() {
+		if item.Id != memberId {
+			members = append(members, item)
+		}
+	}
+	c.SetMembers(members)
+}
+
+func (c *Cluster) setMembers(members []Member) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.members = append([]Member(nil), members...)
+	c.alive = append([]Member(nil), members...)
+	c.ring.Set(c.alive)
+}
+
+// setAlive updates the members on the hash ring, returns whether they are changed.
+func (c *Cluster) setAlive(alive []Member) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if equalMembers(c.alive, alive) {
+		return false
+	}
+	c.alive = alive
+	c.ring.Set(c.alive)
+	return true
+}
+
+func (c *Cluster) onMembersChanged() {
+	if c.config.OnMembersChanged != nil {
+		c.config.OnMembersChanged(c.AliveMembers())
+	}
+}
+
+// heartbeat periodically pings the members, and rebalances the partitions if some of them stop or resume responding.
+func (c *Cluster) heartbeat(stopCh chan struct{}) {
+	ticker := time.NewTicker(c.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if c.setAlive(c.ping()) {
+				c.onMembersChanged()
+			}
+		}
+	}
+}
+
+// ping returns the configured members that are alive, this instance is always alive.
+func (c *Cluster) ping() []Member {
+	members := c.Members()
+	var alive = make([]bool, len(members))
+	var wg sync.WaitGroup
+	for i, member := range members {
+		if member.Id == c.config.NodeId {
+			alive[i] = true
+			continue
+		}
+		wg.Add(1)
+		go func(i int, member Member) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), c.config.HeartbeatInterval)
+			defer cancel()
+			alive[i] = c.config.Transport.Ping(ctx, member) == nil
+		}(i, member)
+	}
+	wg.Wait()
+	var result []Member
+	for i, member := range members {
+		if alive[i] {
+			result = append(result, member)
+		}
+	}
+	return result
+}
+
+func equalMembers(a, b []Member) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Owner returns the member that owns the partition key.
+// If the key is empty or there is no member, this instance is the owner.
+func (c *Cluster) Owner(key string) Member {
+	if key != "" {
+		c.lock.RLock()
+		member, ok := c.ring.Get(key)
+		c.lock.RUnlock()
+		if ok {
+			return member
+		}
+	}
+	return c.Self()
+}
+
+// PartitionKey evaluates the partition key of the message.
+func (c *Cluster) PartitionKey(msg types.RuleMsg) (string, error) {
+	if c.program == nil {
+		return "", nil
+	}
+	out, err := vm.Run(c.program, base.NodeUtils.GetEvn(nil, msg))
+	if err != nil {
+		return "", err
+	}
+	if out == nil {
+		return "", nil
+	}
+	return str.ToString(out), nil
+}
+
+// OnMsg processes the message by the rule chain on the member that owns its partition key.
+// If this instance is the owner, the message is processed locally, otherwise it is forwarded by the transport.
+func (c *Cluster) OnMsg(chainId string, msg types.RuleMsg) error {
+	owner, err := c.ownerOf(msg)
+	if err != nil {
+		return err
+	}
+	if c.isSelf(owner) {
+		return c.process(chainId, msg)
+	}
+	_, err = c.config.Transport.Send(context.Background(), owner, ForwardRequest{
+		From:    c.config.NodeId,
+		ChainId: chainId,
+		Msg:     msg,
+	})
+	return err
+}
+
+// ownerOf returns the member that owns the partition key of the message.
+func (c *Cluster) ownerOf(msg types.RuleMsg) (Member, error) {
+	key, err := c.PartitionKey(msg)
+	if err != nil {
+		return Member{}, err
+	}
+	return c.Owner(key), nil
+}
+
+func (c *Cluster) isSelf(member Member) bool {
+	return member.Id == c.config.NodeId
+}
+
+// handle processes a forwarded message locally.
+// It is not forwarded again even if the membership views of the members are different, to avoid forwarding loops.
+// If the request waits for the result, the results of all branches of the rule chain are returned.
+func (c *Cluster) handle(req ForwardRequest) (ForwardResponse, error) {
+	var resp ForwardResponse
+	if req.Msg.Metadata == nil {
+		req.Msg.Metadata = types.NewMetadata()
+	}
+	if !req.Wait {
+		return resp, c.process(req.ChainId, req.Msg)
+	}
+	e, ok := c.config.Pool.Get(req.ChainId)
+	if !ok {
+		return resp, fmt.Errorf("ruleChain id=%s not found", req.ChainId)
+	}
+	var lock sync.Mutex
+	e.OnMsgAndWait(req.Msg, types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+		result := ForwardResult{Msg: msg, RelationType: relationType}
+		if err != nil {
+			result.Err = err.Error()
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		resp.Results = append(resp.Results, result)
+	}))
+	lock.Lock()
+	defer lock.Unlock()
+	return resp, nil
+}
+
+func (c *Cluster) process(chainId string, msg types.RuleMsg) error {
+	if e, ok := c.config.Pool.Get(chainId); ok {
+		e.OnMsg(msg)
+		return nil
+	}
+	return fmt.Errorf("ruleChain id=%s not found", chainId)
+}
@@ -0,0 +1,362 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/engine"
+	"github.com/rulego/rulego/test/assert"
+)
+
+// testToken 集群成员共享的令牌
+const testToken = "testClusterToken"
+
+var clusterChain = `{
+  "ruleChain": {
+    "id": "testCluster"
+  },
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "jsTransform",
+        "debugMode": true,
+        "configuration": {
+          "jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      }
+    ],
+    "connections": []
+  }
+}`
+
+// processed 记录每个实例处理的消息设备ID
+type processed struct {
+	lock    sync.Mutex
+	devices map[string]map[string]int
+}
+
+func (p *processed) add(nodeId, deviceId string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.devices[nodeId] == nil {
+		p.devices[nodeId] = make(map[string]int)
+	}
+	p.devices[nodeId][deviceId]++
+}
+
+func (p *processed) reset() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.devices = make(map[string]map[string]int)
+}
+
+func (p *processed) count(nodeId, deviceId string) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.devices[nodeId][deviceId]
+}
+
+func (p *processed) total() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var total int
+	for _, devices := range p.devices {
+		for _, c := range devices {
+			total += c
+		}
+	}
+	return total
+}
+
+// waitTotal 等待处理的消息数量，调试回调是异步触发的
+func (p *processed) waitTotal(t *testing.T, total int) {
+	for i := 0; i < 100 && p.total() < total; i++ {
+		time.Sleep(time.Millisecond * 20)
+	}
+	assert.Equal(t, total, p.total())
+}
+
+func newTestCluster(t *testing.T, nodeId string, result *processed, heartbeatInterval ...time.Duration) *Cluster {
+	config := engine.NewConfig()
+	config.OnDebug = func(ruleChainId string, flowType string, _ string, msg types.RuleMsg, _ string, _ error) {
+		if flowType == types.Out {
+			result.add(nodeId, msg.Metadata.GetValue("deviceId"))
+		}
+	}
+	pool := engine.NewPool()
+	_, err := pool.New("testCluster", []byte(clusterChain), types.WithConfig(config))
+	assert.Nil(t, err)
+	var interval time.Duration
+	if len(heartbeatInterval) > 0 {
+		interval = heartbeatInterval[0]
+	}
+	c, err := New(Config{
+		NodeId:            nodeId,
+		PartitionKey:      "metadata.deviceId",
+		Transport:         NewHttpTransport("127.0.0.1:0", testToken),
+		Pool:              pool,
+		HeartbeatInterval: interval,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, c.Start())
+	return c
+}
+
+func TestCluster(t *testing.T) {
+	result := &processed{devices: make(map[string]map[string]int)}
+	var nodes []*Cluster
+	var members []Member
+	for _, nodeId := range []string{"node1", "node2", "node3"} {
+		c := newTestCluster(t, nodeId, result)
+		defer c.Stop()
+		nodes = append(nodes, c)
+		members = append(members, c.Self())
+	}
+	var changed []Member
+	nodes[0].config.OnMembersChanged = func(members []Member) {
+		changed = members
+	}
+	for _, c := range nodes {
+		c.SetMembers(members)
+	}
+	assert.Equal(t, 3, len(changed))
+
+	devices := []string{"d1", "d2", "d3", "d4", "d5", "d6", "d7", "d8"}
+	send := func() {
+		//每个设备的消息随机发送到不同的实例
+		for i := 0; i < 3; i++ {
+			for _, deviceId := range devices {
+				metadata := types.NewMetadata()
+				metadata.PutValue("deviceId", deviceId)
+				msg := types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, metadata, "{\"temperature\":41}")
+				assert.Nil(t, nodes[i].OnMsg("testCluster", msg))
+			}
+		}
+	}
+	send()
+	result.waitTotal(t, 24)
+	//相同设备的消息都由同一个实例处理
+	for _, deviceId := range devices {
+		owner := nodes[0].Owner(deviceId)
+		assert.Equal(t, 3, result.count(owner.Id, deviceId))
+	}
+
+	//成员变化后重新分区
+	for _, c := range nodes {
+		c.RemoveMember("node3")
+	}
+	assert.Equal(t, 2, len(nodes[0].Members()))
+	result.reset()
+	send()
+	result.waitTotal(t, 24)
+	for _, deviceId := range devices {
+		owner := nodes[0].Owner(deviceId)
+		assert.True(t, owner.Id != "node3")
+		assert.Equal(t, 3, result.count(owner.Id, deviceId))
+	}
+
+	//没有分区key，在本实例处理
+	result.reset()
+	assert.Nil(t, nodes[1].OnMsg("testCluster", types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, types.NewMetadata(), "{}")))
+	result.waitTotal(t, 1)
+	assert.Equal(t, 1, result.count("node2", ""))
+
+	//规则链不存在
+	metadata := types.NewMetadata()
+	metadata.PutValue("deviceId", "d1")
+	owner := nodes[0].Owner("d1")
+	for _, c := range nodes {
+		if c.config.NodeId != owner.Id {
+			assert.NotNil(t, c.OnMsg("notFound", types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, metadata, "{}")))
+		}
+	}
+
+	_, err := New(Config{NodeId: "node4", PartitionKey: "metadata.", Transport: NewHttpTransport("127.0.0.1:0", testToken)})
+	assert.NotNil(t, err)
+}
+
+func TestRoutedPool(t *testing.T) {
+	result := &processed{devices: make(map[string]map[string]int)}
+	var nodes []*Cluster
+	var members []Member
+	for _, nodeId := range []string{"node1", "node2"} {
+		c := newTestCluster(t, nodeId, result)
+		defer c.Stop()
+		nodes = append(nodes, c)
+		members = append(members, c.Self())
+	}
+	for _, c := range nodes {
+		c.SetMembers(members)
+	}
+	//每个实例都通过路由池处理所有设备的消息，并同步获取结果
+	for _, c := range nodes {
+		e, ok := c.Pool().Get("testCluster")
+		assert.True(t, ok)
+		for _, deviceId := range []string{"d1", "d2", "d3", "d4"} {
+			metadata := types.NewMetadata()
+			metadata.PutValue("deviceId", deviceId)
+			var endMsg types.RuleMsg
+			var endRelation string
+			var completed bool
+			e.OnMsgAndWait(types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, metadata, "{\"temperature\":41}"),
+				types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+					assert.Nil(t, err)
+					endMsg = msg
+					endRelation = relationType
+				}), types.WithOnAllNodeCompleted(func() {
+					completed = true
+				}))
+			assert.True(t, completed)
+			assert.Equal(t, types.Success, endRelation)
+			assert.Equal(t, deviceId, endMsg.Metadata.GetValue("deviceId"))
+		}
+	}
+	result.waitTotal(t, 8)
+	//相同设备的消息都由同一个实例处理
+	for _, deviceId := range []string{"d1", "d2", "d3", "d4"} {
+		owner := nodes[0].Owner(deviceId)
+		assert.Equal(t, 2, result.count(owner.Id, deviceId))
+	}
+
+	//转发失败
+	nodes[1].Stop()
+	deviceId := "d1"
+	for i := 0; nodes[0].Owner(deviceId).Id != "node2"; i++ {
+		deviceId = "d" + string(rune('a'+i))
+	}
+	e, _ := nodes[0].Pool().Get("testCluster")
+	metadata := types.NewMetadata()
+	metadata.PutValue("deviceId", deviceId)
+	var endErr error
+	var endRelation string
+	e.OnMsgAndWait(types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, metadata, "{}"),
+		types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			endErr = err
+			endRelation = relationType
+		}))
+	assert.NotNil(t, endErr)
+	assert.Equal(t, types.Failure, endRelation)
+
+	_, ok := nodes[0].Pool().Get("notFound")
+	assert.False(t, ok)
+}
+
+func TestHeartbeat(t *testing.T) {
+	result := &processed{devices: make(map[string]map[string]int)}
+	var nodes []*Cluster
+	var members []Member
+	for _, nodeId := range []string{"node1", "node2", "node3"} {
+		c := newTestCluster(t, nodeId, result, time.Millisecond*20)
+		nodes = append(nodes, c)
+		members = append(members, c.Self())
+	}
+	defer nodes[0].Stop()
+	defer nodes[1].Stop()
+	var lock sync.Mutex
+	var changed []Member
+	nodes[0].config.OnMembersChanged = func(members []Member) {
+		lock.Lock()
+		defer lock.Unlock()
+		changed = members
+	}
+	for _, c := range nodes {
+		c.SetMembers(members)
+	}
+	//停止的成员从哈希环中移除
+	assert.Nil(t, nodes[2].Stop())
+	for i := 0; i < 100 && len(nodes[0].AliveMembers()) != 2; i++ {
+		time.Sleep(time.Millisecond * 20)
+	}
+	assert.Equal(t, []Member{members[0], members[1]}, nodes[0].AliveMembers())
+	assert.Equal(t, 3, len(nodes[0].Members()))
+	lock.Lock()
+	assert.Equal(t, 2, len(changed))
+	lock.Unlock()
+	for _, deviceId := range []string{"d1", "d2", "d3", "d4", "d5", "d6", "d7", "d8"} {
+		assert.True(t, nodes[0].Owner(deviceId).Id != "node3")
+	}
+}
+
+func TestHttpTransport(t *testing.T) {
+	//没有令牌或者双向TLS，拒绝启动
+	transport := NewHttpTransport("127.0.0.1:0", "")
+	assert.Equal(t, ErrInsecureTransport, transport.Start(func(req ForwardRequest) (ForwardResponse, error) {
+		return ForwardResponse{}, nil
+	}))
+
+	var received int32
+	server := NewHttpTransport("127.0.0.1:0", testToken)
+	assert.Nil(t, server.Start(func(req ForwardRequest) (ForwardResponse, error) {
+		atomic.AddInt32(&received, 1)
+		return ForwardResponse{}, nil
+	}))
+	defer server.Stop()
+	member := Member{Id: "server", Addr: server.Addr()}
+	msg := types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, types.NewMetadata(), "{}")
+
+	//令牌错误，拒绝转发
+	_, err := NewHttpTransport("", "wrongToken").Send(context.Background(), member, ForwardRequest{ChainId: "testCluster", Msg: msg})
+	assert.NotNil(t, err)
+	assert.NotNil(t, NewHttpTransport("", "").Ping(context.Background(), member))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&received))
+
+	_, err = NewHttpTransport("", testToken).Send(context.Background(), member, ForwardRequest{ChainId: "testCluster", Msg: msg})
+	assert.Nil(t, err)
+	assert.Nil(t, NewHttpTransport("", testToken).Ping(context.Background(), member))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&received))
+}
+
+func TestHttpTransportMaxBodySize(t *testing.T) {
+	var received int32
+	server := NewHttpTransport("127.0.0.1:0", testToken)
+	server.MaxBodySize = 1024
+	assert.Nil(t, server.Start(func(req ForwardRequest) (ForwardResponse, error) {
+		atomic.AddInt32(&received, 1)
+		//返回超过客户端限制的结果
+		return ForwardResponse{Results: []ForwardResult{{Msg: req.Msg}}}, nil
+	}))
+	defer server.Stop()
+	member := Member{Id: "server", Addr: server.Addr()}
+	client := NewHttpTransport("", testToken)
+
+	//消息超过服务端限制，返回413
+	msg := types.NewMsg(0, "TEST_MSG_TYPE", types.TEXT, types.NewMetadata(), strings.Repeat("a", 2048))
+	_, err := client.Send(context.Background(), member, ForwardRequest{ChainId: "testCluster", Msg: msg})
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "413"))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&received))
+
+	//响应超过客户端限制
+	msg = types.NewMsg(0, "TEST_MSG_TYPE", types.TEXT, types.NewMetadata(), strings.Repeat("a", 512))
+	client.MaxBodySize = 256
+	_, err = client.Send(context.Background(), member, ForwardRequest{ChainId: "testCluster", Msg: msg})
+	assert.Equal(t, ErrBodyTooLarge, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&received))
+
+	client.MaxBodySize = 0
+	resp, err := client.Send(context.Background(), member, ForwardRequest{ChainId: "testCluster", Msg: msg})
+	assert.Nil(t, err)
+	assert.Equal(t, 512, len(resp.Results[0].Msg.Data))
+}
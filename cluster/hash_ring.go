@@ -0,0 +1,80 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is the default number of virtual nodes of each member on the hash ring.
+const DefaultReplicas = 100
+
+// HashRing is a consistent hash ring of the cluster members.
+// When the membership changes, only the keys owned by the added or removed members are moved.
+// It is not safe for concurrent use, Cluster protects it with a lock.
+type HashRing struct {
+	replicas int
+	hashes   []uint32
+	members  map[uint32]Member
+}
+
+// NewHashRing creates a hash ring, each member has the specified number of virtual nodes.
+func NewHashRing(replicas int) *HashRing {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	return &HashRing{
+		replicas: replicas,
+		members:  make(map[uint32]Member),
+	}
+}
+
+// Set replaces all members of the hash ring.
+func (r *HashRing) Set(members []Member) {
+	r.hashes = r.hashes[:0]
+	r.members = make(map[uint32]Member, len(members)*r.replicas)
+	for _, member := range members {
+		for i := 0; i < r.replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(member.Id + "#" + strconv.Itoa(i)))
+			if _, ok := r.members[hash]; ok {
+				continue
+			}
+			r.members[hash] = member
+			r.hashes = append(r.hashes, hash)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool {
+		return r.hashes[i] < r.hashes[j]
+	})
+}
+
+// Get returns the member that owns the key. It returns false if the hash ring is empty.
+func (r *HashRing) Get(key string) (Member, bool) {
+	if len(r.hashes) == 0 {
+		return Member{}, false
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= hash
+	})
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.members[r.hashes[i]], true
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestHashRing(t *testing.T) {
+	ring := NewHashRing(0)
+	_, ok := ring.Get("a")
+	assert.False(t, ok)
+
+	members := []Member{{Id: "node1"}, {Id: "node2"}, {Id: "node3"}}
+	ring.Set(members)
+	var owners = make(map[string]string)
+	var counts = make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := "device" + strconv.Itoa(i)
+		member, ok := ring.Get(key)
+		assert.True(t, ok)
+		owners[key] = member.Id
+		counts[member.Id]++
+		//相同key总是相同的成员
+		member, _ = ring.Get(key)
+		assert.Equal(t, owners[key], member.Id)
+	}
+	for _, m := range members {
+		assert.True(t, counts[m.Id] > 500)
+	}
+
+	//删除成员，只迁移被删除成员的key
+	ring.Set(members[:2])
+	for key, owner := range owners {
+		member, _ := ring.Get(key)
+		if owner != "node3" {
+			assert.Equal(t, owner, member.Id)
+		} else {
+			assert.True(t, member.Id != "node3")
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/engine"
+)
+
+var _ types.RuleEnginePool = (*RoutedPool)(nil)
+var _ types.LoadReporter = (*RoutedPool)(nil)
+
+// RoutedPool is a rule engine pool that routes the messages through the cluster.
+// The rule engines it returns process a message locally if this instance owns its partition key,
+// otherwise they forward the message to the owner and wait for the result, so the end callbacks of the caller,
+// such as the responses of the endpoints, are invoked with the results of the owner.
+// Only the end and completion callbacks and the context of the options are applied to the forwarded messages.
+//
+//	router := impl.NewRouter(endpoint.RouterOptions.WithRuleGo(c.Pool())).From("/api/v1/msg").To("chain:rule01").End()
+type RoutedPool struct {
+	types.RuleEnginePool
+	cluster *Cluster
+}
+
+// Get returns the rule engine that routes the messages through the cluster.
+func (p *RoutedPool) Get(id string) (types.RuleEngine, bool) {
+	e, ok := p.RuleEnginePool.Get(id)
+	if !ok {
+		return nil, false
+	}
+	return &routedEngine{RuleEngine: e, cluster: p.cluster}, true
+}
+
+// OnMsg routes the message to all rule engines in the pool.
+func (p *RoutedPool) OnMsg(msg types.RuleMsg) {
+	p.RuleEnginePool.Range(func(key, value any) bool {
+		if e, ok := value.(types.RuleEngine); ok {
+			(&routedEngine{RuleEngine: e, cluster: p.cluster}).OnMsg(msg)
+		}
+		return true
+	})
+}
+
+// InFlight returns the number of messages being processed by the local pool, used as the backpressure signal of endpoints.
+func (p *RoutedPool) InFlight() int64 {
+	if reporter, ok := p.RuleEnginePool.(types.LoadReporter); ok {
+		return reporter.InFlight()
+	}
+	return 0
+}
+
+// routedEngine routes the messages to the owner of their partition key.
+type routedEngine struct {
+	types.RuleEngine
+	cluster *Cluster
+}
+
+func (e *routedEngine) OnMsg(msg types.RuleMsg, opts ...types.RuleContextOption) {
+	e.onMsg(msg, false, opts)
+}
+
+func (e *routedEngine) OnMsgAndWait(msg types.RuleMsg, opts ...types.RuleContextOption) {
+	e.onMsg(msg, true, opts)
+}
+
+func (e *routedEngine) onMsg(msg types.RuleMsg, wait bool, opts []types.RuleContextOption) {
+	owner, err := e.cluster.ownerOf(msg)
+	if err == nil && e.cluster.isSelf(owner) {
+		if wait {
+			e.RuleEngine.OnMsgAndWait(msg, opts...)
+		} else {
+			e.RuleEngine.OnMsg(msg, opts...)
+		}
+	} else if wait {
+		e.forward(owner, msg, err, opts)
+	} else {
+		go e.forward(owner, msg, err, opts)
+	}
+}
+
+// forward sends the message to the owner, and invokes the end callbacks with the results of the owner.
+func (e *routedEngine) forward(owner Member, msg types.RuleMsg, err error, opts []types.RuleContextOption) {
+	chainCtx, _ := e.RootRuleChainCtx().(*engine.RuleChainCtx)
+	var config types.Config
+	if chainCtx != nil {
+		config = chainCtx.Config()
+	}
+	ctx := engine.NewRuleContext(context.Background(), config, chainCtx, nil, nil, nil, nil, nil)
+	for _, opt := range opts {
+		opt(ctx)
+	}
+	var resp ForwardResponse
+	if err == nil {
+		resp, err = e.cluster.config.Transport.Send(ctx.GetContext(), owner, ForwardRequest{
+			From:    e.cluster.config.NodeId,
+			ChainId: e.Id(),
+			Msg:     msg,
+			Wait:    true,
+		})
+	}
+	if onEnd := ctx.GetEndFunc(); onEnd != nil {
+		if err != nil {
+			onEnd(ctx, msg, err, types.Failure)
+		}
+		for _, result := range resp.Results {
+			var resultErr error
+			if result.Err != "" {
+				resultErr = errors.New(result.Err)
+			}
+			if result.Msg.Metadata == nil {
+				result.Msg.Metadata = types.NewMetadata()
+			}
+			onEnd(ctx, result.Msg, resultErr, result.RelationType)
+		}
+	}
+	if onAllNodeCompleted := ctx.GetOnAllNodeCompleted(); onAllNodeCompleted != nil {
+		onAllNodeCompleted()
+	}
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+)
+
+const (
+	// ForwardPath is the HTTP path that receives the forwarded messages.
+	ForwardPath = "/rulego/cluster/forward"
+	// PingPath is the HTTP path that answers the heartbeats of other members.
+	PingPath = "/rulego/cluster/ping"
+	// DefaultSendTimeout is the default timeout of forwarding a message.
+	DefaultSendTimeout = 5 * time.Second
+	// DefaultMaxBodySize is the default limit of the request and response body size in bytes.
+	DefaultMaxBodySize = 4 << 20
+	// HeaderKeyAuthorization is the HTTP header that carries the shared token.
+	HeaderKeyAuthorization = "Authorization"
+	// bearerPrefix is the prefix of the token in the Authorization header.
+	bearerPrefix = "Bearer "
+)
+
+// ErrInsecureTransport is returned when the transport is started without a token or mutual TLS,
+// because the forwarded messages would be accepted from any caller.
+var ErrInsecureTransport = errors.New("cluster transport requires a token or mutual TLS")
+
+// ErrBodyTooLarge is returned when the forwarded message or the response exceeds HttpTransport.MaxBodySize.
+var ErrBodyTooLarge = errors.New("cluster transport body too large")
+
+// ForwardRequest is a message forwarded to the member that owns its partition key.
+type ForwardRequest struct {
+	// From is the id of the member that forwards the message.
+	From string `json:"from"`
+	// ChainId is the id of the rule chain that processes the message.
+	ChainId string `json:"chainId"`
+	// Msg is the message.
+	Msg types.RuleMsg `json:"msg"`
+	// Wait indicates whether the owner responds after the rule chain completes, with the results of all its branches.
+	Wait bool `json:"wait,omitempty"`
+}
+
+// ForwardResult is the result of a branch of the rule chain that processed a forwarded message.
+type ForwardResult struct {
+	// Msg is the end message of the branch.
+	Msg types.RuleMsg `json:"msg"`
+	// RelationType is the relation type of the branch end.
+	RelationType string `json:"relationType,omitempty"`
+	// Err is the error of the branch, empty means success.
+	Err string `json:"err,omitempty"`
+}
+
+// ForwardResponse is the response of a forwarded message. Results are set only if ForwardRequest.Wait is true.
+type ForwardResponse struct {
+	Results []ForwardResult `json:"results,omitempty"`
+}
+
+// Handler processes the messages forwarded by other members.
+type Handler func(req ForwardRequest) (ForwardResponse, error)
+
+// Transport forwards messages between the cluster members. It can be replaced by other protocols, such as gRPC or MQ.
+// Implementations must authenticate the callers, only the cluster members are allowed to forward messages.
+type Transport interface {
+	// Start starts receiving the forwarded messages and processes them by the handler.
+	Start(handler Handler) error
+	// Addr returns the address that other members use to forward messages to this member.
+	Addr() string
+	// Send forwards the message to the member.
+	Send(ctx context.Context, member Member, req ForwardRequest) (ForwardResponse, error)
+	// Ping checks whether the member is alive, it is used by the heartbeat of the cluster.
+	Ping(ctx context.Context, member Member) error
+	// Stop stops receiving messages.
+	Stop() error
+}
+
+// HttpTransport is the default transport that forwards messages by HTTP POST with JSON body.
+// The callers are authenticated by a token shared by all members, sent in the Authorization header,
+// or by mutual TLS if TLSConfig requires and verifies client certificates. One of them is required.
+type HttpTransport struct {
+	// ListenAddr is the address to listen on, for example: 127.0.0.1:9090, use 127.0.0.1:0 to pick a free port.
+	ListenAddr string
+	// Token is the secret shared by all members.
+	Token string
+	// TLSConfig enables HTTPS. It is used by both the server and the client, set ClientAuth to
+	// tls.RequireAndVerifyClientCert and Certificates/RootCAs/ClientCAs for mutual TLS.
+	TLSConfig *tls.Config
+	// MaxBodySize limits the size in bytes of the forwarded messages received by the server and the responses read by the client.
+	// The server responds 413 if a message exceeds it. DefaultMaxBodySize is used if it is not positive.
+	MaxBodySize int64
+	// Client is the HTTP client used to send messages.
+	Client     *http.Client
+	clientOnce sync.Once
+	listener   net.Listener
+	server     *http.Server
+}
+
+var _ Transport = (*HttpTransport)(nil)
+
+// NewHttpTransport creates a HTTP transport listening on the address, authenticated by the shared token.
+func NewHttpTransport(listenAddr string, token string) *HttpTransport {
+	return &HttpTransport{
+		ListenAddr: listenAddr,
+		Token:      token,
+		Client:     &http.Client{Timeout: DefaultSendTimeout},
+	}
+}
+
+func (t *HttpTransport) Start(handler Handler) error {
+	if t.Token == "" && !t.mutualTLS() {
+		return ErrInsecureTransport
+	}
+	listener, err := net.Listen("tcp", t.ListenAddr)
+	if err != nil {
+		return err
+	}
+	if t.TLSConfig != nil {
+		listener = tls.NewListener(listener, t.TLSConfig)
+	}
+	t.listener = listener
+	mux := http.NewServeMux()
+	mux.HandleFunc(ForwardPath, t.authorize(func(w http.ResponseWriter, r *http.Request) {
+		body, err := t.readBody(r.Body)
+		if errors.Is(err, ErrBodyTooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req ForwardRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := handler(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		b, err := json.Marshal(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	}))
+	mux.HandleFunc(PingPath, t.authorize(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.server = &http.Server{Handler: mux}
+	go func() {
+		_ = t.server.Serve(listener)
+	}()
+	return nil
+}
+
+// authorize only accepts POST requests with the shared token.
+func (t *HttpTransport) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if t.Token != "" {
+			token := strings.TrimPrefix(r.Header.Get(HeaderKeyAuthorization), bearerPrefix)
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// Addr returns the actual listening address.
+func (t *HttpTransport) Addr() string {
+	if t.listener != nil {
+		return t.listener.Addr().String()
+	}
+	return t.ListenAddr
+}
+
+func (t *HttpTransport) Send(ctx context.Context, member Member, req ForwardRequest) (ForwardResponse, error) {
+	var resp ForwardResponse
+	body, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+	b, err := t.post(ctx, member, ForwardPath, body)
+	if err != nil {
+		return resp, err
+	}
+	if len(b) > 0 {
+		err = json.Unmarshal(b, &resp)
+	}
+	return resp, err
+}
+
+func (t *HttpTransport) Ping(ctx context.Context, member Member) error {
+	_, err := t.post(ctx, member, PingPath, nil)
+	return err
+}
+
+func (t *HttpTransport) Stop() error {
+	if t.server == nil {
+		return errors.New("transport is not started")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultSendTimeout)
+	defer cancel()
+	return t.server.Shutdown(ctx)
+}
+
+func (t *HttpTransport) post(ctx context.Context, member Member, path string, body []byte) ([]byte, error) {
+	scheme := "http://"
+	if t.TLSConfig != nil {
+		scheme = "https://"
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, scheme+member.Addr+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if t.Token != "" {
+		request.Header.Set(HeaderKeyAuthorization, bearerPrefix+t.Token)
+	}
+	resp, err := t.client().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := t.readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("forward to member id=%s error: %s %s", member.Id, resp.Status, bytes.TrimSpace(b))
+	}
+	return b, nil
+}
+
+// readBody reads the body up to MaxBodySize, returns ErrBodyTooLarge if it is larger.
+func (t *HttpTransport) readBody(r io.Reader) ([]byte, error) {
+	limit := t.MaxBodySize
+	if limit <= 0 {
+		limit = DefaultMaxBodySize
+	}
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	return b, nil
+}
+
+func (t *HttpTransport) client() *http.Client {
+	t.clientOnce.Do(func() {
+		if t.Client == nil {
+			t.Client = &http.Client{Timeout: DefaultSendTimeout}
+		}
+		if t.TLSConfig != nil && t.Client.Transport == nil {
+			t.Client.Transport = &http.Transport{TLSClientConfig: t.TLSConfig}
+		}
+	})
+	return t.Client
+}
+
+func (t *HttpTransport) mutualTLS() bool {
+	return t.TLSConfig != nil && t.TLSConfig.ClientAuth == tls.RequireAndVerifyClientCert
+}
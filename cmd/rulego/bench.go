@@ -0,0 +1,105 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rulego/rulego/test/benchmark"
+	"github.com/rulego/rulego/test/chaintest"
+	"github.com/rulego/rulego/utils/json"
+)
+
+// benchCmd 压测规则链，打印吞吐量和耗时
+func benchCmd(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	msgType := flags.String("type", "DEFAULT", "message type of the template")
+	data := flags.String("data", "{}", "message data template, supports ${seq}, ${ts} and ${rand}")
+	metadata := flags.String("metadata", "", "message metadata template, format: k1=v1,k2=v2")
+	corpus := flags.String("corpus", "", "file of the recorded messages, JSON array or JSON lines, used instead of the template")
+	rate := flags.Int("rate", 0, "messages sent per second, 0 means sending by the concurrency workers as fast as possible")
+	concurrency := flags.Int("concurrency", 1, "number of workers")
+	duration := flags.Duration("duration", 10*time.Second, "sending duration")
+	total := flags.Int64("total", 0, "maximum number of messages to send")
+	mockTypes := flags.String("mockType", "", "node types to mock as pass-through, format: restApiCall,mqttClient")
+	mockNodes := flags.String("mockNode", "", "node ids to mock as pass-through, format: s2,s3")
+	output := flags.String("output", "text", "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: rulego bench [flags] chain.json")
+	}
+	def, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	config := benchmark.Config{
+		Rate:        *rate,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Total:       *total,
+	}
+	if *corpus != "" {
+		if config.Corpus, err = benchmark.LoadCorpus(*corpus); err != nil {
+			return err
+		}
+	} else {
+		md, err := parseMetadata(*metadata)
+		if err != nil {
+			return err
+		}
+		config.Templates = []benchmark.MsgTemplate{{Type: *msgType, Data: *data, Metadata: md.Values()}}
+	}
+	for _, nodeType := range splitList(*mockTypes) {
+		config.Mocks = append(config.Mocks, chaintest.Mock{NodeType: nodeType})
+	}
+	for _, nodeId := range splitList(*mockNodes) {
+		config.Mocks = append(config.Mocks, chaintest.Mock{NodeId: nodeId})
+	}
+	report, err := benchmark.Run(def, config)
+	if err != nil {
+		return err
+	}
+	if *output == "json" {
+		b, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, string(b))
+		return err
+	}
+	_, err = io.WriteString(stdout, report.Text())
+	return err
+}
+
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
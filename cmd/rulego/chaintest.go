@@ -0,0 +1,106 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rulego/rulego/engine"
+	"github.com/rulego/rulego/test/chaintest"
+)
+
+// testCmd 执行声明式规则链测试用例(*.test.json、*.test.yaml、*.test.yml)
+func testCmd(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	coverage := flags.Bool("coverage", false, "print the coverage report of the rule chains")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	paths, err := testFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	collector := engine.NewCoverageCollector()
+	var total, failed int
+	for _, path := range paths {
+		testFile, def, err := chaintest.LoadFile(path)
+		if err != nil {
+			failed++
+			_, _ = fmt.Fprintf(stdout, "FAIL %s: %s\n", path, err)
+			continue
+		}
+		for _, testCase := range testFile.Cases {
+			total++
+			if err := runTestCase(def, testFile.Mocks, testCase, collector); err != nil {
+				failed++
+				_, _ = fmt.Fprintf(stdout, "FAIL %s/%s: %s\n", path, testCase.Name, err)
+			} else {
+				_, _ = fmt.Fprintf(stdout, "ok   %s/%s\n", path, testCase.Name)
+			}
+		}
+	}
+	if *coverage {
+		_, _ = io.WriteString(stdout, collector.Report().Text())
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d test cases failed", failed, total)
+	}
+	_, _ = fmt.Fprintf(stdout, "PASS %d test cases\n", total)
+	return nil
+}
+
+// runTestCase 执行测试用例，并收集覆盖率
+func runTestCase(def []byte, mocks []chaintest.Mock, testCase chaintest.TestCase, collector *engine.CoverageCollector) error {
+	runner, err := chaintest.New(def)
+	if err != nil {
+		return err
+	}
+	defer runner.Stop()
+	runner.Mock(mocks...).Mock(testCase.Mocks...).Coverage(collector)
+	result, err := runner.Run(testCase.Input.Msg())
+	if err != nil {
+		return err
+	}
+	return result.Check(testCase.Expect)
+}
+
+// testFiles 返回测试用例文件列表，目录则递归查找*.test.json、*.test.yaml、*.test.yml文件
+func testFiles(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{"./"}
+	}
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		files, err := chaintest.TestFiles(arg)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, files...)
+	}
+	return paths, nil
+}
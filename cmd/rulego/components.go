@@ -0,0 +1,49 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/utils/json"
+)
+
+// componentsCmd 以JSON格式列出已注册组件的表单或者配置的JSON Schema
+func componentsCmd(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("components", flag.ContinueOnError)
+	schema := flags.Bool("schema", false, "print the JSON Schema of every component configuration instead of the forms")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var v interface{} = rulego.Registry.GetComponentForms()
+	if *schema {
+		schemas := make(map[string]interface{})
+		for componentType, form := range rulego.Registry.GetComponentForms() {
+			schemas[componentType] = form.JSONSchema()
+		}
+		v = schemas
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, string(b))
+	return err
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/engine"
+)
+
+const (
+	graphFormatDot     = "dot"
+	graphFormatMermaid = "mermaid"
+)
+
+// graphCmd 把规则链导出为DOT或者Mermaid
+func graphCmd(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	format := flags.String("format", graphFormatDot, "output format: dot or mermaid")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: rulego graph [-format dot|mermaid] chain.json")
+	}
+	b, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	def, err := (&engine.JsonParser{}).DecodeRuleChain(b)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case graphFormatDot:
+		_, err = io.WriteString(stdout, toDot(def))
+	case graphFormatMermaid:
+		_, err = io.WriteString(stdout, toMermaid(def))
+	default:
+		err = fmt.Errorf("unknown format: %s", *format)
+	}
+	return err
+}
+
+// nodeLabel 节点显示名称
+func nodeLabel(node *types.RuleNode) string {
+	if node.Name != "" {
+		return node.Name + "\\n(" + node.Type + ")"
+	}
+	return node.Id + "\\n(" + node.Type + ")"
+}
+
+// toDot 导出为Graphviz DOT格式
+func toDot(def types.RuleChain) string {
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintf(&buf, "digraph %q {\n", def.RuleChain.ID)
+	_, _ = fmt.Fprintf(&buf, "  label=%q;\n", def.RuleChain.Name)
+	buf.WriteString("  node [shape=box];\n")
+	for _, node := range def.Metadata.Nodes {
+		_, _ = fmt.Fprintf(&buf, "  %q [label=\"%s\"];\n", node.Id, escapeDot(nodeLabel(node)))
+	}
+	for _, conn := range def.Metadata.Connections {
+		_, _ = fmt.Fprintf(&buf, "  %q -> %q [label=%q];\n", conn.FromId, conn.ToId, conn.Type)
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// toMermaid 导出为Mermaid流程图
+func toMermaid(def types.RuleChain) string {
+	var buf bytes.Buffer
+	buf.WriteString("flowchart TD\n")
+	for _, node := range def.Metadata.Nodes {
+		label := strings.ReplaceAll(nodeLabel(node), "\\n", "<br/>")
+		_, _ = fmt.Fprintf(&buf, "  %s[\"%s\"]\n", mermaidId(node.Id), strings.ReplaceAll(label, "\"", "#quot;"))
+	}
+	for _, conn := range def.Metadata.Connections {
+		_, _ = fmt.Fprintf(&buf, "  %s -->|%s| %s\n", mermaidId(conn.FromId), conn.Type, mermaidId(conn.ToId))
+	}
+	return buf.String()
+}
+
+// escapeDot 转义DOT标签中的双引号
+func escapeDot(s string) string {
+	return strings.ReplaceAll(s, "\"", "\\\"")
+}
+
+// mermaidId Mermaid节点ID只能包含字母、数字和下划线
+func mermaidId(id string) string {
+	var buf strings.Builder
+	for _, r := range id {
+		if r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			buf.WriteRune(r)
+		} else {
+			buf.WriteRune('_')
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command rulego runs, validates and tests rule chains without writing a main.go.
+//
+// Usage:
+//
+//	rulego run -dir ./chains                 load a folder and start its endpoints
+//	rulego validate ./chains                 lint rule chain DSL files
+//	rulego send -dir ./chains -chain rule01 -data '{"temperature":41}'
+//	                                         send a message to a rule chain and print every branch result
+//	rulego migrate -w ./chains               upgrade deprecated components and configurations
+//	rulego graph -format mermaid chain.json  export a rule chain as DOT or Mermaid
+//	rulego test ./chains                     run the declarative chain tests(*.test.json)
+//	rulego bench -total 10000 -concurrency 8 -mockType restApiCall chain.json
+//	                                         benchmark a rule chain and print latency percentiles
+//	rulego components                        list the registered component forms as JSON
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// command 子命令
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdout io.Writer) error
+}
+
+var commands = []command{
+	{name: "run", usage: "load a folder and start its endpoints", run: runCmd},
+	{name: "validate", usage: "lint rule chain DSL files", run: validateCmd},
+	{name: "send", usage: "send a message to a rule chain and print every branch result", run: sendCmd},
+	{name: "migrate", usage: "upgrade deprecated components and configurations", run: migrateCmd},
+	{name: "graph", usage: "export a rule chain as DOT or Mermaid", run: graphCmd},
+	{name: "test", usage: "run the declarative chain tests", run: testCmd},
+	{name: "bench", usage: "benchmark a rule chain and print latency percentiles", run: benchCmd},
+	{name: "components", usage: "list the registered component forms as JSON", run: componentsCmd},
+}
+
+func main() {
+	if err := execute(os.Args[1:], os.Stdout); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// execute 执行子命令
+func execute(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		printUsage(stdout)
+		return nil
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdout)
+		}
+	}
+	printUsage(stdout)
+	return fmt.Errorf("unknown command: %s", args[0])
+}
+
+func printUsage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: rulego <command> [flags]")
+	_, _ = fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.usage)
+	}
+	_, _ = fmt.Fprintln(w, "Use \"rulego <command> -h\" for more information about a command.")
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/json"
+)
+
+var testdataFolder = "../../test/chaintest/testdata"
+
+func executeCmd(args ...string) (string, error) {
+	var buf bytes.Buffer
+	err := execute(args, &buf)
+	return buf.String(), err
+}
+
+func TestCommand(t *testing.T) {
+	t.Run("Usage", func(t *testing.T) {
+		out, err := executeCmd()
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(out, "validate"))
+		_, err = executeCmd("unknown")
+		assert.Equal(t, "unknown command: unknown", err.Error())
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		out, err := executeCmd("validate", testdataFolder)
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(out, "ok   "+filepath.Join(testdataFolder, "alarm_chain.json")))
+		assert.False(t, strings.Contains(out, "alarm_chain.test.json"))
+
+		dir := t.TempDir()
+		invalid := filepath.Join(dir, "invalid.json")
+		def := `{"ruleChain":{"id":"invalid"},"metadata":{"nodes":[{"id":"s1","type":"jsFilter","configuration":{"jsScript":"return true;"}}],"connections":[{"fromId":"s1","toId":"s2","type":"True"}]}}`
+		assert.Nil(t, os.WriteFile(invalid, []byte(def), 0644))
+		out, err = executeCmd("validate", invalid)
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(out, "connection to node id=s2 not found"))
+
+		def = `{"ruleChain":{"id":"invalid"},"metadata":{"nodes":[{"id":"s1","type":"notFound"}]}}`
+		assert.Nil(t, os.WriteFile(invalid, []byte(def), 0644))
+		out, _ = executeCmd("validate", invalid)
+		assert.True(t, strings.Contains(out, "node id=s1 has unknown type: notFound"))
+	})
+
+	t.Run("Send", func(t *testing.T) {
+		out, err := executeCmd("send", "-dir", testdataFolder, "-chain", "testAlarmChain", "-data", "{\"temperature\":20}", "-metadata", "deviceId=aa")
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(out, "\"nodeId\":\"s1\""))
+		assert.True(t, strings.Contains(out, "\"relationType\":\"False\""))
+		assert.True(t, strings.Contains(out, "\"deviceId\":\"aa\""))
+
+		_, err = executeCmd("send", "-dir", testdataFolder, "-chain", "notFound")
+		assert.Equal(t, "ruleChain id=notFound not found", err.Error())
+		_, err = executeCmd("send", "-dir", testdataFolder, "-chain", "testAlarmChain", "-metadata", "deviceId")
+		assert.Equal(t, "invalid metadata: deviceId", err.Error())
+	})
+
+	t.Run("Migrate", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "iterator.json")
+		def := `{"ruleChain":{"id":"iterator"},"metadata":{"nodes":[{"id":"s1","type":"iterator","configuration":{"fieldName":"items"}},{"id":"s2","type":"log"}],"connections":[{"fromId":"s1","toId":"s2","type":"True"}]}}`
+		assert.Nil(t, os.WriteFile(file, []byte(def), 0644))
+		out, err := executeCmd("migrate", dir)
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(out, "node s1 iterator -> for [v0.22.0]"))
+		b, _ := os.ReadFile(file)
+		assert.Equal(t, def, string(b))
+
+		_, err = executeCmd("migrate", "-w", file)
+		assert.Nil(t, err)
+		out, err = executeCmd("migrate", file)
+		assert.Nil(t, err)
+		assert.Equal(t, "ok   "+file+"\n", out)
+		out, err = executeCmd("validate", file)
+		assert.Nil(t, err)
+	})
+
+	t.Run("Graph", func(t *testing.T) {
+		file := filepath.Join(testdataFolder, "alarm_chain.json")
+		out, err := executeCmd("graph", file)
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(out, "digraph \"testAlarmChain\""))
+		assert.True(t, strings.Contains(out, "\"s1\" -> \"s2\" [label=\"True\"];"))
+
+		out, err = executeCmd("graph", "-format", "mermaid", file)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(out, "flowchart TD"))
+		assert.True(t, strings.Contains(out, "s2 -->|Failure| s4"))
+
+		_, err = executeCmd("graph", "-format", "svg", file)
+		assert.Equal(t, "unknown format: svg", err.Error())
+	})
+
+	t.Run("Test", func(t *testing.T) {
+		out, err := executeCmd("test", "-coverage", testdataFolder)
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(out, "PASS 5 test cases"))
+		assert.True(t, strings.Contains(out, "alarm_notify.test.yaml/notify failure"))
+		assert.True(t, strings.Contains(out, "ruleChain testAlarmChain"))
+	})
+
+	t.Run("Bench", func(t *testing.T) {
+		file := filepath.Join(testdataFolder, "alarm_chain.json")
+		out, err := executeCmd("bench", "-total", "20", "-concurrency", "2", "-data", "{\"temperature\":60,\"seq\":${seq}}", "-mockType", "restApiCall", file)
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(out, "sent: 20 completed: 20 failed: 0"))
+		assert.True(t, strings.Contains(out, "node s2 count: 20"))
+
+		out, err = executeCmd("bench", "-total", "5", "-output", "json", "-mockNode", "s2", file)
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(out, "\"sent\":5"))
+
+		_, err = executeCmd("bench", "-metadata", "deviceId", file)
+		assert.Equal(t, "invalid metadata: deviceId", err.Error())
+	})
+
+	t.Run("Components", func(t *testing.T) {
+		out, err := executeCmd("components")
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(out, "\"type\":\"jsFilter\""))
+
+		out, err = executeCmd("components", "-schema")
+		assert.Nil(t, err)
+		var schemas map[string]map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(out), &schemas))
+		assert.Equal(t, []interface{}{"jsScript"}, schemas["jsFilter"]["required"])
+	})
+}
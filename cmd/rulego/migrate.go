@@ -0,0 +1,62 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/engine"
+)
+
+// migrateCmd 迁移规则链DSL文件中废弃的组件和配置，默认只打印迁移报告
+func migrateCmd(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the migrated rule chain to the source file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	paths, err := chainFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	config := rulego.NewConfig()
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dsl, report, err := engine.MigrateDsl(config, b)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if !report.Changed() {
+			_, _ = fmt.Fprintf(stdout, "ok   %s\n", path)
+			continue
+		}
+		_, _ = fmt.Fprintf(stdout, "migrate %s\n%s", path, report)
+		if *write {
+			if err := os.WriteFile(path, dsl, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/engine"
+)
+
+// runCmd 加载规则链目录，并启动规则链DSL中的endpoints，直到收到退出信号
+func runCmd(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	dir := fs.String("dir", "./", "folder of the rule chain files")
+	watch := fs.Bool("watch", false, "reload the rule chains when the files are changed")
+	shutdownTimeout := fs.Duration("shutdownTimeout", 10*time.Second, "timeout of waiting for in-flight messages when exiting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	pool := rulego.NewRuleGo()
+	if *watch {
+		watcher, err := pool.Watch(*dir, engine.WatchConfig{
+			OnReload: func(event engine.WatchEvent) {
+				if event.Err != nil {
+					_, _ = fmt.Fprintf(stdout, "%s %s error: %s\n", event.Op, event.Path, event.Err)
+				} else {
+					_, _ = fmt.Fprintf(stdout, "%s %s ruleChain id=%s\n", event.Op, event.Path, event.ChainId)
+				}
+			},
+		})
+		if err != nil {
+			return err
+		}
+		defer watcher.Stop()
+	} else if err := pool.Load(*dir); err != nil {
+		return err
+	}
+	var count int
+	pool.Range(func(key, value any) bool {
+		count++
+		return true
+	})
+	_, _ = fmt.Fprintf(stdout, "loaded %d rule chains from %s, press Ctrl+C to exit\n", count, *dir)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	return pool.Pool().Shutdown(ctx)
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/engine"
+	"github.com/rulego/rulego/utils/json"
+)
+
+// branchResult 规则链分支执行结果
+type branchResult struct {
+	NodeId       string        `json:"nodeId"`
+	RelationType string        `json:"relationType"`
+	Err          string        `json:"err,omitempty"`
+	Msg          types.RuleMsg `json:"msg"`
+}
+
+// sendCmd 发送消息到规则链，并打印每个分支的执行结果
+func sendCmd(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("send", flag.ContinueOnError)
+	dir := flags.String("dir", "./", "folder of the rule chain files")
+	chainId := flags.String("chain", "", "id of the rule chain")
+	msgType := flags.String("type", "DEFAULT", "message type")
+	dataType := flags.String("dataType", string(types.JSON), "message data type: JSON, TEXT or BINARY")
+	data := flags.String("data", "{}", "message data")
+	metadata := flags.String("metadata", "", "message metadata, format: k1=v1,k2=v2")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *chainId == "" {
+		return errors.New("chain can not empty")
+	}
+	md, err := parseMetadata(*metadata)
+	if err != nil {
+		return err
+	}
+	config := rulego.NewConfig()
+	config.EndpointEnabled = false
+	pool := engine.NewPool()
+	defer pool.Stop()
+	if err := pool.Load(*dir, rulego.WithConfig(config)); err != nil {
+		return err
+	}
+	ruleEngine, ok := pool.Get(*chainId)
+	if !ok {
+		return fmt.Errorf("ruleChain id=%s not found", *chainId)
+	}
+	var results []branchResult
+	var lock sync.Mutex
+	msg := types.NewMsg(0, *msgType, types.DataType(*dataType), md, *data)
+	ruleEngine.OnMsgAndWait(msg, types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+		result := branchResult{NodeId: ctx.GetSelfId(), RelationType: relationType, Msg: msg}
+		if err != nil {
+			result.Err = err.Error()
+		}
+		lock.Lock()
+		results = append(results, result)
+		lock.Unlock()
+	}))
+	for _, result := range results {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(stdout, string(b))
+	}
+	return nil
+}
+
+// parseMetadata 解析元数据，格式：k1=v1,k2=v2
+func parseMetadata(s string) (types.Metadata, error) {
+	md := types.NewMetadata()
+	if s == "" {
+		return md, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid metadata: %s", item)
+		}
+		md.PutValue(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	return md, nil
+}
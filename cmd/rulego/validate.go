@@ -0,0 +1,142 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/engine"
+	"github.com/rulego/rulego/test/chaintest"
+	"github.com/rulego/rulego/utils/fs"
+)
+
+// validateCmd 校验规则链DSL文件
+func validateCmd(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	paths, err := chainFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	var failed int
+	for _, path := range paths {
+		if err := validateFile(path); err != nil {
+			failed++
+			_, _ = fmt.Fprintf(stdout, "FAIL %s: %s\n", path, err)
+		} else {
+			_, _ = fmt.Fprintf(stdout, "ok   %s\n", path)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d rule chain files are invalid", failed, len(paths))
+	}
+	return nil
+}
+
+// chainFiles 返回规则链文件列表，目录则递归查找*.json文件，不包括测试用例文件
+func chainFiles(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{"./"}
+	}
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		files, err := fs.GetFilePaths(strings.TrimSuffix(arg, "/") + "/*.json")
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !chaintest.IsTestFile(file) {
+				paths = append(paths, file)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// validateFile 检查规则链定义，并初始化规则链，不启动endpoints
+func validateFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	def, err := (&engine.JsonParser{}).DecodeRuleChain(b)
+	if err != nil {
+		return err
+	}
+	if err := lint(def); err != nil {
+		return err
+	}
+	config := rulego.NewConfig()
+	config.EndpointEnabled = false
+	pool := engine.NewPool()
+	defer pool.Stop()
+	_, err = pool.New(def.RuleChain.ID, b, rulego.WithConfig(config))
+	return err
+}
+
+// lint 检查规则链ID、节点ID、节点类型和连接
+func lint(def types.RuleChain) error {
+	if def.RuleChain.ID == "" {
+		return errors.New("ruleChain id is empty")
+	}
+	components := rulego.Registry.GetComponents()
+	var nodeIds = make(map[string]struct{})
+	for _, node := range def.Metadata.Nodes {
+		if node == nil {
+			continue
+		}
+		if node.Id == "" {
+			return errors.New("node id is empty")
+		}
+		if _, ok := nodeIds[node.Id]; ok {
+			return fmt.Errorf("duplicate node id=%s", node.Id)
+		}
+		nodeIds[node.Id] = struct{}{}
+		if _, ok := components[node.Type]; !ok {
+			return fmt.Errorf("node id=%s has unknown type: %s", node.Id, node.Type)
+		}
+	}
+	for _, conn := range def.Metadata.Connections {
+		if _, ok := nodeIds[conn.FromId]; !ok {
+			return fmt.Errorf("connection from node id=%s not found", conn.FromId)
+		}
+		if _, ok := nodeIds[conn.ToId]; !ok {
+			return fmt.Errorf("connection to node id=%s not found", conn.ToId)
+		}
+		if conn.Type == "" {
+			return fmt.Errorf("connection %s->%s type is empty", conn.FromId, conn.ToId)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,713 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "aggregate",
+//        "name": "温度统计",
+//        "debugMode": false,
+//        "configuration": {
+//          "groupBy": "metadata.deviceId",
+//          "fields": {"temperature": "msg.temperature"},
+//          "functions": ["min", "max", "avg", "p95"],
+//          "windowType": "tumbling",
+//          "sizeMs": 60000
+//        }
+//  }
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+const (
+	// WindowTumbling 滚动窗口，窗口大小固定，窗口之间不重叠
+	WindowTumbling = "tumbling"
+	// WindowSliding 滑动窗口，每隔SlideMs开始一个大小为SizeMs的窗口，窗口之间可以重叠
+	WindowSliding = "sliding"
+	// WindowSession 会话窗口，同一分组的消息间隔超过SessionGapMs则关闭窗口
+	WindowSession = "session"
+)
+
+const (
+	// TimeProcessing 按照消息到达节点的时间划分窗口
+	TimeProcessing = "processing"
+	// TimeEvent 按照消息时间 RuleMsg.Ts 划分窗口
+	TimeEvent = "event"
+)
+
+const (
+	// KeyGroupKey 聚合结果元数据中的分组key
+	KeyGroupKey = "groupKey"
+	// KeyWindowStart 聚合结果元数据中的窗口开始时间，毫秒时间戳
+	KeyWindowStart = "windowStart"
+	// KeyWindowEnd 聚合结果元数据中的窗口结束时间，毫秒时间戳
+	KeyWindowEnd = "windowEnd"
+)
+
+var (
+	// ErrMaxGroupsExceeded 分组数量超过限制
+	ErrMaxGroupsExceeded = errors.New("max groups exceeded")
+	// ErrAggregateDrained 聚合节点已经排空，不再接收消息
+	ErrAggregateDrained = errors.New("aggregate node is drained")
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&AggregateNode{})
+}
+
+// AggregateNodeConfiguration 节点配置
+type AggregateNodeConfiguration struct {
+	//GroupBy 分组key表达式，可以访问msg、metadata、id、type等变量，例如：metadata.deviceId
+	//为空则所有消息在同一个分组
+	GroupBy string
+	//Fields 聚合字段，key：结果字段名称，value：取值表达式，例如：{"temperature":"msg.temperature"}
+	//值必须是数值或者数值字符串，值不存在的消息不参与该字段的聚合
+	Fields map[string]string
+	//Functions 聚合函数，支持：count、sum、min、max、avg以及百分位数pN，例如：p50、p95、p99
+	Functions []string
+	//WindowType 窗口类型：tumbling(滚动窗口)、sliding(滑动窗口)、session(会话窗口)，默认tumbling
+	WindowType string `enum:"tumbling,sliding,session"`
+	//SizeMs 窗口大小，单位毫秒，tumbling和sliding窗口有效
+	SizeMs int64
+	//SlideMs 滑动步长，单位毫秒，sliding窗口有效
+	SlideMs int64
+	//SessionGapMs 会话间隔，单位毫秒，session窗口有效
+	SessionGapMs int64
+	//TimeType 时间类型：processing(处理时间)、event(事件时间，使用RuleMsg.Ts)，默认processing
+	TimeType string `enum:"processing,event"`
+	//AllowedLatenessMs 事件时间允许的延迟，单位毫秒，水位线=最大消息时间-AllowedLatenessMs
+	//窗口结束时间小于等于水位线时关闭窗口，属于已关闭窗口的消息通过`Late`链路路由到下一个节点
+	AllowedLatenessMs int64
+	//MaxGroups 最大分组数量，超过后新分组的消息通过`Failure`链路路由到下一个节点，默认10000
+	MaxGroups int `min:"1"`
+}
+
+// AggregateNode 窗口聚合节点，按照分组统计时间窗口内消息字段的count/sum/min/max/avg/百分位数
+// 窗口关闭时，每个分组的每个窗口通过`Success`链路发送一条聚合结果消息，消息内容例如：
+// {"groupKey":"aa","windowStart":1700000000000,"windowEnd":1700000060000,"count":3,"temperature":{"min":20,"max":40,"avg":30}}
+// 结果消息的类型和元数据取自窗口最后一条消息，并在元数据增加groupKey、windowStart和windowEnd
+// 参与聚合的消息在该节点结束，每个窗口挂起一条消息的处理，直到窗口关闭时使用它发送聚合结果
+// 滑动窗口中一条消息同时开启多个窗口时，由最后结束的窗口挂起，其他窗口的结果随该分组下一个挂起消息的窗口一起发送
+// 分组key或者字段表达式执行失败，发送到`Failure`链
+type AggregateNode struct {
+	//节点配置
+	Config AggregateNodeConfiguration
+	//分组key表达式
+	groupBy *vm.Program
+	//字段取值表达式
+	fields map[string]*vm.Program
+	//是否需要保存字段值用于计算百分位数
+	percentile bool
+	//分组窗口，key：分组key
+	groups map[string]*aggregateGroup
+	//事件时间的最大消息时间
+	maxTs int64
+	//是否已经排空
+	drained int32
+	//停止处理时间定时器
+	stop chan struct{}
+	mu   sync.Mutex
+}
+
+// aggregateGroup 分组窗口
+type aggregateGroup struct {
+	windows []*aggregateWindow
+	//已经关闭但是没有挂起消息的窗口结果，随下一个挂起消息的窗口一起发送
+	deferred []types.RuleMsg
+}
+
+// aggregateWindow 窗口，时间范围：[start,end)
+type aggregateWindow struct {
+	start   int64
+	end     int64
+	count   int64
+	lastMsg types.RuleMsg
+	stats   map[string]*fieldStats
+	//挂起的消息上下文，窗口关闭时使用它发送聚合结果
+	ctx types.RuleContext
+	//挂起的消息
+	heldMsg types.RuleMsg
+}
+
+// fieldStats 字段统计
+type fieldStats struct {
+	count  int64
+	sum    float64
+	min    float64
+	max    float64
+	values []float64
+}
+
+// aggregateResult 关闭窗口的聚合结果，使用同一个挂起的消息上下文发送
+type aggregateResult struct {
+	ctx  types.RuleContext
+	msgs []types.RuleMsg
+	end  int64
+}
+
+// 确保AggregateNode实现了types.DrainableNode接口
+var _ types.DrainableNode = (*AggregateNode)(nil)
+
+// Type 组件类型
+func (x *AggregateNode) Type() string {
+	return "aggregate"
+}
+
+func (x *AggregateNode) New() types.Node {
+	return &AggregateNode{Config: AggregateNodeConfiguration{
+		Fields:     map[string]string{"temperature": "msg.temperature"},
+		Functions:  []string{"count", "min", "max", "avg"},
+		WindowType: WindowTumbling,
+		SizeMs:     60000,
+		TimeType:   TimeProcessing,
+		MaxGroups:  10000,
+	}}
+}
+
+// Init 初始化
+func (x *AggregateNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	//map和数组配置需要覆盖默认值，而不是合并，所以使用零值配置解析，再填充默认值
+	x.Config = AggregateNodeConfiguration{}
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if len(x.Config.Functions) == 0 {
+		x.Config.Functions = []string{"count", "min", "max", "avg"}
+	}
+	if x.Config.SizeMs == 0 {
+		x.Config.SizeMs = 60000
+	}
+	if x.Config.WindowType == "" {
+		x.Config.WindowType = WindowTumbling
+	}
+	if x.Config.TimeType == "" {
+		x.Config.TimeType = TimeProcessing
+	}
+	if x.Config.MaxGroups <= 0 {
+		x.Config.MaxGroups = 10000
+	}
+	switch x.Config.WindowType {
+	case WindowTumbling:
+		x.Config.SlideMs = x.Config.SizeMs
+	case WindowSliding:
+		if x.Config.SlideMs <= 0 || x.Config.SlideMs > x.Config.SizeMs {
+			return errors.New("slideMs must be greater than 0 and less than or equal to sizeMs")
+		}
+	case WindowSession:
+		if x.Config.SessionGapMs <= 0 {
+			return errors.New("sessionGapMs must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("unsupported window type: %s", x.Config.WindowType)
+	}
+	if x.Config.WindowType != WindowSession && x.Config.SizeMs <= 0 {
+		return errors.New("sizeMs must be greater than 0")
+	}
+	if x.Config.TimeType != TimeProcessing && x.Config.TimeType != TimeEvent {
+		return fmt.Errorf("unsupported time type: %s", x.Config.TimeType)
+	}
+	for _, function := range x.Config.Functions {
+		if isPercentile, err := checkAggregateFunction(function); err != nil {
+			return err
+		} else if isPercentile {
+			x.percentile = true
+		}
+	}
+	if x.Config.GroupBy = strings.TrimSpace(x.Config.GroupBy); x.Config.GroupBy != "" {
+		if x.groupBy, err = expr.Compile(x.Config.GroupBy, expr.AllowUndefinedVariables()); err != nil {
+			return err
+		}
+	}
+	x.fields = make(map[string]*vm.Program, len(x.Config.Fields))
+	for name, fieldExpr := range x.Config.Fields {
+		if x.fields[name], err = expr.Compile(fieldExpr, expr.AllowUndefinedVariables()); err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+	x.groups = make(map[string]*aggregateGroup)
+	if x.Config.TimeType == TimeProcessing {
+		x.stop = make(chan struct{})
+		go x.tick(x.tickInterval())
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *AggregateNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	if atomic.LoadInt32(&x.drained) == 1 {
+		ctx.TellFailure(msg, ErrAggregateDrained)
+		return
+	}
+	evn := base.NodeUtils.GetEvn(ctx, msg)
+	key, values, err := x.eval(evn)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ts := time.Now().UnixMilli()
+	if x.Config.TimeType == TimeEvent {
+		ts = msg.Ts
+	}
+
+	x.mu.Lock()
+	if x.Config.TimeType == TimeEvent && ts > x.maxTs {
+		x.maxTs = ts
+	}
+	watermark := x.watermark()
+	group, ok := x.groups[key]
+	if !ok {
+		if len(x.groups) >= x.Config.MaxGroups {
+			x.mu.Unlock()
+			ctx.TellFailure(msg, ErrMaxGroupsExceeded)
+			return
+		}
+		group = &aggregateGroup{}
+	}
+	windows, released := x.assign(group, ts, watermark)
+	if len(windows) == 0 {
+		x.mu.Unlock()
+		ctx.TellNext(msg, types.Late)
+		return
+	}
+	x.groups[key] = group
+	//由最后结束的、还没有挂起消息的窗口挂起该消息
+	var holder *aggregateWindow
+	for _, w := range windows {
+		w.add(msg, values, x.percentile)
+		if w.ctx == nil && (holder == nil || w.end > holder.end) {
+			holder = w
+		}
+	}
+	if holder != nil {
+		holder.ctx = ctx
+		holder.heldMsg = msg
+	}
+	var results []aggregateResult
+	if x.Config.TimeType == TimeEvent {
+		results = x.closeWindows(watermark)
+	}
+	x.mu.Unlock()
+
+	if holder == nil {
+		//消息已经聚合到窗口，结束该消息的处理
+		ctx.DoOnEnd(msg, nil, types.Success)
+	}
+	//合并的会话窗口只发送一条结果，结束被合并窗口挂起的消息
+	for _, w := range released {
+		w.ctx.DoOnEnd(w.heldMsg, nil, types.Success)
+	}
+	x.emit(results, nil)
+}
+
+// Drain 优雅停机时关闭所有窗口，立即发送聚合结果
+// 如果persist不为空，聚合结果交给persist持久化
+func (x *AggregateNode) Drain(persist func(msg types.RuleMsg)) {
+	x.mu.Lock()
+	atomic.StoreInt32(&x.drained, 1)
+	results := x.closeWindows(math.MaxInt64)
+	x.mu.Unlock()
+	x.emit(results, persist)
+}
+
+// Destroy 销毁，关闭所有窗口并发送聚合结果，结束挂起的消息
+func (x *AggregateNode) Destroy() {
+	x.mu.Lock()
+	if x.stop != nil {
+		close(x.stop)
+		x.stop = nil
+	}
+	atomic.StoreInt32(&x.drained, 1)
+	results := x.closeWindows(math.MaxInt64)
+	x.mu.Unlock()
+	x.emit(results, nil)
+}
+
+// eval 计算分组key和字段值
+func (x *AggregateNode) eval(evn map[string]interface{}) (string, map[string]float64, error) {
+	var key string
+	if x.groupBy != nil {
+		out, err := vm.Run(x.groupBy, evn)
+		if err != nil {
+			return "", nil, err
+		}
+		key = str.ToString(out)
+	}
+	values := make(map[string]float64, len(x.fields))
+	for name, program := range x.fields {
+		out, err := vm.Run(program, evn)
+		if err != nil {
+			return "", nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		if out == nil {
+			continue
+		}
+		v, err := toFloat64(out)
+		if err != nil {
+			return "", nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		values[name] = v
+	}
+	return key, values, nil
+}
+
+// watermark 水位线，结束时间小于等于水位线的窗口可以关闭
+func (x *AggregateNode) watermark() int64 {
+	if x.Config.TimeType == TimeEvent {
+		return x.maxTs - x.Config.AllowedLatenessMs
+	}
+	return time.Now().UnixMilli()
+}
+
+// assign 返回消息所属的窗口，不存在则创建，不返回已经关闭的窗口
+// 同时返回被合并的、挂起消息的会话窗口
+func (x *AggregateNode) assign(group *aggregateGroup, ts, watermark int64) ([]*aggregateWindow, []*aggregateWindow) {
+	if x.Config.WindowType == WindowSession {
+		return x.assignSession(group, ts, watermark)
+	}
+	var windows []*aggregateWindow
+	size, slide := x.Config.SizeMs, x.Config.SlideMs
+	lastStart := ts - mod(ts, slide)
+	for start := lastStart; start > ts-size; start -= slide {
+		if start+size <= watermark && x.Config.TimeType == TimeEvent {
+			continue
+		}
+		var w *aggregateWindow
+		for _, item := range group.windows {
+			if item.start == start {
+				w = item
+				break
+			}
+		}
+		if w == nil {
+			w = &aggregateWindow{start: start, end: start + size}
+			group.windows = append(group.windows, w)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// assignSession 返回消息所属的会话窗口，消息落在会话间隔内则延长会话窗口，并合并重叠的会话窗口
+func (x *AggregateNode) assignSession(group *aggregateGroup, ts, watermark int64) ([]*aggregateWindow, []*aggregateWindow) {
+	gap := x.Config.SessionGapMs
+	var w *aggregateWindow
+	var windows []*aggregateWindow
+	var released []*aggregateWindow
+	for _, item := range group.windows {
+		if ts >= item.start-gap && ts < item.end {
+			if w == nil {
+				w = item
+				if ts < w.start {
+					w.start = ts
+				}
+				if ts+gap > w.end {
+					w.end = ts + gap
+				}
+				windows = append(windows, item)
+				continue
+			}
+			//合并会话窗口
+			w.merge(item)
+			if w.ctx == nil {
+				w.ctx, w.heldMsg = item.ctx, item.heldMsg
+			} else if item.ctx != nil {
+				released = append(released, item)
+			}
+			continue
+		}
+		windows = append(windows, item)
+	}
+	if w == nil {
+		if ts+gap <= watermark && x.Config.TimeType == TimeEvent {
+			return nil, nil
+		}
+		w = &aggregateWindow{start: ts, end: ts + gap}
+		windows = append(windows, w)
+	}
+	group.windows = windows
+	return []*aggregateWindow{w}, released
+}
+
+// closeWindows 关闭结束时间小于等于水位线的窗口，返回聚合结果
+func (x *AggregateNode) closeWindows(watermark int64) []aggregateResult {
+	var results []aggregateResult
+	for key, group := range x.groups {
+		var remain, closed []*aggregateWindow
+		for _, w := range group.windows {
+			if w.end > watermark {
+				remain = append(remain, w)
+			} else {
+				closed = append(closed, w)
+			}
+		}
+		sort.SliceStable(closed, func(i, j int) bool {
+			return closed[i].end < closed[j].end
+		})
+		for _, w := range closed {
+			group.deferred = append(group.deferred, x.result(key, w))
+			if w.ctx != nil {
+				results = append(results, aggregateResult{ctx: w.ctx, msgs: group.deferred, end: w.end})
+				group.deferred = nil
+			}
+		}
+		group.windows = remain
+		if len(remain) == 0 && len(group.deferred) == 0 {
+			delete(x.groups, key)
+		}
+	}
+	//按照窗口结束时间发送
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].end < results[j].end
+	})
+	return results
+}
+
+// result 生成窗口的聚合结果消息
+func (x *AggregateNode) result(key string, w *aggregateWindow) types.RuleMsg {
+	data := map[string]interface{}{
+		KeyGroupKey:    key,
+		KeyWindowStart: w.start,
+		KeyWindowEnd:   w.end,
+		"count":        w.count,
+	}
+	for name, stats := range w.stats {
+		data[name] = stats.result(x.Config.Functions)
+	}
+	metadata := w.lastMsg.Metadata.Copy()
+	metadata.PutValue(KeyGroupKey, key)
+	metadata.PutValue(KeyWindowStart, strconv.FormatInt(w.start, 10))
+	metadata.PutValue(KeyWindowEnd, strconv.FormatInt(w.end, 10))
+	b, _ := json.Marshal(data)
+	return types.NewMsg(0, w.lastMsg.Type, types.JSON, metadata, string(b))
+}
+
+// emit 发送聚合结果
+func (x *AggregateNode) emit(results []aggregateResult, persist func(msg types.RuleMsg)) {
+	for _, item := range results {
+		for _, msg := range item.msgs {
+			if persist != nil {
+				persist(msg)
+			} else {
+				item.ctx.TellSuccess(msg)
+			}
+		}
+		if persist != nil {
+			item.ctx.DoOnEnd(item.msgs[len(item.msgs)-1], nil, types.Success)
+		}
+	}
+}
+
+// tick 处理时间窗口定时关闭
+func (x *AggregateNode) tick(interval time.Duration) {
+	x.mu.Lock()
+	stop := x.stop
+	x.mu.Unlock()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			x.mu.Lock()
+			results := x.closeWindows(time.Now().UnixMilli())
+			x.mu.Unlock()
+			x.emit(results, nil)
+		}
+	}
+}
+
+// tickInterval 定时检查窗口的间隔，取窗口步长或者会话间隔的1/10，范围10ms~1s
+func (x *AggregateNode) tickInterval() time.Duration {
+	ms := x.Config.SlideMs
+	if x.Config.WindowType == WindowSession {
+		ms = x.Config.SessionGapMs
+	}
+	interval := time.Duration(ms) * time.Millisecond / 10
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	} else if interval > time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+// add 把消息的字段值聚合到窗口
+func (w *aggregateWindow) add(msg types.RuleMsg, values map[string]float64, keepValues bool) {
+	w.count++
+	w.lastMsg = msg
+	if w.stats == nil {
+		w.stats = make(map[string]*fieldStats, len(values))
+	}
+	for name, v := range values {
+		stats, ok := w.stats[name]
+		if !ok {
+			stats = &fieldStats{min: v, max: v}
+			w.stats[name] = stats
+		}
+		stats.add(v, keepValues)
+	}
+}
+
+// merge 合并其他会话窗口，合并后当前消息会聚合到w，所以保留w的lastMsg
+func (w *aggregateWindow) merge(other *aggregateWindow) {
+	if other.start < w.start {
+		w.start = other.start
+	}
+	if other.end > w.end {
+		w.end = other.end
+	}
+	w.count += other.count
+	if w.stats == nil {
+		w.stats = make(map[string]*fieldStats, len(other.stats))
+	}
+	for name, stats := range other.stats {
+		if s, ok := w.stats[name]; ok {
+			s.merge(stats)
+		} else {
+			w.stats[name] = stats
+		}
+	}
+}
+
+func (s *fieldStats) add(v float64, keepValues bool) {
+	s.count++
+	s.sum += v
+	if v < s.min {
+		s.min = v
+	}
+	if v > s.max {
+		s.max = v
+	}
+	if keepValues {
+		s.values = append(s.values, v)
+	}
+}
+
+func (s *fieldStats) merge(other *fieldStats) {
+	s.count += other.count
+	s.sum += other.sum
+	if other.min < s.min {
+		s.min = other.min
+	}
+	if other.max > s.max {
+		s.max = other.max
+	}
+	s.values = append(s.values, other.values...)
+}
+
+// result 计算聚合函数的结果
+func (s *fieldStats) result(functions []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(functions))
+	var sorted []float64
+	for _, function := range functions {
+		switch function {
+		case "count":
+			result[function] = s.count
+		case "sum":
+			result[function] = s.sum
+		case "min":
+			result[function] = s.min
+		case "max":
+			result[function] = s.max
+		case "avg":
+			result[function] = s.sum / float64(s.count)
+		default:
+			if sorted == nil {
+				sorted = append([]float64(nil), s.values...)
+				sort.Float64s(sorted)
+			}
+			p, _ := strconv.ParseFloat(function[1:], 64)
+			result[function] = percentile(sorted, p)
+		}
+	}
+	return result
+}
+
+// checkAggregateFunction 检查聚合函数是否支持，返回是否是百分位数
+func checkAggregateFunction(function string) (bool, error) {
+	switch function {
+	case "count", "sum", "min", "max", "avg":
+		return false, nil
+	}
+	if strings.HasPrefix(function, "p") {
+		if p, err := strconv.ParseFloat(function[1:], 64); err == nil && p > 0 && p <= 100 {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("unsupported aggregate function: %s", function)
+}
+
+// percentile 使用最近秩方法计算百分位数，values必须已经排序
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	index := int(math.Ceil(p/100*float64(len(values)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return values[index]
+}
+
+// toFloat64 把数值或者数值字符串转换成float64
+func toFloat64(v interface{}) (float64, error) {
+	switch value := v.(type) {
+	case float64:
+		return value, nil
+	case float32:
+		return float64(value), nil
+	case int:
+		return float64(value), nil
+	case int32:
+		return float64(value), nil
+	case int64:
+		return float64(value), nil
+	case string:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return 0, fmt.Errorf("value %v is not a number", v)
+	}
+}
+
+// mod 取模，结果非负
+func mod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
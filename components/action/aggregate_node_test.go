@@ -0,0 +1,298 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/str"
+)
+
+func TestAggregateNode(t *testing.T) {
+	var targetNodeType = "aggregate"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &AggregateNode{}, types.Configuration{
+			"fields":     map[string]string{"temperature": "msg.temperature"},
+			"functions":  []string{"count", "min", "max", "avg"},
+			"windowType": WindowTumbling,
+			"sizeMs":     int64(60000),
+			"timeType":   TimeProcessing,
+			"maxGroups":  10000,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"groupBy":      "metadata.deviceId",
+			"windowType":   WindowSession,
+			"sessionGapMs": 1000,
+			"timeType":     TimeEvent,
+		}, types.Configuration{
+			"groupBy":      "metadata.deviceId",
+			"windowType":   WindowSession,
+			"sessionGapMs": int64(1000),
+			"timeType":     TimeEvent,
+			"maxGroups":    10000,
+			"functions":    []string{"count", "min", "max", "avg"},
+		}, Registry)
+
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"fields":    map[string]string{"humidity": "msg.humidity"},
+			"functions": []string{"max"},
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		aggregateNode := node.(*AggregateNode)
+		assert.Equal(t, []string{"max"}, aggregateNode.Config.Functions)
+		assert.Equal(t, map[string]string{"humidity": "msg.humidity"}, aggregateNode.Config.Fields)
+
+		var errConfigs = map[string]types.Configuration{
+			"unsupported window type: hopping":                                {"windowType": "hopping"},
+			"sizeMs must be greater than 0":                                   {"sizeMs": -1},
+			"slideMs must be greater than 0 and less than or equal to sizeMs": {"windowType": WindowSliding, "sizeMs": 100, "slideMs": 200},
+			"sessionGapMs must be greater than 0":                             {"windowType": WindowSession},
+			"unsupported time type: ingestion":                                {"timeType": "ingestion"},
+			"unsupported aggregate function: p0":                              {"functions": []string{"p0"}},
+			"unsupported aggregate function: median":                          {"functions": []string{"median"}},
+		}
+		for expected, config := range errConfigs {
+			_, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+			assert.NotNil(t, err)
+			assert.Equal(t, expected, err.Error())
+		}
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"groupBy": "metadata.deviceId +"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("TumblingProcessingTime", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"groupBy":   "metadata.deviceId",
+			"fields":    map[string]string{"temperature": "msg.temperature", "humidity": "msg.humidity"},
+			"functions": []string{"count", "sum", "min", "max", "avg", "p50", "p95"},
+			"sizeMs":    300,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		aa := types.BuildMetadata(map[string]string{"deviceId": "aa"})
+		bb := types.BuildMetadata(map[string]string{"deviceId": "bb"})
+		var msgList = []test.Msg{
+			{MetaData: aa, MsgType: "TELEMETRY", Data: "{\"temperature\":20}"},
+			{MetaData: aa, MsgType: "TELEMETRY", Data: "{\"temperature\":40,\"humidity\":50}"},
+			{MetaData: aa, MsgType: "TELEMETRY", Data: "{\"temperature\":\"30\"}"},
+			{MetaData: bb, MsgType: "TELEMETRY", Data: "{\"temperature\":10}"},
+			{MetaData: bb, MsgType: "TELEMETRY", Data: "{\"temperature\":\"abc\"}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Wait(t, 3)
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, types.Failure, results[0].RelationType)
+		//同一个窗口关闭时，分组的发送顺序不确定
+		sort.Slice(results[1:], func(i, j int) bool {
+			return results[1+i].Msg.Metadata.GetValue(KeyGroupKey) < results[1+j].Msg.Metadata.GetValue(KeyGroupKey)
+		})
+		var data map[string]interface{}
+		_ = json.Unmarshal([]byte(results[1].Msg.Data), &data)
+		assert.Equal(t, types.Success, results[1].RelationType)
+		assert.Equal(t, "aa", data[KeyGroupKey])
+		assert.Equal(t, "aa", results[1].Msg.Metadata.GetValue(KeyGroupKey))
+		assert.Equal(t, float64(3), data["count"])
+		temperature := data["temperature"].(map[string]interface{})
+		assert.Equal(t, float64(3), temperature["count"])
+		assert.Equal(t, float64(90), temperature["sum"])
+		assert.Equal(t, float64(20), temperature["min"])
+		assert.Equal(t, float64(40), temperature["max"])
+		assert.Equal(t, float64(30), temperature["avg"])
+		assert.Equal(t, float64(30), temperature["p50"])
+		assert.Equal(t, float64(40), temperature["p95"])
+		humidity := data["humidity"].(map[string]interface{})
+		assert.Equal(t, float64(1), humidity["count"])
+		windowStart := int64(data[KeyWindowStart].(float64))
+		windowEnd := int64(data[KeyWindowEnd].(float64))
+		assert.Equal(t, int64(300), windowEnd-windowStart)
+		_ = json.Unmarshal([]byte(results[2].Msg.Data), &data)
+		assert.Equal(t, "bb", data[KeyGroupKey])
+		assert.Equal(t, float64(1), data["count"])
+	})
+
+	t.Run("TumblingEventTime", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"fields":            map[string]string{"temperature": "msg.temperature"},
+			"functions":         []string{"max"},
+			"sizeMs":            1000,
+			"timeType":          TimeEvent,
+			"allowedLatenessMs": 200,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{Ts: 1000, MsgType: "TELEMETRY", Data: "{\"temperature\":20}"},
+			{Ts: 2100, MsgType: "TELEMETRY", Data: "{\"temperature\":50}"},
+			//在允许的延迟内
+			{Ts: 1900, MsgType: "TELEMETRY", Data: "{\"temperature\":30}"},
+			//关闭第一个窗口
+			{Ts: 2300, MsgType: "TELEMETRY", Data: "{\"temperature\":10}"},
+			{Ts: 1500, MsgType: "TELEMETRY", Data: "{\"temperature\":60}"},
+		}
+		//事件时间窗口由消息关闭，同步发送结果
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, types.Success, results[0].RelationType)
+		var data map[string]interface{}
+		_ = json.Unmarshal([]byte(results[0].Msg.Data), &data)
+		assert.Equal(t, float64(1000), data[KeyWindowStart])
+		assert.Equal(t, float64(2000), data[KeyWindowEnd])
+		assert.Equal(t, float64(2), data["count"])
+		assert.Equal(t, float64(30), data["temperature"].(map[string]interface{})["max"])
+		assert.Equal(t, types.Late, results[1].RelationType)
+		assert.Equal(t, "{\"temperature\":60}", results[1].Msg.Data)
+	})
+
+	t.Run("SlidingEventTime", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"windowType": WindowSliding,
+			"sizeMs":     1000,
+			"slideMs":    500,
+			"timeType":   TimeEvent,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{Ts: 1000, MsgType: "TELEMETRY", Data: "{\"temperature\":20}"},
+			{Ts: 1600, MsgType: "TELEMETRY", Data: "{\"temperature\":30}"},
+			{Ts: 3000, MsgType: "TELEMETRY", Data: "{\"temperature\":40}"},
+		}
+		var windows []string
+		for _, item := range test.NewNodeCollector(node).OnMsg(node, msgList...).Results() {
+			var data map[string]interface{}
+			_ = json.Unmarshal([]byte(item.Msg.Data), &data)
+			windows = append(windows, item.Msg.Metadata.GetValue(KeyWindowStart)+"-"+item.Msg.Metadata.GetValue(KeyWindowEnd)+":"+str.ToString(data["count"]))
+		}
+		assert.Equal(t, []string{"500-1500:1", "1000-2000:2", "1500-2500:1"}, windows)
+	})
+
+	t.Run("SessionEventTime", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"groupBy":           "metadata.deviceId",
+			"windowType":        WindowSession,
+			"sessionGapMs":      500,
+			"timeType":          TimeEvent,
+			"allowedLatenessMs": 1000,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		aa := types.BuildMetadata(map[string]string{"deviceId": "aa"})
+		var msgList = []test.Msg{
+			{Ts: 1000, MetaData: aa, MsgType: "TELEMETRY", Data: "{}"},
+			{Ts: 1800, MetaData: aa, MsgType: "TELEMETRY", Data: "{}"},
+			//合并前面两个会话
+			{Ts: 1400, MetaData: aa, MsgType: "TELEMETRY", Data: "{}"},
+			{Ts: 3400, MetaData: aa, MsgType: "TELEMETRY", Data: "{}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 1, len(results))
+		var data map[string]interface{}
+		_ = json.Unmarshal([]byte(results[0].Msg.Data), &data)
+		assert.Equal(t, float64(1000), data[KeyWindowStart])
+		assert.Equal(t, float64(2300), data[KeyWindowEnd])
+		assert.Equal(t, float64(3), data["count"])
+	})
+
+	t.Run("HeldContextPerWindow", func(t *testing.T) {
+		//每条消息使用独立的上下文，记录聚合结果通过哪条消息的上下文发送
+		onMsg := func(node types.Node, tsList []int64) map[int][]string {
+			var collectors []*test.NodeCollector
+			for _, ts := range tsList {
+				collectors = append(collectors, test.NewNodeCollector(node).OnMsg(node, test.Msg{Ts: ts, MsgType: "TELEMETRY", Data: "{}"}))
+			}
+			sent := make(map[int][]string)
+			for i, collector := range collectors {
+				for _, item := range collector.Results() {
+					sent[i] = append(sent[i], item.Msg.Metadata.GetValue(KeyWindowStart)+"-"+item.Msg.Metadata.GetValue(KeyWindowEnd))
+				}
+			}
+			return sent
+		}
+
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"sizeMs":   1000,
+			"timeType": TimeEvent,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		//第二个窗口由没有挂起的消息关闭，结果通过该窗口挂起的第一条消息发送
+		sent := onMsg(node, []int64{100, 600, 1200, 1500, 2100})
+		assert.Equal(t, map[int][]string{0: {"0-1000"}, 2: {"1000-2000"}}, sent)
+
+		node, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"windowType": WindowSliding,
+			"sizeMs":     2000,
+			"slideMs":    1000,
+			"timeType":   TimeEvent,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		//第一条消息开启两个窗口，由后结束的窗口挂起，先结束的窗口结果随它一起发送
+		sent = onMsg(node, []int64{100, 1100, 2100, 3100})
+		assert.Equal(t, map[int][]string{0: {"-1000-1000", "0-2000"}, 1: {"1000-3000"}}, sent)
+	})
+
+	t.Run("MaxGroups", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"groupBy":   "metadata.deviceId",
+			"maxGroups": 1,
+			"sizeMs":    60000,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{MetaData: types.BuildMetadata(map[string]string{"deviceId": "aa"}), MsgType: "TELEMETRY", Data: "{}"},
+			{MetaData: types.BuildMetadata(map[string]string{"deviceId": "aa"}), MsgType: "TELEMETRY", Data: "{}"},
+			{MetaData: types.BuildMetadata(map[string]string{"deviceId": "bb"}), MsgType: "TELEMETRY", Data: "{}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, ErrMaxGroupsExceeded, results[0].Err)
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"sizeMs": 60000,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{MsgType: "TELEMETRY", Data: "{\"temperature\":20}"},
+		}
+		collector := test.NewNodeCollector(node).OnMsg(node, msgList...)
+		assert.Equal(t, 0, len(collector.Results()))
+
+		var persisted []types.RuleMsg
+		node.(types.DrainableNode).Drain(func(msg types.RuleMsg) {
+			persisted = append(persisted, msg)
+		})
+		assert.Equal(t, 1, len(persisted))
+
+		results := collector.OnMsg(node, msgList...).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, ErrAggregateDrained, results[0].Err)
+	})
+}
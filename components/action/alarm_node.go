@@ -0,0 +1,363 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "alarm",
+//        "name": "高温告警",
+//        "debugMode": false,
+//        "configuration": {
+//          "originator": "metadata.deviceId",
+//          "alarmType": "High Temperature",
+//          "createCondition": "msg.temperature > 50",
+//          "clearCondition": "msg.temperature <= 45",
+//          "ackCondition": "msgType == 'ALARM_ACK'",
+//          "severity": "msg.temperature > 80 ? 'CRITICAL' : 'MAJOR'"
+//        }
+//  }
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/gofrs/uuid/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/cache"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+// 告警严重程度，从高到低
+const (
+	SeverityCritical      = "CRITICAL"
+	SeverityMajor         = "MAJOR"
+	SeverityMinor         = "MINOR"
+	SeverityWarning       = "WARNING"
+	SeverityIndeterminate = "INDETERMINATE"
+)
+
+// 告警状态
+const (
+	AlarmActiveUnack  = "ACTIVE_UNACK"
+	AlarmActiveAck    = "ACTIVE_ACK"
+	AlarmClearedUnack = "CLEARED_UNACK"
+	AlarmClearedAck   = "CLEARED_ACK"
+)
+
+// severityLevels 严重程度等级，值越大越严重
+var severityLevels = map[string]int{
+	SeverityIndeterminate: 1,
+	SeverityWarning:       2,
+	SeverityMinor:         3,
+	SeverityMajor:         4,
+	SeverityCritical:      5,
+}
+
+// 注册节点
+func init() {
+	Registry.Add(&AlarmNode{})
+}
+
+// Alarm 告警
+type Alarm struct {
+	//告警ID
+	Id string `json:"id"`
+	//告警发起者，例如设备ID
+	Originator string `json:"originator"`
+	//告警类型
+	Type string `json:"type"`
+	//严重程度
+	Severity string `json:"severity"`
+	//告警状态：ACTIVE_UNACK、ACTIVE_ACK、CLEARED_UNACK、CLEARED_ACK
+	Status       string `json:"status"`
+	Acknowledged bool   `json:"acknowledged"`
+	Cleared      bool   `json:"cleared"`
+	//告警创建时间，毫秒时间戳
+	StartTs int64 `json:"startTs"`
+	//告警最后一次更新时间，毫秒时间戳
+	EndTs int64 `json:"endTs"`
+	//确认时间，毫秒时间戳
+	AckTs int64 `json:"ackTs,omitempty"`
+	//清除时间，毫秒时间戳
+	ClearTs int64 `json:"clearTs,omitempty"`
+	//告警详情，取自最后一次创建或者更新告警的消息内容
+	Details interface{} `json:"details,omitempty"`
+}
+
+// AlarmNodeConfiguration 节点配置
+type AlarmNodeConfiguration struct {
+	//Originator 告警发起者表达式，可以访问msg、metadata、id、type等变量，默认：metadata.deviceId
+	Originator string `required:"true"`
+	//AlarmType 告警类型，支持${}变量，例如：${metadata.productType} High Temperature
+	AlarmType string `required:"true"`
+	//CreateCondition 创建告警条件表达式，返回true时创建或者更新告警，例如：msg.temperature > 50
+	CreateCondition string `required:"true"`
+	//ClearCondition 清除告警条件表达式，返回true时清除告警，例如：msg.temperature <= 45
+	ClearCondition string
+	//AckCondition 确认告警条件表达式，返回true时确认告警，例如：msgType == 'ALARM_ACK'
+	AckCondition string
+	//Severity 严重程度表达式，返回CRITICAL、MAJOR、MINOR、WARNING或者INDETERMINATE，默认：'MAJOR'
+	//告警激活期间严重程度只升级不降级
+	Severity string
+}
+
+// AlarmNode 告警节点，按照告警发起者和告警类型维护告警状态，管理告警的创建、更新、确认和清除
+// 满足创建条件，如果没有激活的告警则创建告警，通过`Created`链路发送；否则更新告警，通过`Updated`链路发送
+// 满足确认条件，确认未确认的告警，通过`Updated`链路发送
+// 满足清除条件，清除激活的告警，通过`Cleared`链路发送
+// 否则通过`Unchanged`链路发送，如果存在告警则发送告警，否则发送原始消息
+// 发送的消息内容是告警JSON对象，元数据取自原始消息
+// 激活和未确认的告警保存在 types.Config.StateStore 中，key以规则链ID和节点ID作为前缀，告警清除并且确认后删除
+// 默认存储在进程内，不会淘汰，但是重启后丢失，需要跨重启或者多实例共享时通过 types.WithStateStore 替换
+// 存储中的告警丢失后，告警发起者下一次满足创建条件会创建新的告警，而不是更新或者清除原来的告警
+// 表达式执行失败则发送到`Failure`链
+type AlarmNode struct {
+	//节点配置
+	Config          AlarmNodeConfiguration
+	originator      *vm.Program
+	createCondition *vm.Program
+	clearCondition  *vm.Program
+	ackCondition    *vm.Program
+	severity        *vm.Program
+	//告警存储
+	store types.Cache
+	mu    sync.Mutex
+}
+
+// Type 组件类型
+func (x *AlarmNode) Type() string {
+	return "alarm"
+}
+
+func (x *AlarmNode) New() types.Node {
+	return &AlarmNode{Config: AlarmNodeConfiguration{
+		Originator:      "metadata.deviceId",
+		AlarmType:       "General Alarm",
+		CreateCondition: "msg.temperature > 50",
+		ClearCondition:  "msg.temperature <= 50",
+		Severity:        "'" + SeverityMajor + "'",
+	}}
+}
+
+// Init 初始化
+func (x *AlarmNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.AlarmType) == "" {
+		return errors.New("alarmType is empty")
+	}
+	if strings.TrimSpace(x.Config.CreateCondition) == "" {
+		return errors.New("createCondition is empty")
+	}
+	if strings.TrimSpace(x.Config.Severity) == "" {
+		x.Config.Severity = "'" + SeverityMajor + "'"
+	}
+	for _, item := range []struct {
+		name    string
+		script  string
+		program **vm.Program
+	}{
+		{"originator", x.Config.Originator, &x.originator},
+		{"createCondition", x.Config.CreateCondition, &x.createCondition},
+		{"clearCondition", x.Config.ClearCondition, &x.clearCondition},
+		{"ackCondition", x.Config.AckCondition, &x.ackCondition},
+		{"severity", x.Config.Severity, &x.severity},
+	} {
+		if strings.TrimSpace(item.script) == "" {
+			continue
+		}
+		if *item.program, err = expr.Compile(item.script, expr.AllowUndefinedVariables()); err != nil {
+			return errors.New(item.name + ": " + err.Error())
+		}
+	}
+	if x.originator == nil {
+		return errors.New("originator is empty")
+	}
+	if ruleConfig.StateStore != nil {
+		x.store = ruleConfig.StateStore
+	} else {
+		x.store = cache.NewMemoryCache(0)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *AlarmNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	evn := base.NodeUtils.GetEvn(ctx, msg)
+	out, err := vm.Run(x.originator, evn)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	originator := str.ToString(out)
+	alarmType := str.ExecuteTemplate(x.Config.AlarmType, base.NodeUtils.GetEvnAndMetadata(ctx, msg))
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	key := x.storeKey(ctx, originator, alarmType)
+	alarm := x.load(key)
+	active := alarm != nil && !alarm.Cleared
+	var relationType = types.Unchanged
+	if x.ackCondition != nil && alarm != nil && !alarm.Acknowledged {
+		if ok, err := x.match(x.ackCondition, evn); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		} else if ok {
+			alarm.Acknowledged = true
+			alarm.AckTs = msg.Ts
+			relationType = types.Updated
+		}
+	}
+	if relationType == types.Unchanged {
+		if ok, err := x.match(x.createCondition, evn); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		} else if ok {
+			severity, err := x.getSeverity(evn)
+			if err != nil {
+				ctx.TellFailure(msg, err)
+				return
+			}
+			if active {
+				//告警激活期间严重程度只升级不降级
+				if severityLevels[severity] > severityLevels[alarm.Severity] {
+					alarm.Severity = severity
+				}
+				relationType = types.Updated
+			} else {
+				id, _ := uuid.NewV4()
+				alarm = &Alarm{Id: id.String(), Originator: originator, Type: alarmType, Severity: severity, StartTs: msg.Ts}
+				relationType = types.Created
+			}
+			alarm.EndTs = msg.Ts
+			alarm.Details = mergeData(msg)
+		}
+	}
+	if relationType == types.Unchanged && active && x.clearCondition != nil {
+		if ok, err := x.match(x.clearCondition, evn); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		} else if ok {
+			alarm.Cleared = true
+			alarm.ClearTs = msg.Ts
+			relationType = types.Cleared
+		}
+	}
+
+	if alarm == nil {
+		ctx.TellNext(msg, relationType)
+		return
+	}
+	alarm.Status = alarm.status()
+	if relationType != types.Unchanged {
+		if err := x.save(key, alarm); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+	}
+	data, err := json.Marshal(alarm)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellNext(msg, relationType)
+}
+
+// Destroy 销毁
+func (x *AlarmNode) Destroy() {
+}
+
+// match 执行条件表达式
+func (x *AlarmNode) match(program *vm.Program, evn map[string]interface{}) (bool, error) {
+	out, err := vm.Run(program, evn)
+	if err != nil {
+		return false, err
+	}
+	ok, _ := out.(bool)
+	return ok, nil
+}
+
+// getSeverity 计算严重程度
+func (x *AlarmNode) getSeverity(evn map[string]interface{}) (string, error) {
+	out, err := vm.Run(x.severity, evn)
+	if err != nil {
+		return "", err
+	}
+	severity := strings.ToUpper(str.ToString(out))
+	if _, ok := severityLevels[severity]; !ok {
+		return "", errors.New("unsupported severity: " + severity)
+	}
+	return severity, nil
+}
+
+// storeKey 获取告警存储key，以规则链ID和节点ID作为前缀，避免不同规则链相同告警类型的告警冲突
+func (x *AlarmNode) storeKey(ctx types.RuleContext, originator, alarmType string) string {
+	var chainId string
+	if ctx.RuleChain() != nil {
+		chainId = ctx.RuleChain().GetNodeId().Id
+	}
+	return "alarm:" + chainId + ":" + ctx.GetSelfId() + ":" + originator + ":" + alarmType
+}
+
+// load 从存储中获取告警
+func (x *AlarmNode) load(key string) *Alarm {
+	v, ok := x.store.Get(key)
+	if !ok {
+		return nil
+	}
+	var alarm Alarm
+	if err := json.Unmarshal([]byte(str.ToString(v)), &alarm); err != nil {
+		return nil
+	}
+	return &alarm
+}
+
+// save 保存告警，已经清除并且确认的告警生命周期结束，从存储中删除
+func (x *AlarmNode) save(key string, alarm *Alarm) error {
+	if alarm.Cleared && alarm.Acknowledged {
+		return x.store.Delete(key)
+	}
+	data, err := json.Marshal(alarm)
+	if err != nil {
+		return err
+	}
+	return x.store.Set(key, string(data), 0)
+}
+
+// status 告警状态
+func (a *Alarm) status() string {
+	switch {
+	case a.Cleared && a.Acknowledged:
+		return AlarmClearedAck
+	case a.Cleared:
+		return AlarmClearedUnack
+	case a.Acknowledged:
+		return AlarmActiveAck
+	default:
+		return AlarmActiveUnack
+	}
+}
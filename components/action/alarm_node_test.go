@@ -0,0 +1,155 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/cache"
+	"github.com/rulego/rulego/utils/json"
+)
+
+func TestAlarmNode(t *testing.T) {
+	var targetNodeType = "alarm"
+	d1 := types.BuildMetadata(map[string]string{"deviceId": "d1", "productType": "sensor"})
+	//解析输出消息中的告警
+	alarmOf := func(item test.NodeResult) Alarm {
+		var alarm Alarm
+		_ = json.Unmarshal([]byte(item.Msg.Data), &alarm)
+		return alarm
+	}
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &AlarmNode{}, types.Configuration{
+			"originator":      "metadata.deviceId",
+			"alarmType":       "General Alarm",
+			"createCondition": "msg.temperature > 50",
+			"clearCondition":  "msg.temperature <= 50",
+			"severity":        "'MAJOR'",
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"createCondition": "",
+		}, Registry)
+		assert.Equal(t, "createCondition is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"alarmType": "",
+		}, Registry)
+		assert.Equal(t, "alarmType is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"originator": "",
+		}, Registry)
+		assert.Equal(t, "originator is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"clearCondition": "msg.temperature <",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"alarmType":       "${metadata.productType} High Temperature",
+			"createCondition": "msg.temperature > 50",
+			"clearCondition":  "msg.temperature <= 45",
+			"ackCondition":    "msgType == 'ALARM_ACK'",
+			"severity":        "msg.temperature > 80 ? 'CRITICAL' : 'MAJOR'",
+		}, Registry)
+		assert.Nil(t, err)
+		var msgList = []test.Msg{
+			{MetaData: d1, MsgType: "TELEMETRY", Data: "{\"temperature\":60}"},
+			{MetaData: d1, MsgType: "TELEMETRY", Data: "{\"temperature\":90}"},
+			{MetaData: d1, MsgType: "TELEMETRY", Data: "{\"temperature\":60}"},
+			{MetaData: d1, MsgType: "TELEMETRY", Data: "{\"temperature\":48}"},
+			{MetaData: d1, MsgType: "ALARM_ACK", Data: "{}"},
+			{MetaData: d1, MsgType: "TELEMETRY", Data: "{\"temperature\":40}"},
+			{MetaData: d1, MsgType: "TELEMETRY", Data: "{\"temperature\":40}"},
+			{MetaData: d1, MsgType: "TELEMETRY", Data: "{\"temperature\":55}"},
+			{MetaData: types.BuildMetadata(map[string]string{"deviceId": "d2"}), MsgType: "TELEMETRY", Data: "{\"temperature\":40}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 9, len(results))
+
+		var relationTypes []string
+		for _, item := range results {
+			relationTypes = append(relationTypes, item.RelationType)
+		}
+		assert.Equal(t, []string{types.Created, types.Updated, types.Updated, types.Unchanged, types.Updated,
+			types.Cleared, types.Unchanged, types.Created, types.Unchanged}, relationTypes)
+
+		created := alarmOf(results[0])
+		assert.Equal(t, "d1", created.Originator)
+		assert.Equal(t, "sensor High Temperature", created.Type)
+		assert.Equal(t, SeverityMajor, created.Severity)
+		assert.Equal(t, AlarmActiveUnack, created.Status)
+		assert.Equal(t, map[string]interface{}{"temperature": float64(60)}, created.Details)
+		assert.Equal(t, "sensor", results[0].Msg.Metadata.GetValue("productType"))
+		//严重程度升级
+		assert.Equal(t, created.Id, alarmOf(results[1]).Id)
+		assert.Equal(t, SeverityCritical, alarmOf(results[1]).Severity)
+		//不降级
+		assert.Equal(t, SeverityCritical, alarmOf(results[2]).Severity)
+		//没有满足任何条件，发送当前告警
+		assert.Equal(t, created.Id, alarmOf(results[3]).Id)
+		//确认
+		assert.Equal(t, AlarmActiveAck, alarmOf(results[4]).Status)
+		assert.True(t, alarmOf(results[4]).Acknowledged)
+		//清除
+		assert.Equal(t, AlarmClearedAck, alarmOf(results[5]).Status)
+		assert.True(t, alarmOf(results[5]).ClearTs > 0)
+		//告警生命周期结束，发送原始消息
+		assert.Equal(t, "{\"temperature\":40}", results[6].Msg.Data)
+		//新的告警
+		assert.True(t, alarmOf(results[7]).Id != created.Id)
+		assert.Equal(t, AlarmActiveUnack, alarmOf(results[7]).Status)
+		assert.Equal(t, "{\"temperature\":40}", results[8].Msg.Data)
+	})
+
+	t.Run("Store", func(t *testing.T) {
+		store := cache.NewMemoryCache(10)
+		config := types.NewConfig(types.WithStateStore(store))
+		node := test.InitNodeByConfig(config, targetNodeType, types.Configuration{}, Registry)
+		results := test.NewNodeCollector(node).OnMsg(node, test.Msg{
+			MetaData: types.BuildMetadata(map[string]string{"deviceId": "d9"}),
+			MsgType:  "TELEMETRY",
+			Data:     "{\"temperature\":60}",
+		}).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, types.Created, results[0].RelationType)
+		//以规则链ID和节点ID作为前缀，测试上下文没有规则链和节点ID
+		v, ok := store.Get("alarm:::d9:General Alarm")
+		assert.True(t, ok)
+		var alarm Alarm
+		_ = json.Unmarshal([]byte(v.(string)), &alarm)
+		assert.Equal(t, alarmOf(results[0]).Id, alarm.Id)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"severity": "'HIGH'",
+		}, Registry)
+		assert.Nil(t, err)
+		results := test.NewNodeCollector(node).OnMsg(node, test.Msg{MetaData: d1, MsgType: "TELEMETRY", Data: "{\"temperature\":60}"}).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, types.Failure, results[0].RelationType)
+		assert.Equal(t, "unsupported severity: HIGH", results[0].Err.Error())
+	})
+}
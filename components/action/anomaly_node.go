@@ -0,0 +1,354 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "anomaly",
+//        "name": "异常检测",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "metadata.deviceId",
+//          "fields": {"temperature": "msg.temperature"},
+//          "method": "ewma",
+//          "threshold": 3,
+//          "alpha": 0.1,
+//          "warmUp": 30
+//        }
+//  }
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+const (
+	// AnomalyEwma 使用指数加权移动平均的均值和方差计算z-score
+	AnomalyEwma = "ewma"
+	// AnomalyMad 使用滑动窗口的中位数和绝对中位差(MAD)计算稳健z-score
+	AnomalyMad = "mad"
+	// AnomalyIqr 使用滑动窗口的四分位距(IQR)计算超出上下四分位数的距离，单位IQR
+	AnomalyIqr = "iqr"
+)
+
+const (
+	// KeyAnomalyScore 异常检测元数据中所有字段的最大分数，单个字段的分数key为：anomalyScore.字段名
+	KeyAnomalyScore = "anomalyScore"
+	// KeyAnomalyFields 异常检测元数据中异常的字段，多个使用逗号分隔
+	KeyAnomalyFields = "anomalyFields"
+)
+
+// madScale MAD转换成标准差的系数
+const madScale = 0.6745
+
+// 注册节点
+func init() {
+	Registry.Add(&AnomalyNode{})
+}
+
+// AnomalyNodeConfiguration 节点配置
+type AnomalyNodeConfiguration struct {
+	//Key 分组key表达式，每个分组的每个字段单独统计，可以访问msg、metadata、id、type等变量，例如：metadata.deviceId
+	//为空则所有消息在同一个分组
+	Key string
+	//Fields 检测字段，key：字段名称，value：取值表达式，和exprFilter一样可以访问msg、metadata等变量，例如：msg.temperature
+	//表达式返回nil的字段不参与检测
+	Fields map[string]string `required:"true"`
+	//Method 统计方法：ewma(指数加权移动平均z-score)、mad(中位数/MAD稳健z-score)、iqr(四分位距)，默认ewma
+	Method string `enum:"ewma,mad,iqr"`
+	//Threshold 分数超过该值视为异常，默认ewma和mad为3，iqr为1.5
+	Threshold float64 `min:"0"`
+	//Alpha ewma方法的平滑系数，取值(0,1]，越大越关注最近的值，默认0.1
+	Alpha float64 `min:"0" max:"1"`
+	//WindowSize mad和iqr方法的滑动窗口大小，默认100
+	WindowSize int `min:"1"`
+	//WarmUp 预热样本数，每个字段统计的样本数达到该值后才开始检测，预热期间的消息都视为正常，默认30
+	WarmUp int `min:"0"`
+	//MaxKeys 最大分组数量，默认10000，超过后新分组的消息通过`Failure`链路路由到下一个节点
+	MaxKeys int `min:"1"`
+}
+
+// AnomalyNode 统计异常检测节点，按照分组和字段维护滚动统计，检测字段值是否超出阈值
+// 任意字段的分数超过阈值，通过`Anomaly`链路发送到下一个节点，否则通过`Normal`链路发送
+// 元数据增加anomalyScore(所有字段最大分数)、anomalyScore.字段名(字段分数)和anomalyFields(异常字段)
+// 先使用历史统计计算分数，再把当前值加入统计
+// 统计量为0(例如历史值都相同)时，与历史不同的值分数为+Inf
+// 表达式执行失败或者字段值不是数值则发送到`Failure`链
+type AnomalyNode struct {
+	//节点配置
+	Config AnomalyNodeConfiguration
+	//分组key表达式
+	key *vm.Program
+	//字段取值表达式
+	fields map[string]*vm.Program
+	//统计，key：分组key
+	groups map[string]map[string]*anomalyStats
+	mu     sync.Mutex
+}
+
+// anomalyStats 字段的滚动统计
+type anomalyStats struct {
+	count int
+	//ewma均值和方差
+	mean     float64
+	variance float64
+	//mad和iqr滑动窗口，环形缓冲区
+	window []float64
+	next   int
+}
+
+// Type 组件类型
+func (x *AnomalyNode) Type() string {
+	return "anomaly"
+}
+
+func (x *AnomalyNode) New() types.Node {
+	return &AnomalyNode{Config: AnomalyNodeConfiguration{
+		Fields:     map[string]string{"temperature": "msg.temperature"},
+		Method:     AnomalyEwma,
+		Threshold:  3,
+		Alpha:      0.1,
+		WindowSize: 100,
+		WarmUp:     30,
+		MaxKeys:    10000,
+	}}
+}
+
+// Init 初始化
+func (x *AnomalyNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	//map配置需要覆盖默认值，而不是合并，所以使用零值配置解析，再填充默认值
+	x.Config = AnomalyNodeConfiguration{WarmUp: 30}
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if len(x.Config.Fields) == 0 {
+		return errors.New("fields is empty")
+	}
+	switch x.Config.Method {
+	case "":
+		x.Config.Method = AnomalyEwma
+	case AnomalyEwma, AnomalyMad, AnomalyIqr:
+	default:
+		return fmt.Errorf("unsupported anomaly method: %s", x.Config.Method)
+	}
+	if x.Config.Threshold <= 0 {
+		if x.Config.Method == AnomalyIqr {
+			x.Config.Threshold = 1.5
+		} else {
+			x.Config.Threshold = 3
+		}
+	}
+	if x.Config.Alpha == 0 {
+		x.Config.Alpha = 0.1
+	}
+	if x.Config.Alpha < 0 || x.Config.Alpha > 1 {
+		return errors.New("alpha must be greater than 0 and less than or equal to 1")
+	}
+	if x.Config.WindowSize <= 0 {
+		x.Config.WindowSize = 100
+	}
+	if x.Config.MaxKeys <= 0 {
+		x.Config.MaxKeys = 10000
+	}
+	if x.Config.Key = strings.TrimSpace(x.Config.Key); x.Config.Key != "" {
+		if x.key, err = expr.Compile(x.Config.Key, expr.AllowUndefinedVariables()); err != nil {
+			return err
+		}
+	}
+	x.fields = make(map[string]*vm.Program, len(x.Config.Fields))
+	for name, fieldExpr := range x.Config.Fields {
+		if x.fields[name], err = expr.Compile(fieldExpr, expr.AllowUndefinedVariables()); err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+	x.groups = make(map[string]map[string]*anomalyStats)
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *AnomalyNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	evn := base.NodeUtils.GetEvn(ctx, msg)
+	var key string
+	if x.key != nil {
+		out, err := vm.Run(x.key, evn)
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		key = str.ToString(out)
+	}
+	values := make(map[string]float64, len(x.fields))
+	for name, program := range x.fields {
+		out, err := vm.Run(program, evn)
+		if err != nil {
+			ctx.TellFailure(msg, fmt.Errorf("field %s: %w", name, err))
+			return
+		}
+		if out == nil {
+			continue
+		}
+		v, err := toFloat64(out)
+		if err != nil {
+			ctx.TellFailure(msg, fmt.Errorf("field %s: %w", name, err))
+			return
+		}
+		values[name] = v
+	}
+
+	x.mu.Lock()
+	group, ok := x.groups[key]
+	if !ok {
+		if len(x.groups) >= x.Config.MaxKeys {
+			x.mu.Unlock()
+			ctx.TellFailure(msg, ErrMaxGroupsExceeded)
+			return
+		}
+		group = make(map[string]*anomalyStats, len(x.fields))
+		x.groups[key] = group
+	}
+	var maxScore float64
+	var anomalyFields []string
+	for name, v := range values {
+		stats, ok := group[name]
+		if !ok {
+			stats = &anomalyStats{}
+			group[name] = stats
+		}
+		score := x.score(stats, v)
+		if stats.count >= x.Config.WarmUp && score > x.Config.Threshold {
+			anomalyFields = append(anomalyFields, name)
+		}
+		x.update(stats, v)
+		if score > maxScore {
+			maxScore = score
+		}
+		msg.Metadata.PutValue(KeyAnomalyScore+"."+name, formatScore(score))
+	}
+	x.mu.Unlock()
+
+	msg.Metadata.PutValue(KeyAnomalyScore, formatScore(maxScore))
+	if len(anomalyFields) > 0 {
+		sort.Strings(anomalyFields)
+		msg.Metadata.PutValue(KeyAnomalyFields, strings.Join(anomalyFields, ","))
+		ctx.TellNext(msg, types.Anomaly)
+	} else {
+		ctx.TellNext(msg, types.Normal)
+	}
+}
+
+// Destroy 销毁
+func (x *AnomalyNode) Destroy() {
+}
+
+// score 使用历史统计计算当前值的分数
+func (x *AnomalyNode) score(stats *anomalyStats, v float64) float64 {
+	if stats.count == 0 {
+		return 0
+	}
+	switch x.Config.Method {
+	case AnomalyMad:
+		sorted := stats.sorted()
+		median := quantile(sorted, 0.5)
+		deviations := make([]float64, len(sorted))
+		for i, item := range sorted {
+			deviations[i] = math.Abs(item - median)
+		}
+		sort.Float64s(deviations)
+		return divide(madScale*math.Abs(v-median), quantile(deviations, 0.5))
+	case AnomalyIqr:
+		sorted := stats.sorted()
+		q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+		if v < q1 {
+			return divide(q1-v, q3-q1)
+		} else if v > q3 {
+			return divide(v-q3, q3-q1)
+		}
+		return 0
+	default:
+		return divide(math.Abs(v-stats.mean), math.Sqrt(stats.variance))
+	}
+}
+
+// update 把当前值加入统计
+func (x *AnomalyNode) update(stats *anomalyStats, v float64) {
+	stats.count++
+	if x.Config.Method == AnomalyEwma {
+		if stats.count == 1 {
+			stats.mean = v
+			return
+		}
+		diff := v - stats.mean
+		incr := x.Config.Alpha * diff
+		stats.mean += incr
+		stats.variance = (1 - x.Config.Alpha) * (stats.variance + diff*incr)
+		return
+	}
+	if len(stats.window) < x.Config.WindowSize {
+		stats.window = append(stats.window, v)
+	} else {
+		stats.window[stats.next] = v
+		stats.next = (stats.next + 1) % x.Config.WindowSize
+	}
+}
+
+// sorted 返回排序后的窗口数据
+func (s *anomalyStats) sorted() []float64 {
+	sorted := make([]float64, len(s.window))
+	copy(sorted, s.window)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// quantile 计算排序后数据的分位数，使用线性插值
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// divide 计算分数，除数为0时，被除数为0返回0，否则返回+Inf
+func divide(a, b float64) float64 {
+	if b == 0 {
+		if a == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return a / b
+}
+
+// formatScore 格式化分数
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', 4, 64)
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestAnomalyNode(t *testing.T) {
+	var targetNodeType = "anomaly"
+	aa := types.BuildMetadata(map[string]string{"deviceId": "aa"})
+	bb := types.BuildMetadata(map[string]string{"deviceId": "bb"})
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &AnomalyNode{}, types.Configuration{
+			"method":     AnomalyEwma,
+			"threshold":  float64(3),
+			"alpha":      0.1,
+			"windowSize": 100,
+			"warmUp":     30,
+			"maxKeys":    10000,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"fields": map[string]string{"humidity": "msg.humidity"},
+			"method": AnomalyIqr,
+			"warmUp": 0,
+		}, types.Configuration{
+			"method":     AnomalyIqr,
+			"threshold":  1.5,
+			"alpha":      0.1,
+			"windowSize": 100,
+			"warmUp":     0,
+			"maxKeys":    10000,
+		}, Registry)
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"fields": map[string]string{"humidity": "msg.humidity"},
+		}, Registry)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"humidity": "msg.humidity"}, node.(*AnomalyNode).Config.Fields)
+
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.Equal(t, "fields is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"fields": map[string]string{"temperature": "msg.temperature"},
+			"method": "sigma",
+		}, Registry)
+		assert.Equal(t, "unsupported anomaly method: sigma", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"fields": map[string]string{"temperature": "msg.temperature"},
+			"alpha":  2,
+		}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"fields": map[string]string{"temperature": "msg.temperature +"},
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	for _, method := range []string{AnomalyEwma, AnomalyMad, AnomalyIqr} {
+		t.Run(method, func(t *testing.T) {
+			node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+				"key":        "metadata.deviceId",
+				"fields":     map[string]string{"temperature": "msg.temperature", "humidity": "msg.humidity"},
+				"method":     method,
+				"alpha":      0.3,
+				"windowSize": 10,
+				"warmUp":     5,
+			}, Registry)
+			assert.Nil(t, err)
+			var msgList []test.Msg
+			for _, v := range []int{20, 22, 19, 21, 18, 23, 20, 60, 21} {
+				msgList = append(msgList, test.Msg{MetaData: aa, MsgType: "TELEMETRY", Data: fmt.Sprintf("{\"temperature\":%d,\"humidity\":50}", v)})
+			}
+			//不同分组单独统计
+			msgList = append(msgList, test.Msg{MetaData: bb, MsgType: "TELEMETRY", Data: "{\"temperature\":60}"})
+			results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+			assert.Equal(t, 10, len(results))
+			for i, item := range results {
+				if i == 7 {
+					assert.Equal(t, types.Anomaly, item.RelationType)
+					assert.Equal(t, "temperature", item.Msg.Metadata.GetValue(KeyAnomalyFields))
+					assert.Equal(t, item.Msg.Metadata.GetValue(KeyAnomalyScore), item.Msg.Metadata.GetValue(KeyAnomalyScore+".temperature"))
+					assert.Equal(t, "0.0000", item.Msg.Metadata.GetValue(KeyAnomalyScore+".humidity"))
+				} else {
+					assert.Equal(t, types.Normal, item.RelationType)
+					assert.False(t, item.Msg.Metadata.Has(KeyAnomalyFields))
+				}
+			}
+			assert.Equal(t, "0.0000", results[9].Msg.Metadata.GetValue(KeyAnomalyScore))
+		})
+	}
+
+	t.Run("WarmUp", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"fields": map[string]string{"temperature": "msg.temperature"},
+			"warmUp": 10,
+		}, Registry)
+		assert.Nil(t, err)
+		var msgList = []test.Msg{
+			{MetaData: aa, MsgType: "TELEMETRY", Data: "{\"temperature\":20}"},
+			{MetaData: aa, MsgType: "TELEMETRY", Data: "{\"temperature\":21}"},
+			{MetaData: aa, MsgType: "TELEMETRY", Data: "{\"temperature\":60}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, types.Normal, results[2].RelationType)
+		assert.Equal(t, "+Inf", results[1].Msg.Metadata.GetValue(KeyAnomalyScore))
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"fields":  map[string]string{"temperature": "msg.temperature"},
+			"key":     "metadata.deviceId",
+			"maxKeys": 1,
+		}, Registry)
+		assert.Nil(t, err)
+		var msgList = []test.Msg{
+			{MetaData: aa, MsgType: "TELEMETRY", Data: "{\"temperature\":\"high\"}"},
+			{MetaData: aa, MsgType: "TELEMETRY", Data: "{\"temperature\":20}"},
+			{MetaData: bb, MsgType: "TELEMETRY", Data: "{\"temperature\":20}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, types.Failure, results[0].RelationType)
+		assert.Equal(t, types.Normal, results[1].RelationType)
+		assert.Equal(t, types.Failure, results[2].RelationType)
+		assert.Equal(t, ErrMaxGroupsExceeded, results[2].Err)
+	})
+}
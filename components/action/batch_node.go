@@ -0,0 +1,332 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "batch",
+//        "name": "批量写入",
+//        "debugMode": false,
+//        "configuration": {
+//          "groupBy": "metadata.table",
+//          "batchSize": 100,
+//          "maxBytes": 1048576,
+//          "lingerMs": 1000,
+//          "maxPendingMsgs": 10000,
+//          "overflowPolicy": "reject"
+//        }
+//  }
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+const (
+	// OverflowReject 挂起消息达到上限后，新消息通过`Failure`链路路由到下一个节点
+	OverflowReject = "reject"
+	// OverflowFlush 挂起消息达到上限后，立即发送最早的批次，再接收新消息
+	OverflowFlush = "flush"
+)
+
+const (
+	// KeyBatchSize 批量消息元数据中的消息数量
+	KeyBatchSize = "batchSize"
+	// KeyBatchStart 批量消息元数据中最早的消息时间，毫秒时间戳
+	KeyBatchStart = "batchStart"
+	// KeyBatchEnd 批量消息元数据中最晚的消息时间，毫秒时间戳
+	KeyBatchEnd = "batchEnd"
+)
+
+var (
+	// ErrBatchOverflow 挂起的消息数量达到上限
+	ErrBatchOverflow = errors.New("max limit of pending messages")
+	// ErrBatchDrained 批量节点已经排空，不再接收消息
+	ErrBatchDrained = errors.New("batch node is drained")
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&BatchNode{})
+}
+
+// BatchNodeConfiguration 节点配置
+type BatchNodeConfiguration struct {
+	//GroupBy 分组key表达式，可以访问msg、metadata、id、type等变量，例如：metadata.table
+	//为空则所有消息在同一个批次
+	GroupBy string
+	//BatchSize 批次消息数量达到该值时发送，默认100
+	BatchSize int `min:"1"`
+	//MaxBytes 批次消息Data的总字节数达到该值时发送，0表示不限制
+	MaxBytes int `min:"0"`
+	//LingerMs 批次第一条消息到达后，最长等待时间，单位毫秒，到期后发送，默认1000
+	LingerMs int64 `min:"1"`
+	//MaxPendingMsgs 所有批次最大挂起的消息数量，默认10000
+	MaxPendingMsgs int `min:"1"`
+	//OverflowPolicy 挂起消息达到上限的处理策略：reject(拒绝新消息)、flush(立即发送最早的批次)，默认reject
+	OverflowPolicy string `enum:"reject,flush"`
+}
+
+// BatchNode 批量节点，把消息按照分组收集成批次，批次消息数量、字节数或者等待时间达到阈值时，
+// 通过`Success`链路发送一条批量消息，消息内容是所有消息Data组成的JSON数组，JSON类型的Data作为JSON对象，其他作为字符串，例如：
+// [{"temperature":20},{"temperature":30}]
+// 批量消息的类型和元数据取自批次最后一条消息，并在元数据增加groupKey、batchSize、batchStart和batchEnd
+// 批次中的消息在该节点结束，批次第一条消息的处理会挂起，直到使用它发送批量消息
+// 节点销毁或者优雅停机时，发送所有挂起的批次
+type BatchNode struct {
+	//节点配置
+	Config BatchNodeConfiguration
+	//分组key表达式
+	groupBy *vm.Program
+	//分组批次，key：分组key
+	batches map[string]*msgBatch
+	//挂起的消息数量
+	pendingMsgs int
+	//是否已经排空
+	drained int32
+	mu      sync.Mutex
+}
+
+// msgBatch 批次
+type msgBatch struct {
+	key  string
+	msgs []types.RuleMsg
+	//批次消息Data字节数
+	bytes int
+	//批次开始时间
+	createdAt time.Time
+	//挂起的消息上下文，发送批次时使用它发送批量消息
+	pending types.RuleContext
+	//等待时间定时器
+	timer *time.Timer
+}
+
+// 确保BatchNode实现了types.DrainableNode接口
+var _ types.DrainableNode = (*BatchNode)(nil)
+
+// Type 组件类型
+func (x *BatchNode) Type() string {
+	return "batch"
+}
+
+func (x *BatchNode) New() types.Node {
+	return &BatchNode{Config: BatchNodeConfiguration{
+		BatchSize:      100,
+		LingerMs:       1000,
+		MaxPendingMsgs: 10000,
+		OverflowPolicy: OverflowReject,
+	}}
+}
+
+// Init 初始化
+func (x *BatchNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if x.Config.BatchSize <= 0 {
+		x.Config.BatchSize = 100
+	}
+	if x.Config.LingerMs <= 0 {
+		x.Config.LingerMs = 1000
+	}
+	if x.Config.MaxPendingMsgs <= 0 {
+		x.Config.MaxPendingMsgs = 10000
+	}
+	if x.Config.OverflowPolicy == "" {
+		x.Config.OverflowPolicy = OverflowReject
+	}
+	if x.Config.OverflowPolicy != OverflowReject && x.Config.OverflowPolicy != OverflowFlush {
+		return fmt.Errorf("unsupported overflow policy: %s", x.Config.OverflowPolicy)
+	}
+	if x.Config.GroupBy != "" {
+		if x.groupBy, err = expr.Compile(x.Config.GroupBy, expr.AllowUndefinedVariables()); err != nil {
+			return err
+		}
+	}
+	x.batches = make(map[string]*msgBatch)
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *BatchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	if atomic.LoadInt32(&x.drained) == 1 {
+		ctx.TellFailure(msg, ErrBatchDrained)
+		return
+	}
+	var key string
+	if x.groupBy != nil {
+		out, err := vm.Run(x.groupBy, base.NodeUtils.GetEvn(ctx, msg))
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		key = str.ToString(out)
+	}
+
+	var flushed []*msgBatch
+	x.mu.Lock()
+	if x.pendingMsgs >= x.Config.MaxPendingMsgs {
+		if x.Config.OverflowPolicy == OverflowReject {
+			x.mu.Unlock()
+			ctx.TellFailure(msg, ErrBatchOverflow)
+			return
+		}
+		if oldest := x.oldest(); oldest != nil {
+			flushed = append(flushed, x.remove(oldest))
+		}
+	}
+	batch, ok := x.batches[key]
+	if !ok {
+		batch = &msgBatch{key: key, createdAt: time.Now(), pending: ctx}
+		batch.timer = time.AfterFunc(time.Duration(x.Config.LingerMs)*time.Millisecond, func() {
+			x.flushBatch(batch)
+		})
+		x.batches[key] = batch
+	}
+	batch.msgs = append(batch.msgs, msg)
+	batch.bytes += len(msg.Data)
+	x.pendingMsgs++
+	hold := batch.pending == ctx
+	if len(batch.msgs) >= x.Config.BatchSize || (x.Config.MaxBytes > 0 && batch.bytes >= x.Config.MaxBytes) {
+		flushed = append(flushed, x.remove(batch))
+	}
+	x.mu.Unlock()
+
+	if !hold {
+		//消息已经加入批次，结束该消息的处理
+		ctx.DoOnEnd(msg, nil, types.Success)
+	}
+	x.emit(flushed, nil)
+}
+
+// Drain 优雅停机时立即发送所有挂起的批次
+// 如果persist不为空，批量消息交给persist持久化
+func (x *BatchNode) Drain(persist func(msg types.RuleMsg)) {
+	x.mu.Lock()
+	atomic.StoreInt32(&x.drained, 1)
+	flushed := x.removeAll()
+	x.mu.Unlock()
+	x.emit(flushed, persist)
+}
+
+// Destroy 销毁，发送所有挂起的批次
+func (x *BatchNode) Destroy() {
+	x.mu.Lock()
+	flushed := x.removeAll()
+	x.mu.Unlock()
+	x.emit(flushed, nil)
+}
+
+// flushBatch 等待时间到期，发送批次
+func (x *BatchNode) flushBatch(batch *msgBatch) {
+	x.mu.Lock()
+	if x.batches[batch.key] != batch {
+		//批次已经发送
+		x.mu.Unlock()
+		return
+	}
+	x.remove(batch)
+	x.mu.Unlock()
+	x.emit([]*msgBatch{batch}, nil)
+}
+
+// oldest 返回最早的批次
+func (x *BatchNode) oldest() *msgBatch {
+	var oldest *msgBatch
+	for _, batch := range x.batches {
+		if oldest == nil || batch.createdAt.Before(oldest.createdAt) {
+			oldest = batch
+		}
+	}
+	return oldest
+}
+
+// remove 移除批次，调用方需要持有锁
+func (x *BatchNode) remove(batch *msgBatch) *msgBatch {
+	batch.timer.Stop()
+	delete(x.batches, batch.key)
+	x.pendingMsgs -= len(batch.msgs)
+	return batch
+}
+
+// removeAll 移除所有批次，调用方需要持有锁
+func (x *BatchNode) removeAll() []*msgBatch {
+	var flushed []*msgBatch
+	for _, batch := range x.batches {
+		flushed = append(flushed, x.remove(batch))
+	}
+	return flushed
+}
+
+// emit 发送批量消息
+func (x *BatchNode) emit(batches []*msgBatch, persist func(msg types.RuleMsg)) {
+	for _, batch := range batches {
+		msg := batch.msg()
+		if persist != nil {
+			persist(msg)
+			batch.pending.DoOnEnd(msg, nil, types.Success)
+		} else {
+			batch.pending.TellSuccess(msg)
+		}
+	}
+}
+
+// msg 生成批量消息
+func (b *msgBatch) msg() types.RuleMsg {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	start, end := b.msgs[0].Ts, b.msgs[0].Ts
+	for i, msg := range b.msgs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if msg.DataType == types.JSON && json.Valid([]byte(msg.Data)) {
+			buf.WriteString(msg.Data)
+		} else {
+			v, _ := json.Marshal(msg.Data)
+			buf.Write(v)
+		}
+		if msg.Ts < start {
+			start = msg.Ts
+		}
+		if msg.Ts > end {
+			end = msg.Ts
+		}
+	}
+	buf.WriteByte(']')
+	last := b.msgs[len(b.msgs)-1]
+	metadata := last.Metadata.Copy()
+	metadata.PutValue(KeyGroupKey, b.key)
+	metadata.PutValue(KeyBatchSize, strconv.Itoa(len(b.msgs)))
+	metadata.PutValue(KeyBatchStart, strconv.FormatInt(start, 10))
+	metadata.PutValue(KeyBatchEnd, strconv.FormatInt(end, 10))
+	return types.NewMsg(0, last.Type, types.JSON, metadata, buf.String())
+}
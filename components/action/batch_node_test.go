@@ -0,0 +1,181 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestBatchNode(t *testing.T) {
+	var targetNodeType = "batch"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &BatchNode{}, types.Configuration{
+			"batchSize":      100,
+			"lingerMs":       int64(1000),
+			"maxPendingMsgs": 10000,
+			"overflowPolicy": OverflowReject,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"groupBy":        "metadata.table",
+			"batchSize":      10,
+			"maxBytes":       1024,
+			"overflowPolicy": OverflowFlush,
+		}, types.Configuration{
+			"groupBy":        "metadata.table",
+			"batchSize":      10,
+			"maxBytes":       1024,
+			"lingerMs":       int64(1000),
+			"overflowPolicy": OverflowFlush,
+		}, Registry)
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"overflowPolicy": "drop",
+		}, Registry)
+		assert.Equal(t, "unsupported overflow policy: drop", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"groupBy": "metadata.table +",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("BatchSizeAndLinger", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"groupBy":   "metadata.table",
+			"batchSize": 2,
+			"lingerMs":  300,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		t1 := types.BuildMetadata(map[string]string{"table": "t1"})
+		t2 := types.BuildMetadata(map[string]string{"table": "t2"})
+		var msgList = []test.Msg{
+			{Ts: 1000, MetaData: t1, MsgType: "INSERT", Data: "{\"id\":1}"},
+			{Ts: 1001, MetaData: t2, MsgType: "INSERT", Data: "{\"id\":2}"},
+			{Ts: 1002, MetaData: t1, MsgType: "INSERT", Data: "{\"id\":3}"},
+			{Ts: 1003, MetaData: t1, MsgType: "INSERT", Data: "abc", DataType: types.TEXT},
+		}
+		collector := test.NewNodeCollector(node).OnMsg(node, msgList...)
+		//达到批量大小立即发送
+		results := collector.Results()
+		assert.Equal(t, 1, len(results))
+		msg := results[0].Msg
+		assert.Equal(t, types.Success, results[0].RelationType)
+		assert.Equal(t, "[{\"id\":1},{\"id\":3}]", msg.Data)
+		assert.Equal(t, "t1", msg.Metadata.GetValue(KeyGroupKey))
+		assert.Equal(t, "2", msg.Metadata.GetValue(KeyBatchSize))
+		assert.Equal(t, "1000", msg.Metadata.GetValue(KeyBatchStart))
+		assert.Equal(t, "1002", msg.Metadata.GetValue(KeyBatchEnd))
+		assert.Equal(t, types.JSON, msg.DataType)
+		assert.Equal(t, "INSERT", msg.Type)
+		//等待时间到期发送
+		results = collector.Wait(t, 3)
+		assert.Equal(t, 3, len(results))
+		lingered := map[string]string{}
+		for _, item := range results[1:] {
+			assert.Equal(t, types.Success, item.RelationType)
+			lingered[item.Msg.Metadata.GetValue(KeyGroupKey)] = item.Msg.Data
+		}
+		assert.Equal(t, map[string]string{"t1": "[\"abc\"]", "t2": "[{\"id\":2}]"}, lingered)
+	})
+
+	t.Run("MaxBytes", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"maxBytes": 16,
+			"lingerMs": 60000,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{MsgType: "INSERT", Data: "{\"id\":100}"},
+			{MsgType: "INSERT", Data: "{\"id\":200}"},
+			{MsgType: "INSERT", Data: "{\"id\":300}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "[{\"id\":100},{\"id\":200}]", results[0].Msg.Data)
+	})
+
+	t.Run("OverflowReject", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"maxPendingMsgs": 2,
+			"lingerMs":       60000,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{MsgType: "INSERT", Data: "{\"id\":1}"},
+			{MsgType: "INSERT", Data: "{\"id\":2}"},
+			{MsgType: "INSERT", Data: "{\"id\":3}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, ErrBatchOverflow, results[0].Err)
+	})
+
+	t.Run("OverflowFlushAndDestroy", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"groupBy":        "msg.id",
+			"maxPendingMsgs": 2,
+			"overflowPolicy": OverflowFlush,
+			"lingerMs":       60000,
+		}, Registry)
+		assert.Nil(t, err)
+		var msgList = []test.Msg{
+			{MsgType: "INSERT", Data: "{\"id\":1}"},
+			{MsgType: "INSERT", Data: "{\"id\":2}"},
+			{MsgType: "INSERT", Data: "{\"id\":3}"},
+		}
+		collector := test.NewNodeCollector(node).OnMsg(node, msgList...)
+		results := collector.Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "[{\"id\":1}]", results[0].Msg.Data)
+
+		node.Destroy()
+		assert.Equal(t, 3, len(collector.Results()))
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"lingerMs": 60000,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{MsgType: "INSERT", Data: "{\"id\":1}"},
+		}
+		collector := test.NewNodeCollector(node).OnMsg(node, msgList...)
+		assert.Equal(t, 0, len(collector.Results()))
+
+		var persisted []types.RuleMsg
+		node.(types.DrainableNode).Drain(func(msg types.RuleMsg) {
+			persisted = append(persisted, msg)
+		})
+		assert.Equal(t, 1, len(persisted))
+		assert.Equal(t, "[{\"id\":1}]", persisted[0].Data)
+
+		results := collector.OnMsg(node, msgList...).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, ErrBatchDrained, results[0].Err)
+	})
+}
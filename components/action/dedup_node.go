@@ -0,0 +1,149 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "dedup",
+//        "name": "消息去重",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "metadata.deviceId + ':' + string(msg.seq)",
+//          "windowInSeconds": 60,
+//          "drop": false
+//        }
+//  }
+import (
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/cache"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+// DefaultDedupWindowInSeconds 默认去重时间窗口，单位秒
+const DefaultDedupWindowInSeconds = 60
+
+// 注册节点
+func init() {
+	Registry.Add(&DedupNode{})
+}
+
+// DedupNodeConfiguration 节点配置
+type DedupNodeConfiguration struct {
+	//去重key表达式，可以访问msg、metadata、id、type等变量，例如：metadata.deviceId + ':' + string(msg.seq)
+	//为空则使用消息ID(RuleMsg.Id)
+	Key string
+	//去重时间窗口，单位秒。窗口内相同key的消息视为重复消息
+	//<=0则使用默认值 DefaultDedupWindowInSeconds，去重记录总会过期，避免存储无限增长
+	WindowInSeconds int
+	//是否丢弃重复消息
+	//true：重复消息直接结束，不会路由到任何节点
+	//false：重复消息通过`Duplicate`链路路由到下一个节点
+	Drop bool
+}
+
+// DedupNode 消息去重节点
+// 时间窗口内首次出现的消息通过`Success`链路路由到下一个节点，重复消息通过`Duplicate`链路路由或者丢弃。
+// 如果key表达式执行失败则发送到`Failure`链。
+// 去重记录保存在 types.Config.Cache 中，默认是进程内的LRU缓存，可以通过 types.WithCache 替换为分布式缓存实现多实例去重。
+type DedupNode struct {
+	//节点配置
+	Config DedupNodeConfiguration
+	//key表达式
+	program *vm.Program
+	//去重记录存储
+	cache types.Cache
+}
+
+// Type 组件类型
+func (x *DedupNode) Type() string {
+	return "dedup"
+}
+
+func (x *DedupNode) New() types.Node {
+	return &DedupNode{Config: DedupNodeConfiguration{
+		WindowInSeconds: DefaultDedupWindowInSeconds,
+	}}
+}
+
+// Init 初始化
+func (x *DedupNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if x.Config.WindowInSeconds <= 0 {
+		x.Config.WindowInSeconds = DefaultDedupWindowInSeconds
+	}
+	if x.Config.Key != "" {
+		if x.program, err = expr.Compile(x.Config.Key, expr.AllowUndefinedVariables()); err != nil {
+			return err
+		}
+	}
+	if ruleConfig.Cache != nil {
+		x.cache = ruleConfig.Cache
+	} else {
+		x.cache = cache.NewMemoryCache(cache.DefaultMaxSize)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *DedupNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	key, err := x.getKey(ctx, msg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	first, err := x.cache.SetIfAbsent(key, true, time.Duration(x.Config.WindowInSeconds)*time.Second)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else if first {
+		ctx.TellSuccess(msg)
+	} else if x.Config.Drop {
+		ctx.DoOnEnd(msg, nil, types.Duplicate)
+	} else {
+		ctx.TellNext(msg, types.Duplicate)
+	}
+}
+
+// Destroy 销毁
+func (x *DedupNode) Destroy() {
+}
+
+// getKey 获取去重key，以规则链ID和节点ID作为前缀，避免不同节点之间冲突
+func (x *DedupNode) getKey(ctx types.RuleContext, msg types.RuleMsg) (string, error) {
+	var key = msg.Id
+	if x.program != nil {
+		out, err := vm.Run(x.program, base.NodeUtils.GetEvn(ctx, msg))
+		if err != nil {
+			return "", err
+		}
+		key = str.ToString(out)
+	}
+	var chainId string
+	if ctx.RuleChain() != nil {
+		chainId = ctx.RuleChain().GetNodeId().Id
+	}
+	return "dedup:" + chainId + ":" + ctx.GetSelfId() + ":" + key, nil
+}
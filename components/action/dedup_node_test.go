@@ -0,0 +1,111 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestDedupNode(t *testing.T) {
+
+	var targetNodeType = "dedup"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &DedupNode{}, types.Configuration{
+			"windowInSeconds": 60,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"key":             "metadata.deviceId",
+			"windowInSeconds": 10,
+			"drop":            true,
+		}, types.Configuration{
+			"key":             "metadata.deviceId",
+			"windowInSeconds": 10,
+			"drop":            true,
+		}, Registry)
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key": "metadata.deviceId +",
+		}, Registry)
+		assert.NotNil(t, err)
+		//0使用默认窗口，去重记录不会永久保存
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"windowInSeconds": 0,
+		}, Registry)
+		assert.Nil(t, err)
+		assert.Equal(t, DefaultDedupWindowInSeconds, node.(*DedupNode).Config.WindowInSeconds)
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"windowInSeconds": 1,
+		}, Registry)
+		assert.Nil(t, err)
+		metaData := types.NewMetadata()
+		//相同消息ID，窗口内重复，窗口过期后不再视为重复
+		var msgList = []test.Msg{
+			{Id: "id1", MetaData: metaData, MsgType: "ACTIVITY_EVENT", Data: "AA", AfterSleep: time.Millisecond * 100},
+			{Id: "id1", MetaData: metaData, MsgType: "ACTIVITY_EVENT", Data: "AA", AfterSleep: time.Millisecond * 100},
+			{Id: "id2", MetaData: metaData, MsgType: "ACTIVITY_EVENT", Data: "BB", AfterSleep: time.Millisecond * 1100},
+			{Id: "id1", MetaData: metaData, MsgType: "ACTIVITY_EVENT", Data: "AA", AfterSleep: time.Millisecond * 100},
+		}
+		var relations []string
+		var lock sync.Mutex
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err2 error) {
+			lock.Lock()
+			defer lock.Unlock()
+			relations = append(relations, msg.Data+":"+relationType)
+		})
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Equal(t, []string{"AA:Success", "AA:Duplicate", "BB:Success", "AA:Success"}, relations)
+	})
+
+	t.Run("KeyAndDrop", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":  "metadata.deviceId + ':' + string(msg.seq)",
+			"drop": true,
+		}, Registry)
+		assert.Nil(t, err)
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", "aa")
+		var msgList = []test.Msg{
+			{MetaData: metaData, MsgType: "ACTIVITY_EVENT", Data: "{\"seq\":1}", AfterSleep: time.Millisecond * 100},
+			{MetaData: metaData, MsgType: "ACTIVITY_EVENT", Data: "{\"seq\":1}", AfterSleep: time.Millisecond * 100},
+			{MetaData: metaData, MsgType: "ACTIVITY_EVENT", Data: "{\"seq\":2}", AfterSleep: time.Millisecond * 100},
+		}
+		var relations []string
+		var lock sync.Mutex
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err2 error) {
+			lock.Lock()
+			defer lock.Unlock()
+			relations = append(relations, msg.Data+":"+relationType)
+		})
+		lock.Lock()
+		defer lock.Unlock()
+		//重复消息被丢弃
+		assert.Equal(t, []string{"{\"seq\":1}:Success", "{\"seq\":2}:Success"}, relations)
+	})
+}
@@ -48,11 +48,11 @@ func init() {
 // DelayNodeConfiguration 节点配置
 type DelayNodeConfiguration struct {
 	//最大允许挂起消息的数量
-	MaxPendingMsgs int
+	MaxPendingMsgs int `label:"Max pending messages" min:"1" i18n:"delay.maxPendingMsgs"`
 	//延迟时间，单位秒
-	PeriodInSeconds int
+	PeriodInSeconds int `label:"Delay(seconds)" min:"0" i18n:"delay.periodInSeconds"`
 	//通过 ${metadata.key} 从元数据变量中获取或者通过 ${msg.key} 从消息负荷中获取，延迟时间，如果该值有值，优先取该值。
-	PeriodInSecondsPattern string
+	PeriodInSecondsPattern string `label:"Delay pattern" desc:"overrides the delay, supports ${metadata.key} and ${msg.key}" i18n:"delay.periodInSecondsPattern"`
 	//是否覆盖周期内的消息
 	//true：周期内只保留一条消息，新的消息会覆盖之前的消息。直到队列里的消息被处理后，才会再次进入延迟队列。
 	//false：周期内保留所有消息，直到达到最大挂起消息限制后，才会进入失败链路。
@@ -70,10 +70,19 @@ type DelayNode struct {
 	PendingMsgs map[string]types.RuleMsg
 	//上一条pending msg id
 	LastPendingMsgId atomic.Value
+	//挂起消息对应的上下文，用于优雅停机时立即发送挂起的消息
+	pendingCtx map[string]types.RuleContext
+	//是否已经排空，排空后不再挂起消息
+	drained int32
+	//排空时挂起消息的持久化函数
+	persist func(msg types.RuleMsg)
 	//锁
 	mu sync.Mutex
 }
 
+// 确保DelayNode实现了types.DrainableNode接口
+var _ types.DrainableNode = (*DelayNode)(nil)
+
 // Type 组件类型
 func (x *DelayNode) Type() string {
 	return "delay"
@@ -86,6 +95,7 @@ func (x *DelayNode) New() types.Node {
 // Init 初始化
 func (x *DelayNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	x.PendingMsgs = make(map[string]types.RuleMsg)
+	x.pendingCtx = make(map[string]types.RuleContext)
 	err := maps.Map2Struct(configuration, &x.Config)
 	if x.Config.MaxPendingMsgs <= 0 {
 		x.Config.MaxPendingMsgs = 1000
@@ -108,11 +118,18 @@ func (x *DelayNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 			}
 
 			delete(x.PendingMsgs, msg.Id)
+			delete(x.pendingCtx, msg.Id)
 			ctx.TellSuccess(pendingMsg)
+		} else if atomic.LoadInt32(&x.drained) == 1 {
+			//优雅停机时已经排空
+			return
 		} else {
 			ctx.TellFailure(msg, fmt.Errorf("msg not found"))
 		}
 
+	} else if atomic.LoadInt32(&x.drained) == 1 {
+		//已经排空，不再挂起消息
+		x.release(ctx, msg, x.persist)
 	} else if oldMsgId := x.LastPendingMsgId.Load().(string); oldMsgId != "" {
 		//如果是覆盖模式，替换队列里的消息
 		x.mu.Lock()
@@ -142,6 +159,7 @@ func (x *DelayNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 			}
 			x.mu.Lock()
 			x.PendingMsgs[msg.Id] = msg
+			x.pendingCtx[msg.Id] = ctx
 			x.mu.Unlock()
 
 			ackMsg := msg.Copy()
@@ -154,6 +172,36 @@ func (x *DelayNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 
 }
 
+// Drain 优雅停机时排空挂起的消息
+// 如果persist为空，挂起的消息不再等待延迟到期，立即通过成功链路发送到下一个节点；否则交给persist持久化
+func (x *DelayNode) Drain(persist func(msg types.RuleMsg)) {
+	x.mu.Lock()
+	x.persist = persist
+	atomic.StoreInt32(&x.drained, 1)
+	var msgs = x.PendingMsgs
+	var ctxs = x.pendingCtx
+	x.PendingMsgs = make(map[string]types.RuleMsg)
+	x.pendingCtx = make(map[string]types.RuleContext)
+	x.LastPendingMsgId.Store("")
+	x.mu.Unlock()
+
+	for id, msg := range msgs {
+		if ctx, ok := ctxs[id]; ok {
+			x.release(ctx, msg, persist)
+		}
+	}
+}
+
+// release 立即发送消息到下一个节点，或者持久化后结束该消息的处理
+func (x *DelayNode) release(ctx types.RuleContext, msg types.RuleMsg, persist func(msg types.RuleMsg)) {
+	if persist != nil {
+		persist(msg)
+		ctx.DoOnEnd(msg, nil, types.Success)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+}
+
 // Destroy 销毁
 func (x *DelayNode) Destroy() {
 }
@@ -0,0 +1,296 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "fsm",
+//        "name": "设备生命周期",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "metadata.deviceId",
+//          "states": ["provisioning", "active", "maintenance", "decommissioned"],
+//          "initialState": "provisioning",
+//          "events": [
+//            {"name": "activate", "condition": "msgType == 'ACTIVATE'"},
+//            {"name": "repair", "condition": "msgType == 'FAULT'"},
+//            {"name": "repaired", "condition": "msgType == 'REPAIRED'"},
+//            {"name": "retire", "condition": "msgType == 'RETIRE'"}
+//          ],
+//          "transitions": [
+//            {"from": "provisioning", "event": "activate", "to": "active"},
+//            {"from": "active", "event": "repair", "to": "maintenance", "guard": "msg.level > 1"},
+//            {"from": "maintenance", "event": "repaired", "to": "active"},
+//            {"from": "*", "event": "retire", "to": "decommissioned"}
+//          ]
+//        }
+//  }
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/cache"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+const (
+	// KeyPreviousState 状态机转换前的状态
+	KeyPreviousState = "previousState"
+	// KeyCurrentState 状态机当前状态
+	KeyCurrentState = "currentState"
+	// KeyFsmEvent 触发状态转换的事件
+	KeyFsmEvent = "fsmEvent"
+	// AnyState 匹配任意状态的转换来源
+	AnyState = "*"
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&FsmNode{})
+}
+
+// 确保FsmNode实现了types.ComponentDefGetter接口
+var _ types.ComponentDefGetter = (*FsmNode)(nil)
+
+// FsmEvent 状态机事件
+type FsmEvent struct {
+	//Name 事件名称
+	Name string
+	//Condition 事件条件表达式，可以访问msg、metadata、id、type等变量，返回true表示触发该事件，例如：msgType == 'ACTIVATE'
+	Condition string
+}
+
+// FsmTransition 状态转换
+type FsmTransition struct {
+	//From 转换前的状态，*表示任意状态
+	From string
+	//Event 触发转换的事件名称
+	Event string
+	//To 转换后的状态
+	To string
+	//Guard 转换守卫条件表达式，可选，返回true才允许转换，例如：msg.level > 1
+	Guard string
+}
+
+// FsmNodeConfiguration 节点配置
+type FsmNodeConfiguration struct {
+	//Key 实体key表达式，每个实体维护独立的状态，可以访问msg、metadata、id、type等变量，默认：metadata.deviceId
+	Key string
+	//States 所有状态
+	States []string `required:"true"`
+	//InitialState 实体的初始状态
+	InitialState string `required:"true"`
+	//Events 事件列表，按照顺序检查事件条件
+	Events []FsmEvent
+	//Transitions 状态转换列表
+	Transitions []FsmTransition
+}
+
+// FsmNode 有限状态机节点，按照实体key维护当前状态，根据消息触发的事件、守卫条件和状态转换规则转换状态
+// 按照顺序检查事件条件，第一个满足条件并且存在可以执行的状态转换(当前状态匹配、守卫条件通过)的事件触发转换
+// 状态转换后，通过以转换后的状态命名的链路发送到下一个节点，例如：`active`，元数据增加previousState、currentState和fsmEvent
+// 没有发生状态转换，通过`Unchanged`链路发送到下一个节点，元数据增加currentState
+// 每个实体的当前状态保存在 types.Config.StateStore 中，默认存储在进程内，不会淘汰，但是重启后丢失，可以通过 types.WithStateStore 替换为持久化或者分布式存储
+// 状态丢失的实体会从初始状态重新开始转换
+// 表达式执行失败则发送到`Failure`链
+type FsmNode struct {
+	//节点配置
+	Config FsmNodeConfiguration
+	//实体key表达式
+	key *vm.Program
+	//事件条件表达式，和Config.Events一一对应
+	events []*vm.Program
+	//转换守卫条件表达式，和Config.Transitions一一对应
+	guards []*vm.Program
+	//状态存储
+	store types.Cache
+	mu    sync.Mutex
+}
+
+// Type 组件类型
+func (x *FsmNode) Type() string {
+	return "fsm"
+}
+
+func (x *FsmNode) New() types.Node {
+	return &FsmNode{Config: FsmNodeConfiguration{
+		Key:          "metadata.deviceId",
+		States:       []string{"inactive", "active"},
+		InitialState: "inactive",
+		Events: []FsmEvent{
+			{Name: "activate", Condition: "msgType == 'ACTIVATE'"},
+			{Name: "deactivate", Condition: "msgType == 'DEACTIVATE'"},
+		},
+		Transitions: []FsmTransition{
+			{From: "inactive", Event: "activate", To: "active"},
+			{From: "active", Event: "deactivate", To: "inactive"},
+		},
+	}}
+}
+
+// Def 状态转换的链路以状态命名，和switch节点一样由用户定义连接名称
+func (x *FsmNode) Def() types.ComponentForm {
+	relationTypes := []string{}
+	return types.ComponentForm{RelationTypes: &relationTypes}
+}
+
+// Init 初始化
+func (x *FsmNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	//列表配置不和默认值合并
+	x.Config = FsmNodeConfiguration{}
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.Key) == "" {
+		x.Config.Key = "metadata.deviceId"
+	}
+	if x.key, err = expr.Compile(x.Config.Key, expr.AllowUndefinedVariables()); err != nil {
+		return err
+	}
+	if len(x.Config.States) == 0 {
+		return errors.New("states is empty")
+	}
+	states := make(map[string]struct{}, len(x.Config.States))
+	for _, state := range x.Config.States {
+		states[state] = struct{}{}
+	}
+	if _, ok := states[x.Config.InitialState]; !ok {
+		return fmt.Errorf("initial state %s is not declared", x.Config.InitialState)
+	}
+	events := make(map[string]struct{}, len(x.Config.Events))
+	x.events = make([]*vm.Program, len(x.Config.Events))
+	for i, event := range x.Config.Events {
+		if event.Name == "" {
+			return errors.New("event name is empty")
+		}
+		events[event.Name] = struct{}{}
+		if x.events[i], err = expr.Compile(event.Condition, expr.AllowUndefinedVariables()); err != nil {
+			return fmt.Errorf("event %s: %w", event.Name, err)
+		}
+	}
+	x.guards = make([]*vm.Program, len(x.Config.Transitions))
+	for i, transition := range x.Config.Transitions {
+		if _, ok := states[transition.From]; !ok && transition.From != AnyState {
+			return fmt.Errorf("transition from state %s is not declared", transition.From)
+		}
+		if _, ok := states[transition.To]; !ok {
+			return fmt.Errorf("transition to state %s is not declared", transition.To)
+		}
+		if _, ok := events[transition.Event]; !ok {
+			return fmt.Errorf("transition event %s is not declared", transition.Event)
+		}
+		if strings.TrimSpace(transition.Guard) != "" {
+			if x.guards[i], err = expr.Compile(transition.Guard, expr.AllowUndefinedVariables()); err != nil {
+				return fmt.Errorf("transition %s -> %s guard: %w", transition.From, transition.To, err)
+			}
+		}
+	}
+	if ruleConfig.StateStore != nil {
+		x.store = ruleConfig.StateStore
+	} else {
+		x.store = cache.NewMemoryCache(0)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *FsmNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	evn := base.NodeUtils.GetEvn(ctx, msg)
+	out, err := vm.Run(x.key, evn)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	key := x.storeKey(ctx, str.ToString(out))
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	current := x.Config.InitialState
+	if v, ok := x.store.Get(key); ok {
+		current = str.ToString(v)
+	}
+	event, transition, err := x.fire(current, evn)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if transition == nil {
+		msg.Metadata.PutValue(KeyCurrentState, current)
+		ctx.TellNext(msg, types.Unchanged)
+		return
+	}
+	if err := x.store.Set(key, transition.To, 0); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(KeyPreviousState, current)
+	msg.Metadata.PutValue(KeyCurrentState, transition.To)
+	msg.Metadata.PutValue(KeyFsmEvent, event)
+	ctx.TellNext(msg, transition.To)
+}
+
+// Destroy 销毁
+func (x *FsmNode) Destroy() {
+}
+
+// fire 按照顺序检查事件，返回触发的事件和状态转换，没有状态转换返回nil
+func (x *FsmNode) fire(current string, evn map[string]interface{}) (string, *FsmTransition, error) {
+	for i, event := range x.Config.Events {
+		out, err := vm.Run(x.events[i], evn)
+		if err != nil {
+			return "", nil, err
+		}
+		if ok, _ := out.(bool); !ok {
+			continue
+		}
+		for j := range x.Config.Transitions {
+			transition := &x.Config.Transitions[j]
+			if transition.Event != event.Name || (transition.From != current && transition.From != AnyState) {
+				continue
+			}
+			if x.guards[j] != nil {
+				out, err := vm.Run(x.guards[j], evn)
+				if err != nil {
+					return "", nil, err
+				}
+				if ok, _ := out.(bool); !ok {
+					continue
+				}
+			}
+			return event.Name, transition, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// storeKey 获取状态存储key，以规则链ID和节点ID作为前缀，避免不同节点之间冲突
+func (x *FsmNode) storeKey(ctx types.RuleContext, key string) string {
+	var chainId string
+	if ctx.RuleChain() != nil {
+		chainId = ctx.RuleChain().GetNodeId().Id
+	}
+	return "fsm:" + chainId + ":" + ctx.GetSelfId() + ":" + key
+}
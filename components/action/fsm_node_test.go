@@ -0,0 +1,165 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/cache"
+	"github.com/rulego/rulego/utils/reflect"
+)
+
+func TestFsmNode(t *testing.T) {
+	var targetNodeType = "fsm"
+	d1 := types.BuildMetadata(map[string]string{"deviceId": "d1"})
+	d2 := types.BuildMetadata(map[string]string{"deviceId": "d2"})
+
+	var lifecycleConfig = types.Configuration{
+		"key":          "metadata.deviceId",
+		"states":       []interface{}{"provisioning", "active", "maintenance", "decommissioned"},
+		"initialState": "provisioning",
+		"events": []interface{}{
+			map[string]interface{}{"name": "activate", "condition": "msgType == 'ACTIVATE'"},
+			map[string]interface{}{"name": "repair", "condition": "msgType == 'FAULT'"},
+			map[string]interface{}{"name": "repaired", "condition": "msgType == 'REPAIRED'"},
+			map[string]interface{}{"name": "retire", "condition": "msgType == 'RETIRE'"},
+		},
+		"transitions": []interface{}{
+			map[string]interface{}{"from": "provisioning", "event": "activate", "to": "active"},
+			map[string]interface{}{"from": "active", "event": "repair", "to": "maintenance", "guard": "msg.level > 1"},
+			map[string]interface{}{"from": "maintenance", "event": "repaired", "to": "active"},
+			map[string]interface{}{"from": "*", "event": "retire", "to": "decommissioned"},
+		},
+	}
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &FsmNode{}, types.Configuration{
+			"key":          "metadata.deviceId",
+			"initialState": "inactive",
+		}, Registry)
+	})
+
+	t.Run("Def", func(t *testing.T) {
+		componentForm := reflect.GetComponentForm(&FsmNode{})
+		assert.Equal(t, "fsm", componentForm.Type)
+		assert.Equal(t, 0, len(*componentForm.RelationTypes))
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, lifecycleConfig, Registry)
+		assert.Nil(t, err)
+		fsmNode := node.(*FsmNode)
+		assert.Equal(t, 4, len(fsmNode.Config.States))
+		assert.Equal(t, 4, len(fsmNode.Config.Events))
+		assert.Equal(t, "msg.level > 1", fsmNode.Config.Transitions[1].Guard)
+
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"states":       []interface{}{"a"},
+			"initialState": "b",
+		}, Registry)
+		assert.Equal(t, "initial state b is not declared", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"initialState": "a",
+		}, Registry)
+		assert.Equal(t, "states is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"states":       []interface{}{"a", "b"},
+			"initialState": "a",
+			"events":       []interface{}{map[string]interface{}{"name": "e1", "condition": "true"}},
+			"transitions":  []interface{}{map[string]interface{}{"from": "a", "event": "e1", "to": "c"}},
+		}, Registry)
+		assert.Equal(t, "transition to state c is not declared", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"states":       []interface{}{"a", "b"},
+			"initialState": "a",
+			"events":       []interface{}{map[string]interface{}{"name": "e1", "condition": "true"}},
+			"transitions":  []interface{}{map[string]interface{}{"from": "a", "event": "e2", "to": "b"}},
+		}, Registry)
+		assert.Equal(t, "transition event e2 is not declared", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"states":       []interface{}{"a", "b"},
+			"initialState": "a",
+			"events":       []interface{}{map[string]interface{}{"name": "e1", "condition": "msg.a >"}},
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Transitions", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, lifecycleConfig, Registry)
+		assert.Nil(t, err)
+		var msgList = []test.Msg{
+			{MetaData: d1, MsgType: "FAULT", Data: "{\"level\":2}"},
+			{MetaData: d1, MsgType: "ACTIVATE", Data: "{}"},
+			{MetaData: d1, MsgType: "FAULT", Data: "{\"level\":1}"},
+			{MetaData: d1, MsgType: "FAULT", Data: "{\"level\":2}"},
+			{MetaData: d2, MsgType: "ACTIVATE", Data: "{}"},
+			{MetaData: d1, MsgType: "REPAIRED", Data: "{}"},
+			{MetaData: d1, MsgType: "RETIRE", Data: "{}"},
+			{MetaData: d1, MsgType: "ACTIVATE", Data: "{}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 8, len(results))
+		var relationTypes []string
+		for _, item := range results {
+			relationTypes = append(relationTypes, item.RelationType)
+		}
+		assert.Equal(t, []string{types.Unchanged, "active", types.Unchanged, "maintenance", "active", "active",
+			"decommissioned", types.Unchanged}, relationTypes)
+
+		assert.Equal(t, "provisioning", results[0].Msg.Metadata.GetValue(KeyCurrentState))
+		assert.False(t, results[0].Msg.Metadata.Has(KeyPreviousState))
+		assert.Equal(t, "provisioning", results[1].Msg.Metadata.GetValue(KeyPreviousState))
+		assert.Equal(t, "active", results[1].Msg.Metadata.GetValue(KeyCurrentState))
+		assert.Equal(t, "activate", results[1].Msg.Metadata.GetValue(KeyFsmEvent))
+		//守卫条件不通过
+		assert.Equal(t, "active", results[2].Msg.Metadata.GetValue(KeyCurrentState))
+		assert.Equal(t, "repair", results[3].Msg.Metadata.GetValue(KeyFsmEvent))
+		//任意状态转换
+		assert.Equal(t, "active", results[6].Msg.Metadata.GetValue(KeyPreviousState))
+		assert.Equal(t, "decommissioned", results[7].Msg.Metadata.GetValue(KeyCurrentState))
+	})
+
+	t.Run("Store", func(t *testing.T) {
+		store := cache.NewMemoryCache(10)
+		config := types.NewConfig(types.WithStateStore(store))
+		node := test.InitNodeByConfig(config, targetNodeType, lifecycleConfig, Registry)
+		test.NewNodeCollector(node).OnMsg(node, test.Msg{MetaData: d1, MsgType: "ACTIVATE", Data: "{}"})
+		assert.Equal(t, 1, store.Len())
+
+		//新节点实例从存储中恢复状态
+		node = test.InitNodeByConfig(config, targetNodeType, lifecycleConfig, Registry)
+		results := test.NewNodeCollector(node).OnMsg(node, test.Msg{MetaData: d1, MsgType: "FAULT", Data: "{\"level\":2}"}).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "maintenance", results[0].RelationType)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"states":       []interface{}{"a", "b"},
+			"initialState": "a",
+			"events":       []interface{}{map[string]interface{}{"name": "e1", "condition": "msg.a.b.c > 1"}},
+			"transitions":  []interface{}{map[string]interface{}{"from": "a", "event": "e1", "to": "b"}},
+		}, Registry)
+		assert.Nil(t, err)
+		results := test.NewNodeCollector(node).OnMsg(node, test.Msg{MetaData: d1, MsgType: "TELEMETRY", Data: "{\"a\":1}"}).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, types.Failure, results[0].RelationType)
+	})
+}
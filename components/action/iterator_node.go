@@ -54,6 +54,9 @@ type IteratorNode struct {
 	jsEngine types.JsEngine
 }
 
+// 确保IteratorNode实现了types.Migratable接口
+var _ types.Migratable = (*IteratorNode)(nil)
+
 // Type 组件类型
 func (x *IteratorNode) Type() string {
 	return "iterator"
@@ -139,3 +142,102 @@ func (x *IteratorNode) executeItem(ctx types.RuleContext, msg types.RuleMsg, ite
 	}
 	return nil
 }
+
+// Migrations 迁移到for节点，如果有js过滤脚本或者False分支，则在for节点的do分支增加jsFilter节点过滤item：
+// iterator True分支迁移到jsFilter True分支，False分支迁移到jsFilter False分支，Success、Failure分支不变
+func (x *IteratorNode) Migrations() []types.Migration {
+	return []types.Migration{{
+		Version: "v0.22.0",
+		Desc:    "iterator is deprecated, migrated to for and jsFilter",
+		Migrate: migrateIteratorToFor,
+	}}
+}
+
+// iteratorFilterScript 迁移后jsFilter节点的脚本，兼容iterator脚本的item和index变量
+const iteratorFilterScript = "var item = msg;\nvar index = metadata._loopKey !== undefined ? metadata._loopKey : parseInt(metadata._loopIndex);\n"
+
+func migrateIteratorToFor(chain *types.RuleChain, node *types.RuleNode) (bool, error) {
+	if node.Type != "iterator" {
+		return false, nil
+	}
+	var config IteratorNodeConfiguration
+	if err := maps.Map2Struct(node.Configuration, &config); err != nil {
+		return false, err
+	}
+	config.FieldName = strings.TrimSpace(config.FieldName)
+	config.JsScript = strings.TrimSpace(config.JsScript)
+	var trueIds []string
+	var hasFalse bool
+	for _, conn := range chain.Metadata.Connections {
+		if conn.FromId == node.Id && conn.Type == types.True {
+			trueIds = append(trueIds, conn.ToId)
+		} else if conn.FromId == node.Id && conn.Type == types.False {
+			hasFalse = true
+		}
+	}
+	var forRange string
+	if config.FieldName != "" {
+		forRange = "msg." + config.FieldName
+	}
+	var doId string
+	if config.JsScript == "" && !hasFalse && len(trueIds) == 1 {
+		//没有过滤条件，直接遍历True分支
+		doId = trueIds[0]
+		chain.Metadata.Connections = removeConnections(chain.Metadata.Connections, node.Id, types.True)
+	} else {
+		doId = uniqueNodeId(chain, node.Id+"_filter")
+		script := config.JsScript
+		if script == "" {
+			script = "return true;"
+		}
+		chain.Metadata.Nodes = append(chain.Metadata.Nodes, &types.RuleNode{
+			Id:        doId,
+			Type:      "jsFilter",
+			Name:      node.Name,
+			DebugMode: node.DebugMode,
+			Configuration: types.Configuration{
+				"jsScript": iteratorFilterScript + script,
+			},
+		})
+		for i, conn := range chain.Metadata.Connections {
+			if conn.FromId == node.Id && (conn.Type == types.True || conn.Type == types.False) {
+				chain.Metadata.Connections[i].FromId = doId
+			}
+		}
+	}
+	node.Type = "for"
+	node.Configuration = types.Configuration{
+		"range": forRange,
+		"do":    doId,
+		"mode":  DoNotProcess,
+	}
+	return true, nil
+}
+
+// removeConnections 删除指定节点指定关系的连接
+func removeConnections(connections []types.NodeConnection, fromId, relationType string) []types.NodeConnection {
+	var result []types.NodeConnection
+	for _, conn := range connections {
+		if conn.FromId != fromId || conn.Type != relationType {
+			result = append(result, conn)
+		}
+	}
+	return result
+}
+
+// uniqueNodeId 生成规则链中不重复的节点ID
+func uniqueNodeId(chain *types.RuleChain, id string) string {
+	exists := func(id string) bool {
+		for _, node := range chain.Metadata.Nodes {
+			if node.Id == id {
+				return true
+			}
+		}
+		return false
+	}
+	newId := id
+	for i := 1; exists(newId); i++ {
+		newId = fmt.Sprintf("%s%d", id, i)
+	}
+	return newId
+}
@@ -0,0 +1,420 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "merge",
+//        "name": "合并拆分消息",
+//        "debugMode": false,
+//        "configuration": {
+//          "correlationKey": "splitId",
+//          "countKey": "splitTotal",
+//          "indexKey": "splitIndex",
+//          "timeoutMs": 60000,
+//          "strategy": "array"
+//        }
+//  }
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/components/js"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+const (
+	// MergeArray 合并成JSON数组，JSON类型的Data作为JSON对象，其他作为字符串
+	MergeArray = "array"
+	// MergeDeep 深度合并JSON对象，后面的消息覆盖前面消息的同名字段
+	MergeDeep = "deepMerge"
+	// MergeJs 使用js reducer脚本合并
+	MergeJs = "js"
+)
+
+const (
+	// KeyMergeCount 合并消息元数据中实际合并的消息数量
+	KeyMergeCount = "mergeCount"
+	// KeyMergeExpected 合并消息元数据中期望合并的消息数量
+	KeyMergeExpected = "mergeExpected"
+)
+
+var (
+	// ErrMergeDrained 合并节点已经排空，不再接收消息
+	ErrMergeDrained = errors.New("merge node is drained")
+	// ErrMergeNotObject 深度合并的消息不是JSON对象
+	ErrMergeNotObject = errors.New("deep merge requires JSON object data")
+)
+
+// mergeReduceScript 在同一次js执行中依次调用Reduce，使acc在多次调用之间保持为js对象
+const mergeReduceScript = `function ReduceAll(msgs, metadataList) {
+	var acc = null;
+	for (var i = 0; i < msgs.length; i++) {
+		acc = Reduce(acc, msgs[i], metadataList[i], i);
+	}
+	return acc;
+}`
+
+// 注册节点
+func init() {
+	Registry.Add(&MergeNode{})
+}
+
+// MergeNodeConfiguration 节点配置
+type MergeNodeConfiguration struct {
+	//CorrelationKey 关联ID所在的元数据key，关联ID相同的消息合并在一起，默认splitId
+	CorrelationKey string `required:"true"`
+	//CountKey 期望合并的消息数量所在的元数据key，默认splitTotal
+	CountKey string
+	//Count 期望合并的消息数量，消息元数据中没有CountKey时使用
+	Count int `min:"0"`
+	//IndexKey 消息序号所在的元数据key，默认splitIndex，合并时按照序号排序，相同序号的消息只合并一次
+	//如果消息元数据中没有该key，则按照到达顺序合并
+	IndexKey string
+	//TimeoutMs 等待所有消息到达的超时时间，单位毫秒，默认60000
+	//超时后把已经到达的消息合并，通过`Timeout`链路发送到下一个节点
+	TimeoutMs int64 `min:"1"`
+	//Strategy 合并策略：array(JSON数组)、deepMerge(深度合并JSON对象)、js(js reducer脚本)，默认array
+	Strategy string `enum:"array,deepMerge,js"`
+	//JsScript 合并策略是js时的reducer脚本，依次对每条消息执行，返回值作为下一次执行的acc，最后一次返回值作为合并结果
+	//function Reduce(acc, msg, metadata, index)
+	//acc: 上一次执行的返回值，第一次执行为null
+	//msg: 当前消息，JSON类型会转换成对象
+	//metadata: 当前消息元数据
+	//index: 当前消息合并的序号
+	JsScript string `widget:"code"`
+	//MaxGroups 最大等待合并的关联ID数量，默认10000，超过后新关联ID的消息通过`Failure`链路路由到下一个节点
+	MaxGroups int `min:"1"`
+}
+
+// MergeNode 合并节点，等待关联ID相同的N条消息都到达后，把它们合并成一条消息通过`Success`链路发送到下一个节点，
+// 通常和split节点一起使用，把拆分后分别处理的消息重新合并。与join节点不同，join只合并同一次执行的并行分支
+// 合并消息的类型和元数据取自序号最小的消息，并在元数据增加mergeCount、mergeExpected，删除IndexKey
+// 等待超时后，把已经到达的消息合并，通过`Timeout`链路发送到下一个节点
+// 关联ID或者期望数量不存在、合并失败，则通过`Failure`链路发送到下一个节点
+// 同一个关联ID的消息在该节点结束，第一条消息的处理会挂起，直到使用它发送合并消息
+type MergeNode struct {
+	//节点配置
+	Config   MergeNodeConfiguration
+	jsEngine types.JsEngine
+	//等待合并的分组，key：关联ID
+	groups map[string]*mergeGroup
+	//是否已经排空
+	drained int32
+	mu      sync.Mutex
+}
+
+// mergeGroup 等待合并的分组
+type mergeGroup struct {
+	id       string
+	expected int
+	parts    []mergePart
+	//已经到达的序号
+	indexes map[int]struct{}
+	//挂起的消息上下文，使用它发送合并消息
+	pending types.RuleContext
+	//超时定时器
+	timer *time.Timer
+}
+
+// mergePart 等待合并的消息
+type mergePart struct {
+	index int
+	msg   types.RuleMsg
+}
+
+// 确保MergeNode实现了types.DrainableNode接口
+var _ types.DrainableNode = (*MergeNode)(nil)
+
+// Type 组件类型
+func (x *MergeNode) Type() string {
+	return "merge"
+}
+
+func (x *MergeNode) New() types.Node {
+	return &MergeNode{Config: MergeNodeConfiguration{
+		CorrelationKey: KeySplitId,
+		CountKey:       KeySplitTotal,
+		IndexKey:       KeySplitIndex,
+		TimeoutMs:      60000,
+		Strategy:       MergeArray,
+		MaxGroups:      10000,
+	}}
+}
+
+// Init 初始化
+func (x *MergeNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.CorrelationKey = strings.TrimSpace(x.Config.CorrelationKey)
+	if x.Config.CorrelationKey == "" {
+		return errors.New("correlationKey is empty")
+	}
+	if x.Config.TimeoutMs <= 0 {
+		x.Config.TimeoutMs = 60000
+	}
+	if x.Config.MaxGroups <= 0 {
+		x.Config.MaxGroups = 10000
+	}
+	switch x.Config.Strategy {
+	case "":
+		x.Config.Strategy = MergeArray
+	case MergeArray, MergeDeep:
+	case MergeJs:
+		x.Config.JsScript = strings.TrimSpace(x.Config.JsScript)
+		if x.Config.JsScript == "" {
+			return errors.New("jsScript is empty")
+		}
+		jsScript := fmt.Sprintf("function Reduce(acc, msg, metadata, index) { %s }\n%s", x.Config.JsScript, mergeReduceScript)
+		if x.jsEngine, err = js.NewGojaJsEngine(ruleConfig, jsScript, base.NodeUtils.GetVars(configuration)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported merge strategy: %s", x.Config.Strategy)
+	}
+	x.groups = make(map[string]*mergeGroup)
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *MergeNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	if atomic.LoadInt32(&x.drained) == 1 {
+		ctx.TellFailure(msg, ErrMergeDrained)
+		return
+	}
+	id := msg.Metadata.GetValue(x.Config.CorrelationKey)
+	if id == "" {
+		ctx.TellFailure(msg, fmt.Errorf("metadata %s not found", x.Config.CorrelationKey))
+		return
+	}
+	expected := x.Config.Count
+	if v := msg.Metadata.GetValue(x.Config.CountKey); v != "" {
+		if count, err := strconv.Atoi(v); err == nil {
+			expected = count
+		}
+	}
+	if expected <= 0 {
+		ctx.TellFailure(msg, fmt.Errorf("expected count not found, metadata %s or count must be greater than 0", x.Config.CountKey))
+		return
+	}
+	index := -1
+	if v := msg.Metadata.GetValue(x.Config.IndexKey); v != "" {
+		if i, err := strconv.Atoi(v); err == nil {
+			index = i
+		}
+	}
+
+	var completed *mergeGroup
+	x.mu.Lock()
+	group, ok := x.groups[id]
+	if !ok {
+		if len(x.groups) >= x.Config.MaxGroups {
+			x.mu.Unlock()
+			ctx.TellFailure(msg, ErrMaxGroupsExceeded)
+			return
+		}
+		group = &mergeGroup{id: id, expected: expected, indexes: make(map[int]struct{}), pending: ctx}
+		group.timer = time.AfterFunc(time.Duration(x.Config.TimeoutMs)*time.Millisecond, func() {
+			x.timeout(group)
+		})
+		x.groups[id] = group
+	}
+	hold := group.pending == ctx
+	//相同序号的重复消息只合并一次
+	if _, duplicate := group.indexes[index]; !duplicate || index < 0 {
+		if index >= 0 {
+			group.indexes[index] = struct{}{}
+		}
+		group.parts = append(group.parts, mergePart{index: index, msg: msg})
+		if len(group.parts) >= group.expected {
+			completed = x.remove(group)
+		}
+	}
+	x.mu.Unlock()
+
+	if !hold {
+		//消息已经加入分组，结束该消息的处理
+		ctx.DoOnEnd(msg, nil, types.Success)
+	}
+	if completed != nil {
+		x.emit(completed, types.Success)
+	}
+}
+
+// Drain 优雅停机时立即发送所有未完成的分组，已经到达的消息合并后通过`Timeout`链路发送
+// 如果persist不为空，则把分组中的原始消息交给persist持久化，以便恢复后重新合并
+func (x *MergeNode) Drain(persist func(msg types.RuleMsg)) {
+	x.mu.Lock()
+	atomic.StoreInt32(&x.drained, 1)
+	groups := x.removeAll()
+	x.mu.Unlock()
+	for _, group := range groups {
+		if persist != nil {
+			for _, part := range group.parts {
+				persist(part.msg)
+			}
+			group.pending.DoOnEnd(group.parts[0].msg, nil, types.Success)
+		} else {
+			x.emit(group, types.Timeout)
+		}
+	}
+}
+
+// Destroy 销毁，未完成的分组合并后通过`Timeout`链路发送
+func (x *MergeNode) Destroy() {
+	x.mu.Lock()
+	groups := x.removeAll()
+	x.mu.Unlock()
+	for _, group := range groups {
+		x.emit(group, types.Timeout)
+	}
+	//未完成的分组使用js脚本合并后才能停止js引擎
+	if x.jsEngine != nil {
+		x.jsEngine.Stop()
+	}
+}
+
+// timeout 等待超时，发送已经到达的消息合并结果
+func (x *MergeNode) timeout(group *mergeGroup) {
+	x.mu.Lock()
+	if x.groups[group.id] != group {
+		//分组已经发送
+		x.mu.Unlock()
+		return
+	}
+	x.remove(group)
+	x.mu.Unlock()
+	x.emit(group, types.Timeout)
+}
+
+// remove 移除分组，调用方需要持有锁
+func (x *MergeNode) remove(group *mergeGroup) *mergeGroup {
+	group.timer.Stop()
+	delete(x.groups, group.id)
+	return group
+}
+
+// removeAll 移除所有分组，调用方需要持有锁
+func (x *MergeNode) removeAll() []*mergeGroup {
+	var groups []*mergeGroup
+	for _, group := range x.groups {
+		groups = append(groups, x.remove(group))
+	}
+	return groups
+}
+
+// emit 合并分组中的消息，并通过指定关系发送到下一个节点
+func (x *MergeNode) emit(group *mergeGroup, relationType string) {
+	sort.SliceStable(group.parts, func(i, j int) bool {
+		return group.parts[i].index < group.parts[j].index
+	})
+	first := group.parts[0].msg
+	msg, err := x.merge(group)
+	if err != nil {
+		group.pending.TellFailure(first, err)
+		return
+	}
+	group.pending.TellNext(msg, relationType)
+}
+
+// merge 按照合并策略合并消息
+func (x *MergeNode) merge(group *mergeGroup) (types.RuleMsg, error) {
+	first := group.parts[0].msg
+	var result interface{}
+	switch x.Config.Strategy {
+	case MergeDeep:
+		merged := make(map[string]interface{})
+		for _, part := range group.parts {
+			var data map[string]interface{}
+			if err := json.Unmarshal([]byte(part.msg.Data), &data); err != nil {
+				return first, ErrMergeNotObject
+			}
+			deepMerge(merged, data)
+		}
+		result = merged
+	case MergeJs:
+		msgs := make([]interface{}, 0, len(group.parts))
+		metadataList := make([]interface{}, 0, len(group.parts))
+		for _, part := range group.parts {
+			msgs = append(msgs, mergeData(part.msg))
+			metadataList = append(metadataList, part.msg.Metadata.Values())
+		}
+		out, err := x.jsEngine.Execute("ReduceAll", msgs, metadataList)
+		if err != nil {
+			return first, err
+		}
+		result = out
+	default:
+		list := make([]interface{}, 0, len(group.parts))
+		for _, part := range group.parts {
+			list = append(list, mergeData(part.msg))
+		}
+		result = list
+	}
+
+	metadata := first.Metadata.Copy()
+	delete(metadata, x.Config.IndexKey)
+	metadata.PutValue(KeyMergeCount, strconv.Itoa(len(group.parts)))
+	metadata.PutValue(KeyMergeExpected, strconv.Itoa(group.expected))
+	switch v := result.(type) {
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return first, err
+		}
+		return types.NewMsg(0, first.Type, types.JSON, metadata, string(b)), nil
+	default:
+		return types.NewMsg(0, first.Type, types.TEXT, metadata, str.ToString(v)), nil
+	}
+}
+
+// mergeData JSON类型的消息转换成对象，其他使用字符串
+func mergeData(msg types.RuleMsg) interface{} {
+	if msg.DataType == types.JSON {
+		var data interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &data); err == nil {
+			return data
+		}
+	}
+	return msg.Data
+}
+
+// deepMerge 把src深度合并到dst，同名字段如果都是对象则递归合并，否则src覆盖dst
+func deepMerge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if srcMap, ok := v.(map[string]interface{}); ok {
+			if dstMap, ok := dst[k].(map[string]interface{}); ok {
+				deepMerge(dstMap, srcMap)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestMergeNode(t *testing.T) {
+	var targetNodeType = "merge"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &MergeNode{}, types.Configuration{
+			"correlationKey": KeySplitId,
+			"countKey":       KeySplitTotal,
+			"indexKey":       KeySplitIndex,
+			"timeoutMs":      int64(60000),
+			"strategy":       MergeArray,
+			"maxGroups":      10000,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"correlationKey": "orderId",
+			"count":          3,
+			"strategy":       MergeDeep,
+		}, types.Configuration{
+			"correlationKey": "orderId",
+			"countKey":       KeySplitTotal,
+			"count":          3,
+			"indexKey":       KeySplitIndex,
+			"timeoutMs":      int64(60000),
+			"strategy":       MergeDeep,
+			"maxGroups":      10000,
+		}, Registry)
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"strategy": "sum",
+		}, Registry)
+		assert.Equal(t, "unsupported merge strategy: sum", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"strategy": MergeJs,
+		}, Registry)
+		assert.Equal(t, "jsScript is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"correlationKey": " ",
+		}, Registry)
+		assert.Equal(t, "correlationKey is empty", err.Error())
+	})
+
+	t.Run("Array", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			splitPart("a", 2, 3, "{\"sku\":\"c\"}"),
+			splitPart("b", 0, 2, "{\"sku\":\"x\"}"),
+			splitPart("a", 0, 3, "{\"sku\":\"a\"}"),
+			//重复的消息
+			splitPart("a", 0, 3, "{\"sku\":\"a\"}"),
+			splitPart("a", 1, 3, "text"),
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, types.Success, results[0].RelationType)
+		msg := results[0].Msg
+		assert.Equal(t, "[{\"sku\":\"a\"},\"text\",{\"sku\":\"c\"}]", msg.Data)
+		assert.Equal(t, types.JSON, msg.DataType)
+		assert.Equal(t, "a", msg.Metadata.GetValue(KeySplitId))
+		assert.Equal(t, "3", msg.Metadata.GetValue(KeyMergeCount))
+		assert.Equal(t, "3", msg.Metadata.GetValue(KeyMergeExpected))
+		assert.False(t, msg.Metadata.Has(KeySplitIndex))
+	})
+
+	t.Run("DeepMerge", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"correlationKey": "orderId",
+			"count":          2,
+			"strategy":       MergeDeep,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		metadata := types.BuildMetadata(map[string]string{"orderId": "o1"})
+		var msgList = []test.Msg{
+			{MetaData: metadata, MsgType: "ORDER", Data: "{\"a\":1,\"info\":{\"x\":1,\"y\":1}}"},
+			{MetaData: metadata, MsgType: "ORDER", Data: "{\"b\":2,\"info\":{\"y\":2}}"},
+			{MetaData: types.BuildMetadata(map[string]string{"orderId": "o2"}), MsgType: "ORDER", Data: "[1]"},
+			{MetaData: types.BuildMetadata(map[string]string{"orderId": "o2"}), MsgType: "ORDER", Data: "[2]"},
+			{MsgType: "ORDER", Data: "{}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, types.Success, results[0].RelationType)
+		assert.Equal(t, "{\"a\":1,\"b\":2,\"info\":{\"x\":1,\"y\":2}}", results[0].Msg.Data)
+		assert.Equal(t, "ORDER", results[0].Msg.Type)
+		assert.Equal(t, types.Failure, results[1].RelationType)
+		assert.Equal(t, ErrMergeNotObject, results[1].Err)
+		//没有关联ID
+		assert.Equal(t, types.Failure, results[2].RelationType)
+		assert.Equal(t, "metadata orderId not found", results[2].Err.Error())
+	})
+
+	t.Run("Js", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"strategy": MergeJs,
+			"jsScript": "return (acc || 0) + msg.amount;",
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			splitPart("a", 0, 2, "{\"amount\":10}"),
+			splitPart("a", 1, 2, "{\"amount\":15}"),
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "25", results[0].Msg.Data)
+		assert.Equal(t, types.TEXT, results[0].Msg.DataType)
+
+		node, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"strategy": MergeJs,
+			"jsScript": "acc = acc || {items: []}; acc.items.push(msg.sku); return acc;",
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		msgList = []test.Msg{
+			splitPart("a", 1, 2, "{\"sku\":\"y\"}"),
+			splitPart("a", 0, 2, "{\"sku\":\"x\"}"),
+		}
+		results = test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "{\"items\":[\"x\",\"y\"]}", results[0].Msg.Data)
+
+		//销毁时未完成的分组通过js脚本合并后发送
+		node, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"strategy": MergeJs,
+			"jsScript": "return (acc || 0) + msg.amount;",
+		}, Registry)
+		assert.Nil(t, err)
+		collector := test.NewNodeCollector(node).OnMsg(node,
+			splitPart("b", 0, 3, "{\"amount\":10}"),
+			splitPart("b", 2, 3, "{\"amount\":5}"),
+		)
+		assert.Equal(t, 0, len(collector.Results()))
+		node.Destroy()
+		results = collector.Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, types.Timeout, results[0].RelationType)
+		assert.Equal(t, "15", results[0].Msg.Data)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"timeoutMs": 200,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			splitPart("a", 0, 3, "{\"sku\":\"a\"}"),
+			splitPart("a", 2, 3, "{\"sku\":\"c\"}"),
+		}
+		collector := test.NewNodeCollector(node).OnMsg(node, msgList...)
+		assert.Equal(t, 0, len(collector.Results()))
+		//等待超时发送
+		results := collector.Wait(t, 1)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, types.Timeout, results[0].RelationType)
+		assert.Equal(t, "[{\"sku\":\"a\"},{\"sku\":\"c\"}]", results[0].Msg.Data)
+		assert.Equal(t, "2", results[0].Msg.Metadata.GetValue(KeyMergeCount))
+		assert.Equal(t, "3", results[0].Msg.Metadata.GetValue(KeyMergeExpected))
+	})
+
+	t.Run("MaxGroups", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"maxGroups": 1,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			splitPart("a", 0, 2, "{}"),
+			splitPart("b", 0, 2, "{}"),
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, ErrMaxGroupsExceeded, results[0].Err)
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			splitPart("a", 0, 3, "{\"sku\":\"a\"}"),
+			splitPart("a", 1, 3, "{\"sku\":\"b\"}"),
+		}
+		collector := test.NewNodeCollector(node).OnMsg(node, msgList...)
+		assert.Equal(t, 0, len(collector.Results()))
+
+		var persisted []types.RuleMsg
+		node.(types.DrainableNode).Drain(func(msg types.RuleMsg) {
+			persisted = append(persisted, msg)
+		})
+		assert.Equal(t, 2, len(persisted))
+
+		results := collector.OnMsg(node, msgList[0]).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, ErrMergeDrained, results[0].Err)
+	})
+}
+
+// splitPart 创建拆分后的消息
+func splitPart(id string, index, total int, data string) test.Msg {
+	return test.Msg{
+		MetaData: types.BuildMetadata(map[string]string{
+			KeySplitId:    id,
+			KeySplitIndex: strconv.Itoa(index),
+			KeySplitTotal: strconv.Itoa(total),
+		}),
+		MsgType:  "ORDER",
+		DataType: types.JSON,
+		Data:     data,
+	}
+}
@@ -0,0 +1,289 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "split",
+//        "name": "拆分",
+//        "debugMode": false,
+//        "configuration": {
+//          "mode": "json",
+//          "path": "msg.items"
+//        }
+//  }
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+const (
+	// SplitJson 拆分JSON数组，每个元素一条消息
+	SplitJson = "json"
+	// SplitLine 按行拆分文本，每行一条消息
+	SplitLine = "line"
+	// SplitCsv 拆分CSV文本，每条记录一条消息
+	SplitCsv = "csv"
+	// SplitBytes 按照固定大小拆分二进制数据，每块一条消息
+	SplitBytes = "bytes"
+)
+
+const (
+	// KeySplitId 拆分消息元数据中的分组ID，取值为原始消息ID，同一条消息拆分出来的消息分组ID相同
+	KeySplitId = "splitId"
+	// KeySplitIndex 拆分消息元数据中的序号，从0开始
+	KeySplitIndex = "splitIndex"
+	// KeySplitTotal 拆分消息元数据中的消息总数
+	KeySplitTotal = "splitTotal"
+)
+
+// ErrNotArray 拆分的值不是数组
+var ErrNotArray = errors.New("value is not array")
+
+// 注册节点
+func init() {
+	Registry.Add(&SplitNode{})
+}
+
+// SplitNodeConfiguration 节点配置
+type SplitNodeConfiguration struct {
+	//Mode 拆分方式：json(JSON数组)、line(按行)、csv(CSV记录)、bytes(固定大小二进制块)，默认json
+	Mode string `enum:"json,line,csv,bytes"`
+	//Path json方式，获取拆分数组的表达式，可以访问msg、metadata、id、type等变量，例如：msg.items
+	//为空则拆分整个msg
+	Path string
+	//SkipEmpty line方式，是否忽略空行，默认true
+	SkipEmpty bool
+	//Delimiter csv方式，字段分隔符，默认逗号
+	Delimiter string
+	//Header csv方式，第一条记录是否是表头，如果是则每条记录转换成{表头:值}JSON对象，否则转换成JSON数组
+	Header bool
+	//ChunkSize bytes方式，每块字节数，默认1024
+	ChunkSize int `min:"1"`
+}
+
+// SplitNode 拆分节点，把JSON数组、按行或者CSV分隔的文本、二进制数据拆分成多条消息，逐条通过`Success`链路发送到下一个节点
+// 每条消息的元数据增加splitId(原始消息ID)、splitIndex(序号)和splitTotal(总数)，可以通过merge节点重新合并
+// 与for节点不同，拆分后的消息不会返回该节点，原始消息在该节点结束
+// 如果拆分的值不是数组、CSV格式错误或者表达式执行失败，则把原始消息通过`Failure`链路发送到下一个节点
+type SplitNode struct {
+	//节点配置
+	Config SplitNodeConfiguration
+	//拆分数组表达式
+	path *vm.Program
+	//csv字段分隔符
+	comma rune
+}
+
+// Type 组件类型
+func (x *SplitNode) Type() string {
+	return "split"
+}
+
+func (x *SplitNode) New() types.Node {
+	return &SplitNode{Config: SplitNodeConfiguration{
+		Mode:      SplitJson,
+		SkipEmpty: true,
+		Delimiter: ",",
+		ChunkSize: 1024,
+	}}
+}
+
+// Init 初始化
+func (x *SplitNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	switch x.Config.Mode {
+	case "":
+		x.Config.Mode = SplitJson
+	case SplitJson, SplitLine, SplitCsv, SplitBytes:
+	default:
+		return fmt.Errorf("unsupported split mode: %s", x.Config.Mode)
+	}
+	if x.Config.ChunkSize <= 0 {
+		x.Config.ChunkSize = 1024
+	}
+	if x.Config.Delimiter == "" {
+		x.Config.Delimiter = ","
+	}
+	if utf8.RuneCountInString(x.Config.Delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character: %s", x.Config.Delimiter)
+	}
+	x.comma, _ = utf8.DecodeRuneInString(x.Config.Delimiter)
+	x.Config.Path = strings.TrimSpace(x.Config.Path)
+	if x.Config.Path != "" {
+		if x.path, err = expr.Compile(x.Config.Path, expr.AllowUndefinedVariables()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *SplitNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var items []splitItem
+	var err error
+	switch x.Config.Mode {
+	case SplitLine:
+		items = x.splitLines(msg.Data)
+	case SplitCsv:
+		items, err = x.splitCsv(msg.Data)
+	case SplitBytes:
+		items = x.splitBytes(msg)
+	default:
+		items, err = x.splitJson(ctx, msg)
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if len(items) == 0 {
+		//没有可以发送的消息，原始消息在该节点结束
+		ctx.DoOnEnd(msg, nil, types.Success)
+		return
+	}
+	total := strconv.Itoa(len(items))
+	for index, item := range items {
+		metadata := msg.Metadata.Copy()
+		metadata.PutValue(KeySplitId, msg.Id)
+		metadata.PutValue(KeySplitIndex, strconv.Itoa(index))
+		metadata.PutValue(KeySplitTotal, total)
+		ctx.TellSuccess(types.NewMsg(msg.Ts, msg.Type, item.dataType, metadata, item.data))
+	}
+}
+
+// Destroy 销毁
+func (x *SplitNode) Destroy() {
+}
+
+// splitItem 拆分出来的消息内容
+type splitItem struct {
+	dataType types.DataType
+	data     string
+}
+
+func (x *SplitNode) splitJson(ctx types.RuleContext, msg types.RuleMsg) ([]splitItem, error) {
+	var value interface{}
+	if x.path != nil {
+		out, err := vm.Run(x.path, base.NodeUtils.GetEvn(ctx, msg))
+		if err != nil {
+			return nil, err
+		}
+		value = out
+	} else if err := json.Unmarshal([]byte(msg.Data), &value); err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, ErrNotArray
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, ErrNotArray
+	}
+	var items []splitItem
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i).Interface()
+		switch item.(type) {
+		case map[string]interface{}, []interface{}:
+			b, err := json.Marshal(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, splitItem{dataType: types.JSON, data: string(b)})
+		default:
+			items = append(items, splitItem{dataType: types.TEXT, data: str.ToString(item)})
+		}
+	}
+	return items, nil
+}
+
+func (x *SplitNode) splitLines(data string) []splitItem {
+	var items []splitItem
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if x.Config.SkipEmpty && strings.TrimSpace(line) == "" {
+			continue
+		}
+		items = append(items, splitItem{dataType: types.TEXT, data: line})
+	}
+	return items
+}
+
+func (x *SplitNode) splitCsv(data string) ([]splitItem, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.Comma = x.comma
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var header []string
+	if x.Config.Header && len(records) > 0 {
+		header = records[0]
+		records = records[1:]
+	}
+	var items []splitItem
+	for _, record := range records {
+		var value interface{} = record
+		if header != nil {
+			row := make(map[string]interface{}, len(record))
+			for i, field := range record {
+				if i < len(header) {
+					row[header[i]] = field
+				} else {
+					row[strconv.Itoa(i)] = field
+				}
+			}
+			value = row
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, splitItem{dataType: types.JSON, data: string(b)})
+	}
+	return items, nil
+}
+
+func (x *SplitNode) splitBytes(msg types.RuleMsg) []splitItem {
+	var items []splitItem
+	size := x.Config.ChunkSize
+	for start := 0; start < len(msg.Data); start += size {
+		end := start + size
+		if end > len(msg.Data) {
+			end = len(msg.Data)
+		}
+		items = append(items, splitItem{dataType: msg.DataType, data: msg.Data[start:end]})
+	}
+	return items
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestSplitNode(t *testing.T) {
+	var targetNodeType = "split"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &SplitNode{}, types.Configuration{
+			"mode":      SplitJson,
+			"skipEmpty": true,
+			"delimiter": ",",
+			"chunkSize": 1024,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"mode":      SplitCsv,
+			"delimiter": ";",
+			"header":    true,
+		}, types.Configuration{
+			"mode":      SplitCsv,
+			"skipEmpty": true,
+			"delimiter": ";",
+			"header":    true,
+			"chunkSize": 1024,
+		}, Registry)
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode": "xml",
+		}, Registry)
+		assert.Equal(t, "unsupported split mode: xml", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode":      SplitCsv,
+			"delimiter": ";;",
+		}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"path": "msg.items +",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Json", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"path": "msg.items",
+		}, Registry)
+		assert.Nil(t, err)
+		results := test.NewNodeCollector(node).OnMsg(node, test.Msg{
+			Id:       "m1",
+			MetaData: types.BuildMetadata(map[string]string{"deviceId": "aa"}),
+			MsgType:  "ORDER",
+			Data:     "{\"items\":[{\"sku\":\"a\"},{\"sku\":\"b\"},1]}",
+		}).Results()
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, "{\"sku\":\"a\"}", results[0].Msg.Data)
+		assert.Equal(t, types.JSON, results[0].Msg.DataType)
+		assert.Equal(t, "{\"sku\":\"b\"}", results[1].Msg.Data)
+		assert.Equal(t, "1", results[2].Msg.Data)
+		assert.Equal(t, types.TEXT, results[2].Msg.DataType)
+		for index, item := range results {
+			assert.Equal(t, types.Success, item.RelationType)
+			assert.Equal(t, "ORDER", item.Msg.Type)
+			assert.Equal(t, "aa", item.Msg.Metadata.GetValue("deviceId"))
+			assert.Equal(t, "m1", item.Msg.Metadata.GetValue(KeySplitId))
+			assert.Equal(t, "3", item.Msg.Metadata.GetValue(KeySplitTotal))
+			assert.Equal(t, strconv.Itoa(index), item.Msg.Metadata.GetValue(KeySplitIndex))
+			assert.True(t, item.Msg.Id != "m1")
+		}
+
+		//整个msg
+		node, err = test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.Nil(t, err)
+		results = test.NewNodeCollector(node).OnMsg(node, test.Msg{Data: "[\"a\",\"b\"]"}).Results()
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, "b", results[1].Msg.Data)
+
+		//不是数组
+		results = test.NewNodeCollector(node).OnMsg(node, test.Msg{Data: "{\"items\":1}"}).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, types.Failure, results[0].RelationType)
+		assert.Equal(t, ErrNotArray, results[0].Err)
+	})
+
+	t.Run("Line", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode": SplitLine,
+		}, Registry)
+		assert.Nil(t, err)
+		results := test.NewNodeCollector(node).OnMsg(node, test.Msg{DataType: types.TEXT, Data: "line1\r\n\nline2\n"}).Results()
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, "line1", results[0].Msg.Data)
+		assert.Equal(t, "line2", results[1].Msg.Data)
+		assert.Equal(t, "2", results[1].Msg.Metadata.GetValue(KeySplitTotal))
+
+		node, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode":      SplitLine,
+			"skipEmpty": false,
+		}, Registry)
+		assert.Nil(t, err)
+		results = test.NewNodeCollector(node).OnMsg(node, test.Msg{DataType: types.TEXT, Data: "line1\n\nline2"}).Results()
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, "", results[1].Msg.Data)
+	})
+
+	t.Run("Csv", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode":   SplitCsv,
+			"header": true,
+		}, Registry)
+		assert.Nil(t, err)
+		results := test.NewNodeCollector(node).OnMsg(node, test.Msg{DataType: types.TEXT, Data: "name,age\nlala,18\n\"li,li\",20\n"}).Results()
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, "{\"age\":\"18\",\"name\":\"lala\"}", results[0].Msg.Data)
+		assert.Equal(t, "{\"age\":\"20\",\"name\":\"li,li\"}", results[1].Msg.Data)
+
+		node, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode":      SplitCsv,
+			"delimiter": ";",
+		}, Registry)
+		assert.Nil(t, err)
+		results = test.NewNodeCollector(node).OnMsg(node, test.Msg{DataType: types.TEXT, Data: "a;b\nc;d"}).Results()
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, "[\"c\",\"d\"]", results[1].Msg.Data)
+
+		results = test.NewNodeCollector(node).OnMsg(node, test.Msg{DataType: types.TEXT, Data: "a;\"b\nc"}).Results()
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, types.Failure, results[0].RelationType)
+		assert.NotNil(t, results[0].Err)
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode":      SplitBytes,
+			"chunkSize": 4,
+		}, Registry)
+		assert.Nil(t, err)
+		results := test.NewNodeCollector(node).OnMsg(node, test.Msg{DataType: types.BINARY, Data: string([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})}).Results()
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, string([]byte{0, 1, 2, 3}), results[0].Msg.Data)
+		assert.Equal(t, string([]byte{8, 9}), results[2].Msg.Data)
+		assert.Equal(t, types.BINARY, results[2].Msg.DataType)
+	})
+}
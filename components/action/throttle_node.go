@@ -0,0 +1,314 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "throttle",
+//        "name": "告警限流",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "metadata.deviceId",
+//          "mode": "throttle",
+//          "intervalMs": 60000,
+//          "idleMs": 600000
+//        }
+//  }
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+const (
+	// ThrottleLeading 限流，每个时间间隔内只通过第一条消息
+	ThrottleLeading = "throttle"
+	// ThrottleDebounce 防抖，消息停止到达超过时间间隔后，通过最后一条消息
+	ThrottleDebounce = "debounce"
+	// ThrottleSample 采样，每N条消息通过一条
+	ThrottleSample = "sample"
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&ThrottleNode{})
+}
+
+// ThrottleNodeConfiguration 节点配置
+type ThrottleNodeConfiguration struct {
+	//Key 限流key表达式，可以访问msg、metadata、id、type等变量，例如：metadata.deviceId
+	//每个key单独限流，为空则所有消息使用同一个key
+	Key string
+	//Mode 限流模式：throttle(每个时间间隔只通过第一条消息)、debounce(静默时间间隔后通过最后一条消息)、sample(每N条通过一条)，默认throttle
+	Mode string `enum:"throttle,debounce,sample"`
+	//IntervalMs throttle模式的时间间隔或者debounce模式的静默时间，单位毫秒，默认1000
+	IntervalMs int64 `min:"1"`
+	//SampleN sample模式每N条消息通过第1条，默认10
+	SampleN int `min:"1"`
+	//IdleMs key空闲超过该时间后清除其限流状态，单位毫秒，默认60000，小于IntervalMs时使用IntervalMs
+	IdleMs int64 `min:"1"`
+}
+
+// ThrottleNode 限流节点，按照key对消息进行限流、防抖或者采样
+// 通过的消息通过`Success`链路发送到下一个节点，被抑制的消息通过`Suppressed`链路发送到下一个节点
+// debounce模式每个key挂起最后一条消息，新消息到达时，挂起的消息被抑制，静默时间到期后发送挂起的消息
+// key空闲超过IdleMs后清除其限流状态，避免key数量持续增长
+// 如果key表达式执行失败则发送到`Failure`链
+type ThrottleNode struct {
+	//节点配置
+	Config ThrottleNodeConfiguration
+	//key表达式
+	program *vm.Program
+	//限流状态，key：限流key
+	states map[string]*throttleState
+	//是否已经排空，排空后debounce模式不再挂起消息
+	drained int32
+	//排空时挂起消息的持久化函数
+	persist func(msg types.RuleMsg)
+	stop    chan struct{}
+	mu      sync.Mutex
+}
+
+// throttleState 每个key的限流状态
+type throttleState struct {
+	key string
+	//最后一条消息到达时间
+	lastSeen time.Time
+	//throttle模式当前时间间隔开始时间
+	windowStart time.Time
+	//sample模式消息计数
+	count int64
+	//debounce模式挂起的消息和上下文
+	pendingMsg types.RuleMsg
+	pending    types.RuleContext
+	timer      *time.Timer
+}
+
+// 确保ThrottleNode实现了types.DrainableNode接口
+var _ types.DrainableNode = (*ThrottleNode)(nil)
+
+// Type 组件类型
+func (x *ThrottleNode) Type() string {
+	return "throttle"
+}
+
+func (x *ThrottleNode) New() types.Node {
+	return &ThrottleNode{Config: ThrottleNodeConfiguration{
+		Mode:       ThrottleLeading,
+		IntervalMs: 1000,
+		SampleN:    10,
+		IdleMs:     60000,
+	}}
+}
+
+// Init 初始化
+func (x *ThrottleNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	switch x.Config.Mode {
+	case "":
+		x.Config.Mode = ThrottleLeading
+	case ThrottleLeading, ThrottleDebounce, ThrottleSample:
+	default:
+		return fmt.Errorf("unsupported throttle mode: %s", x.Config.Mode)
+	}
+	if x.Config.IntervalMs <= 0 {
+		x.Config.IntervalMs = 1000
+	}
+	if x.Config.SampleN <= 0 {
+		x.Config.SampleN = 10
+	}
+	if x.Config.IdleMs <= 0 {
+		x.Config.IdleMs = 60000
+	}
+	if x.Config.IdleMs < x.Config.IntervalMs {
+		x.Config.IdleMs = x.Config.IntervalMs
+	}
+	if x.Config.Key != "" {
+		if x.program, err = expr.Compile(x.Config.Key, expr.AllowUndefinedVariables()); err != nil {
+			return err
+		}
+	}
+	x.states = make(map[string]*throttleState)
+	x.stop = make(chan struct{})
+	go x.evict(time.Duration(x.Config.IdleMs) * time.Millisecond)
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *ThrottleNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var key string
+	if x.program != nil {
+		out, err := vm.Run(x.program, base.NodeUtils.GetEvn(ctx, msg))
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		key = str.ToString(out)
+	}
+	now := time.Now()
+	interval := time.Duration(x.Config.IntervalMs) * time.Millisecond
+
+	x.mu.Lock()
+	state, ok := x.states[key]
+	if !ok {
+		state = &throttleState{key: key}
+		x.states[key] = state
+	}
+	state.lastSeen = now
+	switch x.Config.Mode {
+	case ThrottleDebounce:
+		if atomic.LoadInt32(&x.drained) == 1 {
+			//已经排空，不再挂起消息
+			persist := x.persist
+			x.mu.Unlock()
+			x.release(ctx, msg, persist)
+			return
+		}
+		suppressedCtx, suppressedMsg := state.pending, state.pendingMsg
+		state.pending, state.pendingMsg = ctx, msg
+		if state.timer != nil {
+			state.timer.Stop()
+		}
+		state.timer = time.AfterFunc(interval, func() {
+			x.fire(state, ctx)
+		})
+		x.mu.Unlock()
+		if suppressedCtx != nil {
+			suppressedCtx.TellNext(suppressedMsg, types.Suppressed)
+		}
+	case ThrottleSample:
+		pass := state.count%int64(x.Config.SampleN) == 0
+		state.count++
+		x.mu.Unlock()
+		x.tell(ctx, msg, pass)
+	default:
+		pass := state.windowStart.IsZero() || now.Sub(state.windowStart) >= interval
+		if pass {
+			state.windowStart = now
+		}
+		x.mu.Unlock()
+		x.tell(ctx, msg, pass)
+	}
+}
+
+// Drain 优雅停机时立即发送debounce模式挂起的消息
+// 如果persist不为空，挂起的消息交给persist持久化
+func (x *ThrottleNode) Drain(persist func(msg types.RuleMsg)) {
+	x.mu.Lock()
+	x.persist = persist
+	atomic.StoreInt32(&x.drained, 1)
+	pending := x.removePending()
+	x.mu.Unlock()
+	for _, state := range pending {
+		x.release(state.pending, state.pendingMsg, persist)
+	}
+}
+
+// Destroy 销毁，立即发送debounce模式挂起的消息
+func (x *ThrottleNode) Destroy() {
+	x.mu.Lock()
+	pending := x.removePending()
+	if x.stop != nil {
+		close(x.stop)
+		x.stop = nil
+	}
+	x.mu.Unlock()
+	for _, state := range pending {
+		state.pending.TellSuccess(state.pendingMsg)
+	}
+}
+
+// tell 通过的消息发送到`Success`链路，否则发送到`Suppressed`链路
+func (x *ThrottleNode) tell(ctx types.RuleContext, msg types.RuleMsg, pass bool) {
+	if pass {
+		ctx.TellSuccess(msg)
+	} else {
+		ctx.TellNext(msg, types.Suppressed)
+	}
+}
+
+// release 立即发送消息到下一个节点，或者持久化后结束该消息的处理
+func (x *ThrottleNode) release(ctx types.RuleContext, msg types.RuleMsg, persist func(msg types.RuleMsg)) {
+	if persist != nil {
+		persist(msg)
+		ctx.DoOnEnd(msg, nil, types.Success)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+}
+
+// fire debounce模式静默时间到期，发送挂起的消息
+func (x *ThrottleNode) fire(state *throttleState, ctx types.RuleContext) {
+	x.mu.Lock()
+	if state.pending != ctx {
+		//挂起的消息已经被替换或者已经发送
+		x.mu.Unlock()
+		return
+	}
+	msg := state.pendingMsg
+	state.pending, state.pendingMsg, state.timer = nil, types.RuleMsg{}, nil
+	x.mu.Unlock()
+	ctx.TellSuccess(msg)
+}
+
+// removePending 移除debounce模式所有挂起的消息，调用方需要持有锁
+func (x *ThrottleNode) removePending() []throttleState {
+	var pending []throttleState
+	for _, state := range x.states {
+		if state.pending != nil {
+			state.timer.Stop()
+			pending = append(pending, *state)
+			state.pending, state.pendingMsg, state.timer = nil, types.RuleMsg{}, nil
+		}
+	}
+	return pending
+}
+
+// evict 定时清除空闲的key
+func (x *ThrottleNode) evict(idle time.Duration) {
+	x.mu.Lock()
+	stop := x.stop
+	x.mu.Unlock()
+	ticker := time.NewTicker(idle)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			x.mu.Lock()
+			for key, state := range x.states {
+				if state.pending == nil && now.Sub(state.lastSeen) >= idle {
+					delete(x.states, key)
+				}
+			}
+			x.mu.Unlock()
+		}
+	}
+}
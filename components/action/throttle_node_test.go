@@ -0,0 +1,207 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestThrottleNode(t *testing.T) {
+	var targetNodeType = "throttle"
+	aa := types.BuildMetadata(map[string]string{"deviceId": "aa"})
+	bb := types.BuildMetadata(map[string]string{"deviceId": "bb"})
+	//消息内容作为key，返回每条消息的路由关系
+	relations := func(results []test.NodeResult) map[string]string {
+		var m = make(map[string]string)
+		for _, item := range results {
+			m[item.Msg.Data] = item.RelationType
+		}
+		return m
+	}
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &ThrottleNode{}, types.Configuration{
+			"mode":       ThrottleLeading,
+			"intervalMs": int64(1000),
+			"sampleN":    10,
+			"idleMs":     int64(60000),
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"key":        "metadata.deviceId",
+			"mode":       ThrottleDebounce,
+			"intervalMs": 120000,
+		}, types.Configuration{
+			"key":        "metadata.deviceId",
+			"mode":       ThrottleDebounce,
+			"intervalMs": int64(120000),
+			"sampleN":    10,
+			"idleMs":     int64(120000),
+		}, Registry)
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode": "drop",
+		}, Registry)
+		assert.Equal(t, "unsupported throttle mode: drop", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key": "metadata.deviceId +",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Throttle", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":        "metadata.deviceId",
+			"intervalMs": 200,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "1"},
+			{MetaData: bb, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "2"},
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "3"},
+			//等待进入下一个时间间隔
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "4", AfterSleep: time.Millisecond * 300},
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "5"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, map[string]string{
+			"1": types.Success,
+			"2": types.Success,
+			"3": types.Suppressed,
+			"4": types.Suppressed,
+			"5": types.Success,
+		}, relations(results))
+	})
+
+	t.Run("Debounce", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":        "metadata.deviceId",
+			"mode":       ThrottleDebounce,
+			"intervalMs": 100,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "1"},
+			{MetaData: bb, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "2"},
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "3"},
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "4"},
+		}
+		collector := test.NewNodeCollector(node).OnMsg(node, msgList...)
+		//间隔内没有新消息，发送每个分组最后一条消息
+		results := collector.Wait(t, 4)
+		assert.Equal(t, map[string]string{
+			"1": types.Suppressed,
+			"2": types.Success,
+			"3": types.Suppressed,
+			"4": types.Success,
+		}, relations(results))
+
+		results = collector.OnMsg(node, test.Msg{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "5"}).Wait(t, 5)
+		assert.Equal(t, 5, len(results))
+		assert.Equal(t, "5", results[4].Msg.Data)
+		assert.Equal(t, types.Success, results[4].RelationType)
+	})
+
+	t.Run("Sample", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode":    ThrottleSample,
+			"sampleN": 2,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "1"},
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "2"},
+			{MetaData: bb, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "3"},
+			{MetaData: bb, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "4"},
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "5"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, map[string]string{
+			"1": types.Success,
+			"2": types.Suppressed,
+			"3": types.Success,
+			"4": types.Suppressed,
+			"5": types.Success,
+		}, relations(results))
+	})
+
+	t.Run("Evict", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":        "metadata.deviceId",
+			"intervalMs": 50,
+			"idleMs":     50,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "1"},
+			{MetaData: bb, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "2"},
+		}
+		test.NewNodeCollector(node).OnMsg(node, msgList...)
+		throttleNode := node.(*ThrottleNode)
+		states := func() int {
+			throttleNode.mu.Lock()
+			defer throttleNode.mu.Unlock()
+			return len(throttleNode.states)
+		}
+		assert.Equal(t, 2, states())
+
+		//等待空闲的分组被清除
+		deadline := time.Now().Add(test.DefaultWaitTimeout)
+		for states() > 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 10)
+		}
+		assert.Equal(t, 0, states())
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":        "metadata.deviceId",
+			"mode":       ThrottleDebounce,
+			"intervalMs": 60000,
+		}, Registry)
+		assert.Nil(t, err)
+		defer node.Destroy()
+		var msgList = []test.Msg{
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "1"},
+			{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "2"},
+		}
+		collector := test.NewNodeCollector(node).OnMsg(node, msgList...)
+		assert.Equal(t, map[string]string{"1": types.Suppressed}, relations(collector.Results()))
+
+		var persisted []types.RuleMsg
+		node.(types.DrainableNode).Drain(func(msg types.RuleMsg) {
+			persisted = append(persisted, msg)
+		})
+		assert.Equal(t, 1, len(persisted))
+		assert.Equal(t, "2", persisted[0].Data)
+
+		//排空后不再挂起消息
+		collector.OnMsg(node, test.Msg{MetaData: aa, MsgType: "TELEMETRY", DataType: types.TEXT, Data: "3"})
+		assert.Equal(t, 1, len(collector.Results()))
+		assert.Equal(t, 2, len(persisted))
+	})
+}
@@ -68,19 +68,19 @@ const (
 // RestApiCallNodeConfiguration rest配置
 type RestApiCallNodeConfiguration struct {
 	//RestEndpointUrlPattern HTTP URL地址,可以使用 ${metadata.key} 读取元数据中的变量或者使用 ${msg.key} 读取消息负荷中的变量进行替换
-	RestEndpointUrlPattern string
+	RestEndpointUrlPattern string `label:"URL" desc:"supports ${metadata.key} and ${msg.key}" required:"true" i18n:"restApiCall.restEndpointUrlPattern"`
 	//RequestMethod 请求方法，默认POST
-	RequestMethod string
+	RequestMethod string `label:"Method" enum:"GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS" i18n:"restApiCall.requestMethod"`
 	// Without request body
 	WithoutRequestBody bool
 	//Headers 请求头,可以使用 ${metadata.key} 读取元数据中的变量或者使用 ${msg.key} 读取消息负荷中的变量进行替换
-	Headers map[string]string
+	Headers map[string]string `label:"Headers" desc:"supports ${metadata.key} and ${msg.key}" i18n:"restApiCall.headers"`
 	//ReadTimeoutMs 超时，单位毫秒，默认0:不限制
-	ReadTimeoutMs int
+	ReadTimeoutMs int `label:"Read timeout(ms)" desc:"0 means no limit" min:"0" i18n:"restApiCall.readTimeoutMs"`
 	//禁用证书验证
 	InsecureSkipVerify bool
 	//MaxParallelRequestsCount 连接池大小，默认200。0代表不限制
-	MaxParallelRequestsCount int
+	MaxParallelRequestsCount int `label:"Max parallel requests" desc:"0 means no limit" min:"0" i18n:"restApiCall.maxParallelRequestsCount"`
 	//EnableProxy 是否开启代理
 	EnableProxy bool
 	//UseSystemProxyProperties 使用系统配置代理
@@ -94,7 +94,7 @@ type RestApiCallNodeConfiguration struct {
 	//ProxyUser 代理用户名
 	ProxyUser string
 	//ProxyPassword 代理密码
-	ProxyPassword string
+	ProxyPassword string `widget:"password"`
 }
 
 // RestApiCallNode 将通过REST API调用GET | POST | PUT | DELETE到外部REST服务。
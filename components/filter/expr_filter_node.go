@@ -41,7 +41,7 @@ func init() {
 // ExprFilterNodeConfiguration 节点配置
 type ExprFilterNodeConfiguration struct {
 	// 表达式
-	Expr string
+	Expr string `label:"Expression" desc:"expr expression returning bool" required:"true" widget:"code" i18n:"exprFilter.expr"`
 }
 
 // ExprFilterNode 使用expr表达式过滤消息
@@ -0,0 +1,527 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "geofence",
+//        "name": "电子围栏",
+//        "debugMode": false,
+//        "configuration": {
+//          "entityKey": "metadata.deviceId",
+//          "latitudeField": "msg.latitude",
+//          "longitudeField": "msg.longitude",
+//          "perimeter": "{\"type\":\"Polygon\",\"coordinates\":[[[116.30,39.90],[116.40,39.90],[116.40,40.00],[116.30,40.00],[116.30,39.90]]]}",
+//          "minInsideMs": 60000,
+//          "minOutsideMs": 60000
+//        }
+//  }
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/cache"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+const (
+	// KeyGeofenceInside 元数据中当前位置是否在围栏内
+	KeyGeofenceInside = "geofenceInside"
+	// KeyGeofenceDistance 元数据中当前位置到围栏边界的距离，单位米
+	KeyGeofenceDistance = "geofenceDistance"
+	// KeyGeofenceDwell 元数据中实体在当前状态(围栏内或者围栏外)的停留时间，单位毫秒
+	KeyGeofenceDwell = "geofenceDwellMs"
+)
+
+// earthRadius 地球平均半径，单位米
+const earthRadius = 6371008.8
+
+// 注册节点
+func init() {
+	Registry.Add(&GeofenceNode{})
+}
+
+// GeofenceNodeConfiguration 节点配置
+type GeofenceNodeConfiguration struct {
+	//EntityKey 实体key表达式，每个实体维护独立的进出状态，可以访问msg、metadata、id、type等变量，默认：metadata.deviceId
+	EntityKey string
+	//LatitudeField 纬度表达式，默认：msg.latitude
+	LatitudeField string `required:"true"`
+	//LongitudeField 经度表达式，默认：msg.longitude
+	LongitudeField string `required:"true"`
+	//Perimeter GeoJSON格式的围栏，支持Polygon、MultiPolygon、Point(圆形)、Feature、FeatureCollection和GeometryCollection
+	//Point表示圆形围栏，半径取自Feature的properties.radius，或者Radius配置
+	Perimeter string `widget:"code"`
+	//PerimeterKey 围栏所在的元数据key，如果消息元数据中存在该key，则使用元数据中的围栏代替Perimeter
+	PerimeterKey string
+	//Radius 圆形围栏的默认半径，单位米
+	Radius float64 `min:"0"`
+	//MinInsideMs 进入围栏后，至少停留该时间才确认进入，单位毫秒，默认0
+	MinInsideMs int64 `min:"0"`
+	//MinOutsideMs 离开围栏后，至少停留该时间才确认离开，单位毫秒，默认0
+	MinOutsideMs int64 `min:"0"`
+}
+
+// GeofenceNode 电子围栏节点，检查消息中的经纬度是否在多边形或者圆形围栏内，并按照实体维护进出状态
+// 确认进入围栏通过`Entered`链路发送到下一个节点，确认离开围栏通过`Left`链路发送
+// 否则按照实体当前确认的状态通过`Inside`或者`Outside`链路发送
+// 实体第一次出现时视为在围栏外，使用消息时间戳计算停留时间
+// 元数据增加geofenceInside、geofenceDistance(到围栏边界的距离，单位米)和geofenceDwellMs
+// 每个实体的进出状态和停留起始时间保存在 types.Config.StateStore 中，节点销毁或者规则链重新加载后继续使用
+// 默认存储在进程内，不会淘汰，但是重启后丢失，可以通过 types.WithStateStore 替换为持久化或者分布式存储
+// 状态丢失的实体会被当作第一次出现，可能再次触发`Entered`并且重新计算停留时间
+// 经纬度不存在、不是数值或者围栏格式错误，则发送到`Failure`链
+type GeofenceNode struct {
+	//节点配置
+	Config    GeofenceNodeConfiguration
+	entityKey *vm.Program
+	latitude  *vm.Program
+	longitude *vm.Program
+	//配置的围栏
+	perimeter []geoShape
+	//状态存储
+	store types.Cache
+	mu    sync.Mutex
+}
+
+// geofenceState 实体的进出状态
+type geofenceState struct {
+	//确认的状态是否在围栏内
+	Inside bool `json:"inside"`
+	//确认的状态开始时间
+	Since int64 `json:"since"`
+	//位置越过边界，等待确认的开始时间，0表示没有等待确认
+	PendingSince int64 `json:"pendingSince,omitempty"`
+}
+
+// Type 组件类型
+func (x *GeofenceNode) Type() string {
+	return "geofence"
+}
+
+func (x *GeofenceNode) New() types.Node {
+	return &GeofenceNode{Config: GeofenceNodeConfiguration{
+		EntityKey:      "metadata.deviceId",
+		LatitudeField:  "msg.latitude",
+		LongitudeField: "msg.longitude",
+		PerimeterKey:   "perimeter",
+	}}
+}
+
+// Init 初始化
+func (x *GeofenceNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.EntityKey) == "" {
+		x.Config.EntityKey = "metadata.deviceId"
+	}
+	if strings.TrimSpace(x.Config.LatitudeField) == "" {
+		x.Config.LatitudeField = "msg.latitude"
+	}
+	if strings.TrimSpace(x.Config.LongitudeField) == "" {
+		x.Config.LongitudeField = "msg.longitude"
+	}
+	if x.entityKey, err = expr.Compile(x.Config.EntityKey, expr.AllowUndefinedVariables()); err != nil {
+		return err
+	}
+	if x.latitude, err = expr.Compile(x.Config.LatitudeField, expr.AllowUndefinedVariables()); err != nil {
+		return err
+	}
+	if x.longitude, err = expr.Compile(x.Config.LongitudeField, expr.AllowUndefinedVariables()); err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.Perimeter) != "" {
+		if x.perimeter, err = parseGeoJSON(x.Config.Perimeter, x.Config.Radius); err != nil {
+			return err
+		}
+	} else if x.Config.PerimeterKey == "" {
+		return errors.New("perimeter is empty")
+	}
+	if ruleConfig.StateStore != nil {
+		x.store = ruleConfig.StateStore
+	} else {
+		x.store = cache.NewMemoryCache(0)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GeofenceNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	evn := base.NodeUtils.GetEvn(ctx, msg)
+	lat, err := x.getCoordinate(x.latitude, "latitude", evn)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	lon, err := x.getCoordinate(x.longitude, "longitude", evn)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	perimeter := x.perimeter
+	if v := msg.Metadata.GetValue(x.Config.PerimeterKey); v != "" {
+		if perimeter, err = parseGeoJSON(v, x.Config.Radius); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+	}
+	if len(perimeter) == 0 {
+		ctx.TellFailure(msg, errors.New("perimeter is empty"))
+		return
+	}
+	out, err := vm.Run(x.entityKey, evn)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	key := x.storeKey(ctx, str.ToString(out))
+
+	inside := false
+	distance := math.Inf(1)
+	for _, shape := range perimeter {
+		if shape.contains(lat, lon) {
+			inside = true
+		}
+		distance = math.Min(distance, shape.distance(lat, lon))
+	}
+
+	x.mu.Lock()
+	state := x.load(key, msg.Ts)
+	relationType := x.transit(state, inside, msg.Ts)
+	err = x.save(key, state)
+	x.mu.Unlock()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(KeyGeofenceInside, strconv.FormatBool(inside))
+	msg.Metadata.PutValue(KeyGeofenceDistance, strconv.FormatFloat(distance, 'f', 2, 64))
+	msg.Metadata.PutValue(KeyGeofenceDwell, strconv.FormatInt(msg.Ts-state.Since, 10))
+	ctx.TellNext(msg, relationType)
+}
+
+// Destroy 销毁，进出状态保留在存储中，以便规则链重新加载后继续使用
+func (x *GeofenceNode) Destroy() {
+}
+
+// transit 根据当前位置更新实体状态，返回路由关系
+func (x *GeofenceNode) transit(state *geofenceState, inside bool, ts int64) string {
+	if inside != state.Inside {
+		if state.PendingSince == 0 {
+			state.PendingSince = ts
+		}
+		minDuration := x.Config.MinOutsideMs
+		if inside {
+			minDuration = x.Config.MinInsideMs
+		}
+		if ts-state.PendingSince >= minDuration {
+			state.Inside = inside
+			state.Since = state.PendingSince
+			state.PendingSince = 0
+			if inside {
+				return types.Entered
+			}
+			return types.Left
+		}
+	} else {
+		state.PendingSince = 0
+	}
+	if state.Inside {
+		return types.Inside
+	}
+	return types.Outside
+}
+
+// getCoordinate 获取经纬度
+func (x *GeofenceNode) getCoordinate(program *vm.Program, name string, evn map[string]interface{}) (float64, error) {
+	out, err := vm.Run(program, evn)
+	if err != nil {
+		return 0, err
+	}
+	if out == nil {
+		return 0, fmt.Errorf("%s not found", name)
+	}
+	v, err := strconv.ParseFloat(str.ToString(out), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s %v is not a number", name, out)
+	}
+	return v, nil
+}
+
+// load 从存储中获取实体状态，不存在则视为从ts开始在围栏外
+func (x *GeofenceNode) load(key string, ts int64) *geofenceState {
+	if v, ok := x.store.Get(key); ok {
+		var state geofenceState
+		if err := json.Unmarshal([]byte(str.ToString(v)), &state); err == nil {
+			return &state
+		}
+	}
+	return &geofenceState{Since: ts}
+}
+
+// save 保存实体状态
+func (x *GeofenceNode) save(key string, state *geofenceState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return x.store.Set(key, string(data), 0)
+}
+
+// storeKey 获取状态存储key，以规则链ID和节点ID作为前缀，避免不同节点之间冲突
+func (x *GeofenceNode) storeKey(ctx types.RuleContext, key string) string {
+	var chainId string
+	if ctx.RuleChain() != nil {
+		chainId = ctx.RuleChain().GetNodeId().Id
+	}
+	return "geofence:" + chainId + ":" + ctx.GetSelfId() + ":" + key
+}
+
+// geoShape 围栏形状
+type geoShape interface {
+	//contains 位置是否在围栏内
+	contains(lat, lon float64) bool
+	//distance 位置到围栏边界的距离，单位米
+	distance(lat, lon float64) float64
+}
+
+// geoPolygon 多边形围栏，第一个环是外边界，其他环是洞，坐标顺序是[经度,纬度]
+type geoPolygon [][][2]float64
+
+// geoCircle 圆形围栏
+type geoCircle struct {
+	lat, lon float64
+	//半径，单位米
+	radius float64
+}
+
+func (p geoPolygon) contains(lat, lon float64) bool {
+	if !ringContains(p[0], lat, lon) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if ringContains(hole, lat, lon) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p geoPolygon) distance(lat, lon float64) float64 {
+	//以当前位置为原点，使用等距投影把经纬度转换成平面坐标，单位米
+	scaleY := earthRadius * math.Pi / 180
+	scaleX := scaleY * math.Cos(lat*math.Pi/180)
+	d := math.Inf(1)
+	for _, ring := range p {
+		for i := 0; i+1 < len(ring); i++ {
+			ax, ay := (ring[i][0]-lon)*scaleX, (ring[i][1]-lat)*scaleY
+			bx, by := (ring[i+1][0]-lon)*scaleX, (ring[i+1][1]-lat)*scaleY
+			d = math.Min(d, segmentDistance(ax, ay, bx, by))
+		}
+	}
+	return d
+}
+
+func (c geoCircle) contains(lat, lon float64) bool {
+	return haversine(lat, lon, c.lat, c.lon) <= c.radius
+}
+
+func (c geoCircle) distance(lat, lon float64) float64 {
+	return math.Abs(haversine(lat, lon, c.lat, c.lon) - c.radius)
+}
+
+// ringContains 射线法判断位置是否在环内
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// segmentDistance 原点到线段AB的距离
+func segmentDistance(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if lengthSquare := dx*dx + dy*dy; lengthSquare > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSquare))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
+
+// haversine 两个位置之间的球面距离，单位米
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// geoJSON GeoJSON对象
+type geoJSON struct {
+	Type        string                 `json:"type"`
+	Coordinates interface{}            `json:"coordinates"`
+	Geometry    *geoJSON               `json:"geometry"`
+	Geometries  []geoJSON              `json:"geometries"`
+	Features    []geoJSON              `json:"features"`
+	Properties  map[string]interface{} `json:"properties"`
+}
+
+// parseGeoJSON 解析GeoJSON围栏，radius是Point没有指定properties.radius时使用的半径
+func parseGeoJSON(data string, radius float64) ([]geoShape, error) {
+	var object geoJSON
+	if err := json.Unmarshal([]byte(data), &object); err != nil {
+		return nil, fmt.Errorf("invalid perimeter: %w", err)
+	}
+	shapes, err := object.shapes(radius)
+	if err != nil {
+		return nil, fmt.Errorf("invalid perimeter: %w", err)
+	}
+	return shapes, nil
+}
+
+// shapes 转换成围栏形状
+func (g *geoJSON) shapes(radius float64) ([]geoShape, error) {
+	var shapes []geoShape
+	switch g.Type {
+	case "FeatureCollection":
+		for i := range g.Features {
+			items, err := g.Features[i].shapes(radius)
+			if err != nil {
+				return nil, err
+			}
+			shapes = append(shapes, items...)
+		}
+	case "Feature":
+		if g.Geometry == nil {
+			return nil, errors.New("feature geometry is empty")
+		}
+		if v, ok := g.Properties["radius"]; ok {
+			r, err := strconv.ParseFloat(str.ToString(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("radius %v is not a number", v)
+			}
+			radius = r
+		}
+		return g.Geometry.shapes(radius)
+	case "GeometryCollection":
+		for i := range g.Geometries {
+			items, err := g.Geometries[i].shapes(radius)
+			if err != nil {
+				return nil, err
+			}
+			shapes = append(shapes, items...)
+		}
+	case "Polygon":
+		polygon, err := toPolygon(g.Coordinates)
+		if err != nil {
+			return nil, err
+		}
+		shapes = append(shapes, polygon)
+	case "MultiPolygon":
+		items, ok := g.Coordinates.([]interface{})
+		if !ok {
+			return nil, errors.New("multi polygon coordinates must be an array")
+		}
+		for _, item := range items {
+			polygon, err := toPolygon(item)
+			if err != nil {
+				return nil, err
+			}
+			shapes = append(shapes, polygon)
+		}
+	case "Point":
+		point, err := toPosition(g.Coordinates)
+		if err != nil {
+			return nil, err
+		}
+		if radius <= 0 {
+			return nil, errors.New("circle radius must be greater than 0")
+		}
+		shapes = append(shapes, geoCircle{lat: point[1], lon: point[0], radius: radius})
+	default:
+		return nil, fmt.Errorf("unsupported geometry type: %s", g.Type)
+	}
+	if len(shapes) == 0 {
+		return nil, errors.New("no geometry found")
+	}
+	return shapes, nil
+}
+
+// toPolygon 转换多边形坐标
+func toPolygon(v interface{}) (geoPolygon, error) {
+	rings, ok := v.([]interface{})
+	if !ok || len(rings) == 0 {
+		return nil, errors.New("polygon coordinates must be a non-empty array of rings")
+	}
+	polygon := make(geoPolygon, 0, len(rings))
+	for _, item := range rings {
+		positions, ok := item.([]interface{})
+		if !ok || len(positions) < 3 {
+			return nil, errors.New("polygon ring must have at least 3 positions")
+		}
+		ring := make([][2]float64, 0, len(positions))
+		for _, position := range positions {
+			point, err := toPosition(position)
+			if err != nil {
+				return nil, err
+			}
+			ring = append(ring, point)
+		}
+		//闭合环
+		if ring[0] != ring[len(ring)-1] {
+			ring = append(ring, ring[0])
+		}
+		polygon = append(polygon, ring)
+	}
+	return polygon, nil
+}
+
+// toPosition 转换[经度,纬度]坐标
+func toPosition(v interface{}) ([2]float64, error) {
+	values, ok := v.([]interface{})
+	if !ok || len(values) < 2 {
+		return [2]float64{}, errors.New("position must be [longitude, latitude]")
+	}
+	var point [2]float64
+	for i := 0; i < 2; i++ {
+		f, ok := values[i].(float64)
+		if !ok {
+			return [2]float64{}, errors.New("position must be [longitude, latitude]")
+		}
+		point[i] = f
+	}
+	return point, nil
+}
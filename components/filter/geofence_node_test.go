@@ -0,0 +1,205 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/cache"
+)
+
+// 经度116.30~116.40，纬度39.90~40.00的矩形围栏
+const squarePerimeter = `{"type":"Polygon","coordinates":[[[116.30,39.90],[116.40,39.90],[116.40,40.00],[116.30,40.00],[116.30,39.90]]]}`
+
+func TestGeofenceNode(t *testing.T) {
+	var targetNodeType = "geofence"
+	d1 := types.BuildMetadata(map[string]string{"deviceId": "d1"})
+	d2 := types.BuildMetadata(map[string]string{"deviceId": "d2"})
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GeofenceNode{}, types.Configuration{
+			"entityKey":      "metadata.deviceId",
+			"latitudeField":  "msg.latitude",
+			"longitudeField": "msg.longitude",
+			"perimeterKey":   "perimeter",
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"latitudeField":  "msg.lat",
+			"longitudeField": "msg.lng",
+			"perimeter":      squarePerimeter,
+			"minInsideMs":    60000,
+		}, types.Configuration{
+			"entityKey":      "metadata.deviceId",
+			"latitudeField":  "msg.lat",
+			"longitudeField": "msg.lng",
+			"perimeter":      squarePerimeter,
+			"minInsideMs":    int64(60000),
+		}, Registry)
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"perimeter": "{",
+		}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"perimeter": `{"type":"LineString","coordinates":[[116.30,39.90],[116.40,39.90]]}`,
+		}, Registry)
+		assert.Equal(t, "invalid perimeter: unsupported geometry type: LineString", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"perimeter": `{"type":"Point","coordinates":[116.35,39.95]}`,
+		}, Registry)
+		assert.Equal(t, "invalid perimeter: circle radius must be greater than 0", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"perimeterKey": "",
+		}, Registry)
+		assert.Equal(t, "perimeter is empty", err.Error())
+	})
+
+	t.Run("Polygon", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"perimeter":   squarePerimeter,
+			"minInsideMs": 1500,
+		}, Registry)
+		assert.Nil(t, err)
+		var msgList = []test.Msg{
+			{Ts: 1000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.50}"},
+			{Ts: 2000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.35}"},
+			{Ts: 3000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.35}"},
+			{Ts: 4000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.35}"},
+			{Ts: 5000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.35}"},
+			{Ts: 6000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.45}"},
+			{Ts: 6500, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.35}"},
+			{Ts: 7000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.45}"},
+			{Ts: 7000, MetaData: d2, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.35}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 9, len(results))
+		var relationTypes []string
+		for _, item := range results {
+			relationTypes = append(relationTypes, item.RelationType)
+		}
+		assert.Equal(t, []string{types.Outside, types.Outside, types.Outside, types.Entered, types.Inside,
+			types.Left, types.Outside, types.Outside, types.Outside}, relationTypes)
+
+		//0.1经度在纬度39.95处约8.5km
+		assertDistance(t, 8530, results[0].Msg)
+		assert.Equal(t, "false", results[0].Msg.Metadata.GetValue(KeyGeofenceInside))
+		assert.Equal(t, "true", results[1].Msg.Metadata.GetValue(KeyGeofenceInside))
+		//到最近的边界(经度116.30或者116.40)约4.3km
+		assertDistance(t, 4261, results[1].Msg)
+		assert.Equal(t, "2000", results[3].Msg.Metadata.GetValue(KeyGeofenceDwell))
+		assert.Equal(t, "3000", results[4].Msg.Metadata.GetValue(KeyGeofenceDwell))
+		assert.Equal(t, "0", results[5].Msg.Metadata.GetValue(KeyGeofenceDwell))
+		assert.Equal(t, "1000", results[7].Msg.Metadata.GetValue(KeyGeofenceDwell))
+	})
+
+	t.Run("CircleAndHole", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"perimeter": `{"type":"FeatureCollection","features":[
+				{"type":"Feature","properties":{"radius":1000},"geometry":{"type":"Point","coordinates":[116.35,39.80]}},
+				{"type":"Feature","geometry":{"type":"Polygon","coordinates":[
+					[[116.30,39.90],[116.40,39.90],[116.40,40.00],[116.30,40.00]],
+					[[116.34,39.94],[116.36,39.94],[116.36,39.96],[116.34,39.96]]]}}]}`,
+		}, Registry)
+		assert.Nil(t, err)
+		var msgList = []test.Msg{
+			{Ts: 1000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.805,\"longitude\":116.35}"},
+			{Ts: 2000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.82,\"longitude\":116.35}"},
+			{Ts: 3000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.35}"},
+			{Ts: 4000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.93,\"longitude\":116.35}"},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 4, len(results))
+		assert.Equal(t, types.Entered, results[0].RelationType)
+		//圆心往北0.005纬度约556m
+		assertDistance(t, 444, results[0].Msg)
+		assert.Equal(t, types.Left, results[1].RelationType)
+		assertDistance(t, 1224, results[1].Msg)
+		//在洞里
+		assert.Equal(t, types.Outside, results[2].RelationType)
+		assertDistance(t, 852, results[2].Msg)
+		assert.Equal(t, types.Entered, results[3].RelationType)
+	})
+
+	t.Run("Store", func(t *testing.T) {
+		store := cache.NewMemoryCache(10)
+		config := types.NewConfig(types.WithStateStore(store))
+		configuration := types.Configuration{"perimeter": squarePerimeter}
+		node := test.InitNodeByConfig(config, targetNodeType, configuration, Registry)
+		results := test.NewNodeCollector(node).OnMsg(node,
+			test.Msg{Ts: 1000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.35}"}).Results()
+		assert.Equal(t, types.Entered, results[0].RelationType)
+		assert.Equal(t, 1, store.Len())
+		node.Destroy()
+
+		//新节点实例从存储中恢复状态，不会重复进入，停留时间继续计算
+		node = test.InitNodeByConfig(config, targetNodeType, configuration, Registry)
+		results = test.NewNodeCollector(node).OnMsg(node,
+			test.Msg{Ts: 3000, MetaData: d1, MsgType: "LOCATION", Data: "{\"latitude\":39.95,\"longitude\":116.35}"}).Results()
+		assert.Equal(t, types.Inside, results[0].RelationType)
+		assert.Equal(t, "2000", results[0].Msg.Metadata.GetValue(KeyGeofenceDwell))
+	})
+
+	t.Run("MetadataPerimeter", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"latitudeField":  "msg.lat",
+			"longitudeField": "msg.lng",
+		}, Registry)
+		assert.Nil(t, err)
+		var msgList = []test.Msg{
+			{
+				MetaData: types.BuildMetadata(map[string]string{"deviceId": "d1", "perimeter": squarePerimeter}),
+				Data:     "{\"lat\":39.95,\"lng\":116.35}",
+			},
+			{
+				MetaData: types.BuildMetadata(map[string]string{"deviceId": "d1"}),
+				Data:     "{\"lat\":39.95,\"lng\":116.35}",
+			},
+			{
+				MetaData: types.BuildMetadata(map[string]string{"deviceId": "d1", "perimeter": "[1]"}),
+				Data:     "{\"lat\":39.95,\"lng\":116.35}",
+			},
+			{
+				MetaData: types.BuildMetadata(map[string]string{"deviceId": "d1", "perimeter": squarePerimeter}),
+				Data:     "{\"lat\":\"north\",\"lng\":116.35}",
+			},
+		}
+		results := test.NewNodeCollector(node).OnMsg(node, msgList...).Results()
+		assert.Equal(t, 4, len(results))
+		assert.Equal(t, types.Entered, results[0].RelationType)
+		assert.Equal(t, types.Failure, results[1].RelationType)
+		assert.Equal(t, "perimeter is empty", results[1].Err.Error())
+		assert.Equal(t, types.Failure, results[2].RelationType)
+		assert.Equal(t, types.Failure, results[3].RelationType)
+		assert.Equal(t, "latitude north is not a number", results[3].Err.Error())
+	})
+}
+
+// assertDistance 检查到围栏边界的距离，允许1%误差
+func assertDistance(t *testing.T, expected float64, msg types.RuleMsg) {
+	distance, err := strconv.ParseFloat(msg.Metadata.GetValue(KeyGeofenceDistance), 64)
+	assert.Nil(t, err)
+	if math.Abs(distance-expected) > expected*0.01 {
+		t.Errorf("distance %v, expected %v", distance, expected)
+	}
+}
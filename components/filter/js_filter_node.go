@@ -46,7 +46,7 @@ type JsFilterNodeConfiguration struct {
 	//完整脚本函数：
 	//function Filter(msg, metadata, msgType) { ${JsScript} }
 	//return bool
-	JsScript string
+	JsScript string `label:"Script" desc:"function Filter(msg, metadata, msgType) { ${jsScript} }, returns bool" required:"true" widget:"js" i18n:"jsFilter.jsScript"`
 }
 
 // JsFilterNode 使用js脚本过滤传入信息
@@ -0,0 +1,235 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "router",
+//        "name": "路由",
+//        "debugMode": false,
+//        "configuration": {
+//          "mode": "all",
+//          "routes": [
+//            {"case": "msg.temperature > 50", "then": "alarm"},
+//            {"case": "msg.humidity > 80", "then": "humidity"},
+//            {"then": "archive"}
+//          ]
+//        }
+//  }
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+const (
+	// RouteAll 发送到所有匹配的路由
+	RouteAll = "all"
+	// RouteWeighted 在匹配的路由中按照权重随机选择一个
+	RouteWeighted = "weighted"
+	// RouteRoundRobin 在匹配的路由中轮询选择一个
+	RouteRoundRobin = "roundRobin"
+	// RouteHash 在匹配的路由中按照hashKey表达式的哈希值选择一个，相同key总是选择相同的路由
+	RouteHash = "hash"
+)
+
+func init() {
+	Registry.Add(&RouterNode{})
+}
+
+// 确保RouterNode实现了types.ComponentDefGetter接口
+var _ types.ComponentDefGetter = (*RouterNode)(nil)
+
+// RouterNodeConfiguration 节点配置
+type RouterNodeConfiguration struct {
+	//Mode 路由模式：all(所有匹配的路由)、weighted(加权随机)、roundRobin(轮询)、hash(粘性哈希)，默认all
+	Mode string `enum:"all,weighted,roundRobin,hash"`
+	//Routes 路由列表
+	Routes []Route `required:"true"`
+	//HashKey hash模式的key表达式，可以访问msg、metadata、id、type等变量，例如：metadata.deviceId
+	HashKey string
+}
+
+// Route 路由
+type Route struct {
+	//Case 条件表达式，为空表示总是匹配，可以访问的变量和switch节点的case一样
+	Case string `json:"case"`
+	//Then 路由关系，把消息转发到对应的路由链
+	Then string `json:"then"`
+	//Weight weighted模式的权重，默认1
+	Weight int `json:"weight"`
+}
+
+// RouterNode 基于内容的路由节点，先找出case表达式匹配的路由，再根据路由模式转发消息：
+// all模式把消息转发到所有匹配的路由链；weighted、roundRobin和hash模式在匹配的路由中选择一个转发，用于在并行分支之间分摊负载
+// 如果没有匹配的路由，则转发到默认的"Default"链
+// 如果表达式执行失败则发送到`Failure`链
+type RouterNode struct {
+	//节点配置
+	Config RouterNodeConfiguration
+	cases  []*vm.Program
+	//hash模式key表达式
+	hashKey *vm.Program
+	//roundRobin模式计数器
+	counter uint64
+}
+
+// Type 组件类型
+func (x *RouterNode) Type() string {
+	return "router"
+}
+
+func (x *RouterNode) New() types.Node {
+	return &RouterNode{Config: RouterNodeConfiguration{
+		Mode: RouteAll,
+		Routes: []Route{
+			{Case: "msg.temperature > 50", Then: "Case1", Weight: 1},
+			{Case: "msg.humidity > 80", Then: "Case2", Weight: 1},
+		},
+	}}
+}
+
+// Def 路由链名称由用户定义
+func (x *RouterNode) Def() types.ComponentForm {
+	relationTypes := []string{}
+	return types.ComponentForm{RelationTypes: &relationTypes}
+}
+
+// Init 初始化
+func (x *RouterNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	//列表配置不和默认值合并
+	x.Config = RouterNodeConfiguration{}
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	switch x.Config.Mode {
+	case "":
+		x.Config.Mode = RouteAll
+	case RouteAll, RouteWeighted, RouteRoundRobin:
+	case RouteHash:
+		if strings.TrimSpace(x.Config.HashKey) == "" {
+			return errors.New("hashKey is empty")
+		}
+		if x.hashKey, err = expr.Compile(x.Config.HashKey, expr.AllowUndefinedVariables()); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported router mode: %s", x.Config.Mode)
+	}
+	if len(x.Config.Routes) == 0 {
+		return errors.New("routes is empty")
+	}
+	x.cases = make([]*vm.Program, len(x.Config.Routes))
+	for i := range x.Config.Routes {
+		route := &x.Config.Routes[i]
+		if route.Then == "" {
+			return fmt.Errorf("route %d then is empty", i)
+		}
+		if route.Weight < 0 {
+			return fmt.Errorf("route %s weight must be greater than or equal to 0", route.Then)
+		} else if route.Weight == 0 {
+			route.Weight = 1
+		}
+		if strings.TrimSpace(route.Case) != "" {
+			if x.cases[i], err = expr.Compile(route.Case, expr.AllowUndefinedVariables(), expr.AsBool()); err != nil {
+				return fmt.Errorf("route %s: %w", route.Then, err)
+			}
+		}
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *RouterNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	evn := base.NodeUtils.GetEvn(ctx, msg)
+	var matched []int
+	for i, program := range x.cases {
+		if program == nil {
+			matched = append(matched, i)
+		} else if out, err := vm.Run(program, evn); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		} else if result, ok := out.(bool); ok && result {
+			matched = append(matched, i)
+		}
+	}
+	if len(matched) == 0 {
+		//没匹配到，默认转发到Default链
+		ctx.TellNext(msg, KeyDefaultRelationType)
+		return
+	}
+	switch x.Config.Mode {
+	case RouteWeighted:
+		ctx.TellNext(msg, x.weighted(matched))
+	case RouteRoundRobin:
+		index := atomic.AddUint64(&x.counter, 1) - 1
+		ctx.TellNext(msg, x.Config.Routes[matched[index%uint64(len(matched))]].Then)
+	case RouteHash:
+		out, err := vm.Run(x.hashKey, evn)
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(str.ToString(out)))
+		ctx.TellNext(msg, x.Config.Routes[matched[h.Sum32()%uint32(len(matched))]].Then)
+	default:
+		//相同的路由关系只转发一次
+		var relationTypes []string
+		seen := make(map[string]struct{}, len(matched))
+		for _, i := range matched {
+			then := x.Config.Routes[i].Then
+			if _, ok := seen[then]; !ok {
+				seen[then] = struct{}{}
+				relationTypes = append(relationTypes, then)
+			}
+		}
+		ctx.TellNext(msg, relationTypes...)
+	}
+}
+
+// Destroy 销毁
+func (x *RouterNode) Destroy() {
+}
+
+// weighted 在匹配的路由中按照权重随机选择一个，返回路由关系
+func (x *RouterNode) weighted(matched []int) string {
+	total := 0
+	for _, i := range matched {
+		total += x.Config.Routes[i].Weight
+	}
+	n := rand.Intn(total)
+	for _, i := range matched {
+		if n < x.Config.Routes[i].Weight {
+			return x.Config.Routes[i].Then
+		}
+		n -= x.Config.Routes[i].Weight
+	}
+	return x.Config.Routes[matched[len(matched)-1]].Then
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/reflect"
+)
+
+func TestRouterNode(t *testing.T) {
+	var targetNodeType = "router"
+	//统计每个路由关系的消息数量
+	countRelations := func(node types.Node, msgList ...test.Msg) map[string]int {
+		var counts = make(map[string]int)
+		for _, item := range test.NewNodeCollector(node).OnMsg(node, msgList...).Results() {
+			counts[item.RelationType]++
+		}
+		return counts
+	}
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &RouterNode{}, types.Configuration{
+			"mode": RouteAll,
+		}, Registry)
+		componentForm := reflect.GetComponentForm(&RouterNode{})
+		assert.Equal(t, 0, len(*componentForm.RelationTypes))
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode":   RouteWeighted,
+			"routes": []interface{}{map[string]interface{}{"then": "a", "weight": 3}, map[string]interface{}{"then": "b"}},
+		}, Registry)
+		assert.Nil(t, err)
+		routerNode := node.(*RouterNode)
+		assert.Equal(t, []Route{{Then: "a", Weight: 3}, {Then: "b", Weight: 1}}, routerNode.Config.Routes)
+
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode": "random",
+		}, Registry)
+		assert.Equal(t, "unsupported router mode: random", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode":   RouteHash,
+			"routes": []interface{}{map[string]interface{}{"then": "a"}},
+		}, Registry)
+		assert.Equal(t, "hashKey is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.Equal(t, "routes is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"routes": []interface{}{map[string]interface{}{"case": "msg.a >"}},
+		}, Registry)
+		assert.Equal(t, "route 0 then is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"routes": []interface{}{map[string]interface{}{"case": "msg.a >", "then": "a"}},
+		}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"routes": []interface{}{map[string]interface{}{"then": "a", "weight": -1}},
+		}, Registry)
+		assert.Equal(t, "route a weight must be greater than or equal to 0", err.Error())
+	})
+
+	t.Run("All", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"routes": []interface{}{
+				map[string]interface{}{"case": "msg.temperature > 50", "then": "alarm"},
+				map[string]interface{}{"case": "msg.humidity > 80", "then": "humidity"},
+				map[string]interface{}{"case": "msg.humidity > 90", "then": "humidity"},
+				map[string]interface{}{"case": "msg.temperature > 100", "then": "fire"},
+			},
+		}, Registry)
+		assert.Nil(t, err)
+		results := countRelations(node, test.Msg{MsgType: "TELEMETRY", Data: "{\"temperature\":60,\"humidity\":95}"})
+		assert.Equal(t, map[string]int{"alarm": 1, "humidity": 1}, results)
+
+		results = countRelations(node, test.Msg{MsgType: "TELEMETRY", Data: "{\"temperature\":20,\"humidity\":50}"})
+		assert.Equal(t, map[string]int{KeyDefaultRelationType: 1}, results)
+
+		results = countRelations(node, test.Msg{MsgType: "TELEMETRY", Data: "{\"temperature\":\"a\"}"})
+		assert.Equal(t, map[string]int{types.Failure: 1}, results)
+	})
+
+	t.Run("Weighted", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode": RouteWeighted,
+			"routes": []interface{}{
+				map[string]interface{}{"then": "a", "weight": 3},
+				map[string]interface{}{"then": "b", "weight": 1},
+				map[string]interface{}{"case": "msg.temperature > 50", "then": "c", "weight": 100},
+			},
+		}, Registry)
+		assert.Nil(t, err)
+		var msgList []test.Msg
+		for i := 0; i < 400; i++ {
+			msgList = append(msgList, test.Msg{Data: "{\"temperature\":20}"})
+		}
+		results := countRelations(node, msgList...)
+		assert.Equal(t, 400, results["a"]+results["b"])
+		assert.Equal(t, 0, results["c"])
+		assert.True(t, results["a"] > results["b"])
+		assert.True(t, results["b"] > 0)
+	})
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode": RouteRoundRobin,
+			"routes": []interface{}{
+				map[string]interface{}{"then": "a"},
+				map[string]interface{}{"then": "b"},
+				map[string]interface{}{"then": "c"},
+			},
+		}, Registry)
+		assert.Nil(t, err)
+		var msgList []test.Msg
+		for i := 0; i < 9; i++ {
+			msgList = append(msgList, test.Msg{Data: "{}"})
+		}
+		results := countRelations(node, msgList...)
+		assert.Equal(t, map[string]int{"a": 3, "b": 3, "c": 3}, results)
+	})
+
+	t.Run("Hash", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode":    RouteHash,
+			"hashKey": "metadata.deviceId",
+			"routes": []interface{}{
+				map[string]interface{}{"then": "a"},
+				map[string]interface{}{"then": "b"},
+				map[string]interface{}{"then": "c"},
+			},
+		}, Registry)
+		assert.Nil(t, err)
+		//相同key总是选择相同的路由
+		for i := 0; i < 10; i++ {
+			var msgList []test.Msg
+			deviceId := fmt.Sprintf("device%d", i)
+			for j := 0; j < 5; j++ {
+				msgList = append(msgList, test.Msg{MetaData: types.BuildMetadata(map[string]string{"deviceId": deviceId}), Data: "{}"})
+			}
+			results := countRelations(node, msgList...)
+			assert.Equal(t, 1, len(results))
+		}
+	})
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flow
+
+import (
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+)
+
+// contractMapping 调用方对子规则链契约(types.ChainContract)的输入输出映射
+type contractMapping struct {
+	//inputs 输入参数名->调用方表达式
+	inputs map[string]string
+	//outputs 调用方元数据key->输出字段名
+	outputs  map[string]string
+	programs map[string]*vm.Program
+}
+
+// newContractMapping 编译输入参数表达式
+func newContractMapping(inputs map[string]string, outputs map[string]string) (*contractMapping, error) {
+	m := &contractMapping{
+		inputs:   inputs,
+		outputs:  outputs,
+		programs: make(map[string]*vm.Program),
+	}
+	for name, exprStr := range inputs {
+		program, err := expr.Compile(exprStr, expr.AllowUndefinedVariables())
+		if err != nil {
+			return nil, fmt.Errorf("contract input %s: %w", name, err)
+		}
+		m.programs[name] = program
+	}
+	return m, nil
+}
+
+// isEmpty 是否没有配置映射
+func (m *contractMapping) isEmpty() bool {
+	return m == nil || (len(m.inputs) == 0 && len(m.outputs) == 0)
+}
+
+// getContract 从规则链池获取目标规则链的契约，如果没有声明契约则返回nil
+func getContract(ctx types.RuleContext, chainId string) *types.ChainContract {
+	if chainId == "" || ctx.RuleChain() == nil {
+		return nil
+	}
+	chainCtx, ok := ctx.RuleChain().(types.ChainCtx)
+	if !ok || chainCtx.GetRuleEnginePool() == nil {
+		return nil
+	}
+	if e, ok := chainCtx.GetRuleEnginePool().Get(chainId); ok {
+		return e.Definition().RuleChain.Contract
+	}
+	return nil
+}
+
+// input 根据映射计算输入参数，并创建子规则链消息
+// 子规则链消息的元数据只包含契约声明的输入参数，不会泄露调用方的元数据
+func (m *contractMapping) input(ctx types.RuleContext, msg types.RuleMsg, contract *types.ChainContract) (types.RuleMsg, error) {
+	if err := contract.ValidateMapping(m.inputs, m.outputs); err != nil {
+		return msg, err
+	}
+	var evn map[string]interface{}
+	if len(m.programs) > 0 {
+		evn = base.NodeUtils.GetEvn(ctx, msg)
+	}
+	metadata := types.NewMetadata()
+	for _, param := range contract.Inputs {
+		var value interface{}
+		if program, ok := m.programs[param.Name]; ok {
+			out, err := vm.Run(program, evn)
+			if err != nil {
+				return msg, fmt.Errorf("contract input %s: %w", param.Name, err)
+			}
+			value = out
+		}
+		if value == nil {
+			value = param.Default
+		}
+		if value == nil {
+			if param.Required {
+				return msg, fmt.Errorf("contract input %s is required", param.Name)
+			}
+			continue
+		}
+		v, err := types.ConvertParamValue(param.Type, value)
+		if err != nil {
+			return msg, fmt.Errorf("contract input %s %s", param.Name, err)
+		}
+		metadata.PutValue(param.Name, types.ParamValueToString(v))
+	}
+	subMsg := msg.Copy()
+	subMsg.Metadata = metadata
+	return subMsg, nil
+}
+
+// output 把子规则链结束消息的输出字段合并到调用方消息的元数据
+// 如果没有配置输出映射，则合并所有声明的输出字段，元数据key和输出字段名相同
+func (m *contractMapping) output(contract *types.ChainContract, callerMsg types.RuleMsg, endMsg types.RuleMsg) (types.RuleMsg, error) {
+	outMsg := callerMsg.Copy()
+	outMsg.Data = endMsg.Data
+	outMsg.DataType = endMsg.DataType
+	outMsg.Type = endMsg.Type
+	if err := m.mergeOutput(contract, outMsg.Metadata, endMsg.Metadata); err != nil {
+		return outMsg, err
+	}
+	return outMsg, nil
+}
+
+// mergeOutput 把输出字段合并到目标元数据
+func (m *contractMapping) mergeOutput(contract *types.ChainContract, target types.Metadata, source types.Metadata) error {
+	put := func(key, name string) error {
+		if !source.Has(name) {
+			return nil
+		}
+		value := source.GetValue(name)
+		if param, ok := contract.GetOutput(name); ok {
+			if _, err := types.ConvertParamValue(param.Type, value); err != nil {
+				return fmt.Errorf("contract output %s %s", name, err)
+			}
+		}
+		target.PutValue(key, value)
+		return nil
+	}
+	if len(m.outputs) > 0 {
+		for key, name := range m.outputs {
+			if err := put(key, name); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, param := range contract.Outputs {
+			if err := put(param.Name, param.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -25,7 +25,19 @@ package flow
 //			"targetId": "sub_chain_01",
 //        }
 //  }
+//如果子规则链声明了契约(contract)，可以配置输入输出映射，示例：
+//{
+//        "id": "s1",
+//        "type": "flow",
+//        "name": "子规则链",
+//        "configuration": {
+//			"targetId": "sub_chain_01",
+//			"inputs": {"deviceId": "metadata.deviceId", "threshold": "msg.threshold"},
+//			"outputs": {"alarmLevel": "level"}
+//        }
+//  }
 import (
+	"fmt"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/utils/maps"
 	"github.com/rulego/rulego/utils/str"
@@ -43,15 +55,22 @@ type ChainNodeConfiguration struct {
 	TargetId string
 	//Extend true：继承子规则链的关系和输出，false:合并子规则链的关系和输出
 	Extend bool
+	//Inputs 子规则链契约输入参数映射，key:输入参数名，value:表达式，例如：metadata.deviceId
+	Inputs map[string]string
+	//Outputs 子规则链契约输出字段映射，key:合并到当前消息的元数据key，value:输出字段名
+	//如果为空，则合并所有声明的输出字段
+	Outputs map[string]string
 }
 
 // ChainNode 子规则链
 // 如果找不到规则链，则把消息通过`Failure`关系发送到下一个节点
 // Extend=true 子规则链的每一个输出和关系作为下一个节点的输入，不合并子规则链的关系和输出
 // Extend=false 子规则链所有分支执行完后，把每个结束节点处理的消息合后通过`Success`关系发送到下一个节点。消息格式：[]WrapperMsg
+// 如果子规则链声明了契约，则子规则链消息的元数据只包含映射后的输入参数，结束后只把输出字段合并到当前消息的元数据
 type ChainNode struct {
 	//节点配置
-	Config ChainNodeConfiguration
+	Config  ChainNodeConfiguration
+	mapping *contractMapping
 }
 
 // Type 组件类型
@@ -65,12 +84,25 @@ func (x *ChainNode) New() types.Node {
 
 // Init 初始化
 func (x *ChainNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
-	return maps.Map2Struct(configuration, &x.Config)
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	mapping, err := newContractMapping(x.Config.Inputs, x.Config.Outputs)
+	if err != nil {
+		return err
+	}
+	x.mapping = mapping
+	return nil
 }
 
 // OnMsg 处理消息
 func (x *ChainNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
-	if x.Config.Extend {
+	contract := getContract(ctx, x.Config.TargetId)
+	if contract == nil && !x.mapping.isEmpty() {
+		ctx.TellFailure(msg, fmt.Errorf("ruleChain id=%s has no contract", x.Config.TargetId))
+	} else if contract != nil {
+		x.tellFlowWithContract(ctx, msg, contract)
+	} else if x.Config.Extend {
 		x.TellFlowAndNoMerge(ctx, msg)
 	} else {
 		x.TellFlowAndMerge(ctx, msg)
@@ -133,6 +165,65 @@ func (x *ChainNode) TellFlowAndMerge(ctx types.RuleContext, msg types.RuleMsg) {
 	})
 }
 
+// tellFlowWithContract 按契约调用子规则链
+func (x *ChainNode) tellFlowWithContract(ctx types.RuleContext, msg types.RuleMsg, contract *types.ChainContract) {
+	subMsg, err := x.mapping.input(ctx, msg, contract)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if x.Config.Extend {
+		ctx.TellFlow(ctx.GetContext(), x.Config.TargetId, subMsg, func(nodeCtx types.RuleContext, onEndMsg types.RuleMsg, err error, relationType string) {
+			outMsg, outErr := x.mapping.output(contract, msg, onEndMsg)
+			if err == nil {
+				err = outErr
+			}
+			if err != nil {
+				ctx.TellFailure(outMsg, err)
+			} else {
+				ctx.TellNext(outMsg, relationType)
+			}
+		}, nil)
+		return
+	}
+	var wrapperMsg = msg.Copy()
+	var msgs []types.WrapperMsg
+	var targetRelationType = types.Success
+	var targetErr error
+	var mu sync.Mutex
+	ctx.TellFlow(ctx.GetContext(), x.Config.TargetId, subMsg, func(nodeCtx types.RuleContext, onEndMsg types.RuleMsg, err error, relationType string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			if err = x.mapping.mergeOutput(contract, wrapperMsg.Metadata, onEndMsg.Metadata); err != nil {
+				relationType = types.Failure
+			}
+		}
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if relationType == types.Failure {
+			targetRelationType = relationType
+			targetErr = err
+		}
+		onEndMsg.Metadata = nil
+		msgs = append(msgs, types.WrapperMsg{
+			Msg:    onEndMsg,
+			Err:    errStr,
+			NodeId: nodeCtx.GetSelfId(),
+		})
+	}, func() {
+		wrapperMsg.DataType = types.JSON
+		wrapperMsg.Data = str.ToString(msgs)
+		if targetRelationType == types.Failure {
+			ctx.TellFailure(wrapperMsg, targetErr)
+		} else {
+			ctx.TellSuccess(wrapperMsg)
+		}
+	})
+}
+
 // Destroy 销毁
 func (x *ChainNode) Destroy() {
 }
@@ -27,6 +27,7 @@ package flow
 //  }
 import (
 	"errors"
+	"fmt"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/utils/maps"
 	"strings"
@@ -41,17 +42,23 @@ func init() {
 type RefNodeConfiguration struct {
 	//TargetId 节点ID，
 	TargetId string
+	//Inputs 目标规则链契约输入参数映射，key:输入参数名，value:表达式，例如：metadata.deviceId
+	Inputs map[string]string
+	//Outputs 目标规则链契约输出字段映射，key:合并到当前消息的元数据key，value:输出字段名
+	Outputs map[string]string
 }
 
 // RefNode 引用指定规则链或者当前规则链节点，用于节点复用
 // 格式：[{chainId}]:{nodeId}，如果是引入本规则链，则格式为：{nodeId}
 // 执行成功则使用该节点的输出关系发送到下一个节点
 // 如果找不到节点，则把消息通过`Failure`关系发送到下一个节点
+// 如果引用其他规则链的节点，并且该规则链声明了契约，则按契约映射输入输出
 type RefNode struct {
 	//节点配置
 	Config  RefNodeConfiguration
 	chainId string
 	nodeId  string
+	mapping *contractMapping
 }
 
 // Type 组件类型
@@ -75,11 +82,39 @@ func (x *RefNode) Init(ruleConfig types.Config, configuration types.Configuratio
 		x.chainId = values[0]
 		x.nodeId = values[1]
 	}
+	if err != nil {
+		return err
+	}
+	x.mapping, err = newContractMapping(x.Config.Inputs, x.Config.Outputs)
 	return err
 }
 
 // OnMsg 处理消息
 func (x *RefNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	contract := getContract(ctx, x.chainId)
+	if contract == nil && !x.mapping.isEmpty() {
+		ctx.TellFailure(msg, fmt.Errorf("ruleChain id=%s has no contract", x.chainId))
+		return
+	}
+	if contract != nil {
+		subMsg, err := x.mapping.input(ctx, msg, contract)
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		ctx.TellChainNode(ctx.GetContext(), x.chainId, x.nodeId, subMsg, true, func(newCtx types.RuleContext, newMsg types.RuleMsg, err error, relationType string) {
+			outMsg, outErr := x.mapping.output(contract, msg, newMsg)
+			if err == nil {
+				err = outErr
+			}
+			if err != nil {
+				ctx.TellFailure(msg, err)
+			} else {
+				ctx.TellNext(outMsg, relationType)
+			}
+		}, nil)
+		return
+	}
 	ctx.TellChainNode(ctx.GetContext(), x.chainId, x.nodeId, msg, true, func(newCtx types.RuleContext, newMsg types.RuleMsg, err error, relationType string) {
 		if err != nil {
 			ctx.TellFailure(msg, err)
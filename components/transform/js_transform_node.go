@@ -51,7 +51,7 @@ type JsTransformNodeConfiguration struct {
 	//完整脚本函数：
 	//function Transform(msg, metadata, msgType) { ${JsScript} }
 	//return {'msg':msg,'metadata':metadata,'msgType':msgType};
-	JsScript string
+	JsScript string `label:"Script" desc:"function Transform(msg, metadata, msgType) { ${jsScript} }" required:"true" widget:"js" i18n:"jsTransform.jsScript"`
 }
 
 // JsTransformNode 使用JavaScript更改消息metadata，msg或msgType
@@ -0,0 +1,174 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package impl
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/api/types/endpoint"
+)
+
+// DefaultBackpressureCheckInterval 背压状态下检查负载的默认间隔
+const DefaultBackpressureCheckInterval = 100 * time.Millisecond
+
+// BackpressureConfig 背压水位线配置
+type BackpressureConfig struct {
+	// HighWatermark 高水位线，正在处理的消息数达到该值时，endpoint暂停接收新的输入。<=0表示不启用背压
+	HighWatermark int64
+	// LowWatermark 低水位线，暂停后正在处理的消息数回落到该值以下时，endpoint恢复接收。默认为HighWatermark的一半
+	LowWatermark int64
+}
+
+// Backpressure 背压控制器
+// 根据负载(正在处理的消息数)和高低水位线，在接收和暂停两种状态之间切换，避免在高水位线附近频繁抖动
+type Backpressure struct {
+	Config BackpressureConfig
+	// CheckInterval 暂停状态下检查负载的间隔
+	CheckInterval time.Duration
+	// 负载获取函数
+	load func() int64
+	// 1:暂停 0:接收
+	paused int32
+}
+
+// NewBackpressure 创建背压控制器，load 返回当前正在处理的消息数
+func NewBackpressure(config BackpressureConfig, load func() int64) *Backpressure {
+	return &Backpressure{
+		Config:        config,
+		CheckInterval: DefaultBackpressureCheckInterval,
+		load:          load,
+	}
+}
+
+// Enabled 是否启用背压
+func (b *Backpressure) Enabled() bool {
+	return b != nil && b.load != nil && b.Config.HighWatermark > 0
+}
+
+// Saturated 检查当前负载，返回是否应该拒绝或暂停接收新的输入
+// 负载达到高水位线进入暂停状态，直到负载回落到低水位线以下才恢复
+func (b *Backpressure) Saturated() bool {
+	if !b.Enabled() {
+		return false
+	}
+	current := b.load()
+	if atomic.LoadInt32(&b.paused) == 1 {
+		if current <= b.lowWatermark() {
+			atomic.StoreInt32(&b.paused, 0)
+			return false
+		}
+		return true
+	}
+	if current >= b.Config.HighWatermark {
+		atomic.StoreInt32(&b.paused, 1)
+		return true
+	}
+	return false
+}
+
+// Paused 返回是否处于暂停状态，不重新计算负载
+func (b *Backpressure) Paused() bool {
+	return b.Enabled() && atomic.LoadInt32(&b.paused) == 1
+}
+
+// Wait 阻塞直到负载回落到低水位线以下。stop 返回true时提前结束并返回false
+func (b *Backpressure) Wait(stop func() bool) bool {
+	for b.Saturated() {
+		if stop != nil && stop() {
+			return false
+		}
+		time.Sleep(b.checkInterval())
+	}
+	return true
+}
+
+// Watch 周期性检查负载，状态发生变化时调用 onChange，返回停止检查的函数
+func (b *Backpressure) Watch(onChange func(saturated bool)) func() {
+	if !b.Enabled() {
+		return func() {}
+	}
+	stopCh := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(b.checkInterval())
+		defer ticker.Stop()
+		var last bool
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if saturated := b.Saturated(); saturated != last {
+					last = saturated
+					onChange(saturated)
+				}
+			}
+		}
+	}()
+	var stopped int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&stopped, 0, 1) {
+			close(stopCh)
+		}
+	}
+}
+
+func (b *Backpressure) lowWatermark() int64 {
+	if b.Config.LowWatermark <= 0 || b.Config.LowWatermark >= b.Config.HighWatermark {
+		return b.Config.HighWatermark / 2
+	}
+	return b.Config.LowWatermark
+}
+
+func (b *Backpressure) checkInterval() time.Duration {
+	if b.CheckInterval <= 0 {
+		return DefaultBackpressureCheckInterval
+	}
+	return b.CheckInterval
+}
+
+// InFlight 返回endpoint所有路由目标规则链池正在处理的消息数，作为背压的负载信号
+// 规则链池需要实现 types.LoadReporter 接口
+func (e *BaseEndpoint) InFlight() int64 {
+	e.RLock()
+	var routers = make([]endpoint.Router, 0, len(e.RouterStorage))
+	for _, router := range e.RouterStorage {
+		routers = append(routers, router)
+	}
+	e.RUnlock()
+	return InFlight(routers...)
+}
+
+// InFlight 返回路由列表目标规则链池正在处理的消息数，相同的规则链池只统计一次
+func InFlight(routers ...endpoint.Router) int64 {
+	var total int64
+	var visited = make(map[types.LoadReporter]struct{})
+	for _, router := range routers {
+		r, ok := router.(*Router)
+		if !ok || r.RuleGo == nil {
+			continue
+		}
+		if reporter, ok := r.RuleGo.(types.LoadReporter); ok {
+			if _, ok := visited[reporter]; !ok {
+				visited[reporter] = struct{}{}
+				total += reporter.InFlight()
+			}
+		}
+	}
+	return total
+}
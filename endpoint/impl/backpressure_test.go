@@ -0,0 +1,118 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package impl
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/api/types/endpoint"
+	"github.com/rulego/rulego/test/assert"
+)
+
+type testLoadPool struct {
+	types.RuleEnginePool
+	load int64
+}
+
+func (p *testLoadPool) InFlight() int64 {
+	return atomic.LoadInt64(&p.load)
+}
+
+func TestBackpressure(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		var b *Backpressure
+		assert.False(t, b.Enabled())
+		assert.False(t, b.Saturated())
+		b = NewBackpressure(BackpressureConfig{}, func() int64 {
+			return 100
+		})
+		assert.False(t, b.Enabled())
+		assert.False(t, b.Saturated())
+	})
+	t.Run("Watermark", func(t *testing.T) {
+		var load int64
+		b := NewBackpressure(BackpressureConfig{HighWatermark: 10, LowWatermark: 4}, func() int64 {
+			return load
+		})
+		load = 9
+		assert.False(t, b.Saturated())
+		load = 10
+		assert.True(t, b.Saturated())
+		assert.True(t, b.Paused())
+		//高低水位线之间，保持暂停
+		load = 5
+		assert.True(t, b.Saturated())
+		load = 4
+		assert.False(t, b.Saturated())
+		assert.False(t, b.Paused())
+		load = 5
+		assert.False(t, b.Saturated())
+	})
+	t.Run("DefaultLowWatermark", func(t *testing.T) {
+		var load int64 = 10
+		b := NewBackpressure(BackpressureConfig{HighWatermark: 10}, func() int64 {
+			return load
+		})
+		assert.True(t, b.Saturated())
+		load = 6
+		assert.True(t, b.Saturated())
+		load = 5
+		assert.False(t, b.Saturated())
+	})
+	t.Run("WaitAndWatch", func(t *testing.T) {
+		var load int64 = 2
+		b := NewBackpressure(BackpressureConfig{HighWatermark: 2, LowWatermark: 1}, func() int64 {
+			return atomic.LoadInt64(&load)
+		})
+		b.CheckInterval = time.Millisecond * 10
+		var changes []bool
+		var changeCh = make(chan bool, 2)
+		stop := b.Watch(func(saturated bool) {
+			changeCh <- saturated
+		})
+		defer stop()
+		changes = append(changes, <-changeCh)
+
+		time.AfterFunc(time.Millisecond*50, func() {
+			atomic.StoreInt64(&load, 0)
+		})
+		start := time.Now()
+		assert.True(t, b.Wait(nil))
+		assert.True(t, time.Since(start) >= time.Millisecond*40)
+		changes = append(changes, <-changeCh)
+		assert.Equal(t, []bool{true, false}, changes)
+
+		atomic.StoreInt64(&load, 2)
+		assert.False(t, b.Wait(func() bool {
+			return true
+		}))
+	})
+	t.Run("InFlight", func(t *testing.T) {
+		pool := &testLoadPool{load: 3}
+		r1 := NewRouter(endpoint.RouterOptions.WithRuleGo(pool)).From("a").End()
+		r2 := NewRouter(endpoint.RouterOptions.WithRuleGo(pool)).From("b").End()
+		r3 := NewRouter(endpoint.RouterOptions.WithRuleGo(&testLoadPool{load: 2})).From("c").End()
+		//同一个规则链池只统计一次
+		assert.Equal(t, int64(5), InFlight(r1, r2, r3))
+
+		var ep = &BaseEndpoint{}
+		assert.Equal(t, int64(0), ep.InFlight())
+	})
+}
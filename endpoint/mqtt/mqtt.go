@@ -226,8 +226,13 @@ type Mqtt struct {
 	base.SharedNode[*mqtt.Client]
 	RuleConfig types.Config
 	Config     mqtt.Config
-	client     *mqtt.Client
-	started    bool
+	//背压配置，规则链池正在处理的消息数达到高水位线时取消订阅，回落到低水位线以下时重新订阅
+	BackpressureConfig impl.BackpressureConfig
+	client             *mqtt.Client
+	started            bool
+	backpressure       *impl.Backpressure
+	//停止背压检查
+	stopWatch func()
 }
 
 // Type 组件类型
@@ -244,7 +249,11 @@ func (x *Mqtt) New() types.Node {
 // Init 初始化
 func (x *Mqtt) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.Config)
+	if err == nil {
+		err = maps.Map2Struct(configuration, &x.BackpressureConfig)
+	}
 	x.RuleConfig = ruleConfig
+	x.backpressure = impl.NewBackpressure(x.BackpressureConfig, x.InFlight)
 	_ = x.SharedNode.Init(x.RuleConfig, x.Type(), x.Config.Server, true, func() (*mqtt.Client, error) {
 		return x.initClient()
 	})
@@ -257,6 +266,10 @@ func (x *Mqtt) Destroy() {
 }
 
 func (x *Mqtt) Close() error {
+	if x.stopWatch != nil {
+		x.stopWatch()
+		x.stopWatch = nil
+	}
 	if x.client != nil {
 		return x.client.Close()
 	}
@@ -273,8 +286,8 @@ func (x *Mqtt) AddRouter(router endpoint.Router, params ...interface{}) (string,
 	}
 	x.CheckAndSetRouterId(router)
 	x.saveRouter(router)
-	//服务已经启动
-	if x.started {
+	//服务已经启动，背压暂停期间由恢复时统一订阅
+	if x.started && !x.backpressure.Paused() {
 		if form := router.GetFrom(); form != nil {
 			client, err := x.SharedNode.Get()
 			if err != nil {
@@ -322,9 +335,38 @@ func (x *Mqtt) Start() error {
 		}
 	}
 	x.started = true
+	x.stopWatch = x.backpressure.Watch(x.onBackpressure)
 	return nil
 }
 
+// onBackpressure 规则链池负载达到高水位线时取消所有路由的订阅，负载回落后重新订阅
+func (x *Mqtt) onBackpressure(saturated bool) {
+	client, err := x.SharedNode.Get()
+	if err != nil {
+		return
+	}
+	x.RLock()
+	defer x.RUnlock()
+	for _, router := range x.RouterStorage {
+		if form := router.GetFrom(); form != nil {
+			if saturated {
+				_ = client.UnregisterHandler(form.ToString())
+			} else {
+				client.RegisterHandler(mqtt.Handler{
+					Topic:  form.ToString(),
+					Qos:    x.Config.QOS,
+					Handle: x.handler(router),
+				})
+			}
+		}
+	}
+	if saturated {
+		x.Printf("mqtt endpoint paused, in-flight messages reached high watermark %d", x.BackpressureConfig.HighWatermark)
+	} else {
+		x.Printf("mqtt endpoint resumed")
+	}
+}
+
 // 存储路由
 func (x *Mqtt) saveRouter(routers ...endpoint.Router) {
 	x.Lock()
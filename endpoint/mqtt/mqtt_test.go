@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/rulego/rulego/api/types"
 	endpoint "github.com/rulego/rulego/api/types/endpoint"
+	"github.com/rulego/rulego/components/action"
 	"github.com/rulego/rulego/components/mqtt"
 	"github.com/rulego/rulego/endpoint/impl"
 	"github.com/rulego/rulego/engine"
@@ -12,6 +13,7 @@ import (
 	"github.com/rulego/rulego/utils/maps"
 	"os"
 	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -176,3 +178,83 @@ func startServer(t *testing.T, stop chan struct{}) {
 	<-stop
 	ep.Destroy()
 }
+
+// 测试背压，负载达到高水位线时取消订阅，回落后重新订阅
+func TestMqttBackpressure(t *testing.T) {
+	var ruleChainFile = `{
+          "ruleChain": {
+            "id": "testMqttBackpressure"
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "mqttBackpressureSleep"
+                }
+              }
+            ]
+          }
+        }`
+	action.Functions.Register("mqttBackpressureSleep", func(ctx types.RuleContext, msg types.RuleMsg) {
+		time.Sleep(time.Millisecond * 300)
+		ctx.TellSuccess(msg)
+	})
+	config := engine.NewConfig(types.WithDefaultPool())
+	pool := engine.NewPool()
+	_, err := pool.New("", []byte(ruleChainFile), engine.WithConfig(config))
+	assert.Nil(t, err)
+
+	var nodeConfig = make(types.Configuration)
+	_ = maps.Map2Struct(&mqtt.Config{
+		Server: testServer,
+	}, nodeConfig)
+	nodeConfig["highWatermark"] = 1
+	var ep = &Endpoint{}
+	err = ep.Init(config, nodeConfig)
+	assert.Nil(t, err)
+	ep.backpressure.CheckInterval = time.Millisecond * 10
+	topic := "/device/backpressure"
+	var count int32
+	router := impl.NewRouter(endpoint.RouterOptions.WithRuleGo(pool)).From(topic).Transform(func(router endpoint.Router, exchange *endpoint.Exchange) bool {
+		atomic.AddInt32(&count, 1)
+		return true
+	}).To("chain:testMqttBackpressure").End()
+	_, err = ep.AddRouter(router)
+	assert.Nil(t, err)
+	err = ep.Start()
+	assert.Nil(t, err)
+	defer ep.Destroy()
+
+	client, err := ep.SharedNode.Get()
+	assert.Nil(t, err)
+	subscribed := func() bool {
+		return client.GetHandlerByUpTopic(topic).Topic == topic
+	}
+	assert.True(t, subscribed())
+	err = client.Publish(topic, 0, []byte(msgContent1))
+	assert.Nil(t, err)
+	//达到高水位线，取消订阅
+	waitUntil(t, func() bool {
+		return !subscribed()
+	})
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+	//负载回落，重新订阅
+	waitUntil(t, subscribed)
+	err = client.Publish(topic, 0, []byte(msgContent2))
+	assert.Nil(t, err)
+	waitUntil(t, func() bool {
+		return atomic.LoadInt32(&count) == 2
+	})
+}
+
+func waitUntil(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second * 5)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("wait timeout")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
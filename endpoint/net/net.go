@@ -43,6 +43,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/rulego/rulego/api/types"
@@ -244,7 +245,12 @@ type Net struct {
 	udpConn *net.UDPConn
 	// 路由映射表
 	routers map[string]*RegexpRouter
-	closed  bool
+	// 是否已经关闭 1:关闭 0:未关闭
+	closed int32
+	// 背压配置，路由目标规则链池正在处理的消息数达到高水位线时暂停读取连接数据，回落到低水位线以下时恢复读取
+	BackpressureConfig impl.BackpressureConfig
+	// 背压控制器
+	backpressure *impl.Backpressure
 }
 
 // Type 组件类型
@@ -262,20 +268,45 @@ func (ep *Net) New() types.Node {
 func (ep *Net) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	// 将配置转换为EndpointConfiguration结构体
 	err := maps.Map2Struct(configuration, &ep.Config)
+	if err == nil {
+		err = maps.Map2Struct(configuration, &ep.BackpressureConfig)
+	}
 	if ep.Config.Protocol == "" {
 		ep.Config.Protocol = "tcp"
 	}
 	ep.RuleConfig = ruleConfig
+	ep.backpressure = impl.NewBackpressure(ep.BackpressureConfig, ep.InFlight)
 	return err
 }
 
+// InFlight 返回路由目标规则链池正在处理的消息数
+func (ep *Net) InFlight() int64 {
+	ep.RLock()
+	var routers = make([]endpoint.Router, 0, len(ep.routers))
+	for _, item := range ep.routers {
+		routers = append(routers, item.router)
+	}
+	ep.RUnlock()
+	return impl.InFlight(routers...)
+}
+
+// waitBackpressure 规则链池负载达到高水位线时阻塞，暂停读取连接数据，直到负载回落或者endpoint关闭
+func (ep *Net) waitBackpressure() bool {
+	return ep.backpressure.Wait(ep.isClosed)
+}
+
+// isClosed 是否已经关闭
+func (ep *Net) isClosed() bool {
+	return atomic.LoadInt32(&ep.closed) == 1
+}
+
 // Destroy 销毁
 func (ep *Net) Destroy() {
 	_ = ep.Close()
 }
 
 func (ep *Net) Close() error {
-	ep.closed = true
+	atomic.StoreInt32(&ep.closed, 1)
 	if ep.listener != nil {
 		err := ep.listener.Close()
 		ep.listener = nil
@@ -477,6 +508,15 @@ func (x *TcpHandler) handler() {
 	reader := bufio.NewReader(x.conn)
 	// 循环读取客户端发送的数据
 	for {
+		// 背压：暂停读取，由TCP流控把压力传递给客户端
+		if x.endpoint.backpressure.Saturated() {
+			x.readTimeoutTimer.Stop()
+			if !x.endpoint.waitBackpressure() {
+				x.onDisconnect()
+				break
+			}
+			x.readTimeoutTimer.Reset(readTimeoutDuration)
+		}
 		// 设置读取超时
 		if x.endpoint.Config.ReadTimeout > 0 {
 			err := x.conn.SetReadDeadline(time.Now().Add(readTimeoutDuration))
@@ -568,13 +608,17 @@ type UDPHandler struct {
 func (x *UDPHandler) handler() {
 	buffer := make([]byte, BufferSize)
 	for {
-		if x.endpoint.udpConn == nil || x.endpoint.closed {
+		if x.endpoint.udpConn == nil || x.endpoint.isClosed() {
+			break
+		}
+		// 背压：暂停读取
+		if !x.endpoint.waitBackpressure() {
 			break
 		}
 		n, addr, err := x.endpoint.udpConn.ReadFromUDP(buffer)
 		if err != nil {
 			time.Sleep(time.Second)
-			if x.endpoint.closed {
+			if x.endpoint.isClosed() {
 				break
 			}
 			err = x.endpoint.listenUDP()
@@ -1,14 +1,17 @@
 package net
 
 import (
+	"errors"
 	"fmt"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/api/types/endpoint"
+	"github.com/rulego/rulego/components/action"
 	"github.com/rulego/rulego/endpoint/impl"
 	"github.com/rulego/rulego/engine"
 	"github.com/rulego/rulego/test"
 	"github.com/rulego/rulego/test/assert"
 	"github.com/rulego/rulego/utils/maps"
+	"net"
 	"os"
 	"reflect"
 	"strings"
@@ -290,3 +293,84 @@ func startServer(t *testing.T, stop chan struct{}, wg *sync.WaitGroup) {
 	assert.Equal(t, int32(4), router2Count)
 	wg.Done()
 }
+
+// 测试背压
+func TestNetBackpressure(t *testing.T) {
+	var ruleChainFile = `{
+          "ruleChain": {
+            "id": "testNetBackpressure"
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "netBackpressureSleep"
+                }
+              }
+            ]
+          }
+        }`
+	action.Functions.Register("netBackpressureSleep", func(ctx types.RuleContext, msg types.RuleMsg) {
+		time.Sleep(time.Millisecond * 300)
+		ctx.TellSuccess(msg)
+	})
+	config := engine.NewConfig(types.WithDefaultPool())
+	pool := engine.NewPool()
+	_, err := pool.New("", []byte(ruleChainFile), engine.WithConfig(config))
+	assert.Nil(t, err)
+
+	var nodeConfig = make(types.Configuration)
+	_ = maps.Map2Struct(&Config{
+		Protocol: "tcp",
+		Server:   "127.0.0.1:6336",
+	}, nodeConfig)
+	nodeConfig["highWatermark"] = 1
+	var ep = &Net{}
+	err = ep.Init(config, nodeConfig)
+	assert.Nil(t, err)
+	ep.backpressure.CheckInterval = time.Millisecond * 10
+	var received = make(chan time.Time, 10)
+	router := impl.NewRouter(endpoint.RouterOptions.WithRuleGo(pool)).From("").Transform(func(router endpoint.Router, exchange *endpoint.Exchange) bool {
+		received <- time.Now()
+		return true
+	}).To("chain:testNetBackpressure").End()
+	_, err = ep.AddRouter(router)
+	assert.Nil(t, err)
+	err = ep.Start()
+	assert.Nil(t, err)
+	defer ep.Destroy()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:6336")
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte(msgContent1 + "\n" + msgContent2 + "\n"))
+	assert.Nil(t, err)
+
+	//达到高水位线后暂停读取，直到第一条消息处理完成
+	first := waitReceived(t, received)
+	second := waitReceived(t, received)
+	assert.True(t, second.Sub(first) >= time.Millisecond*250)
+
+	//暂停读取期间关闭endpoint，断开连接
+	_, err = conn.Write([]byte(msgContent4 + "\n" + msgContent5 + "\n"))
+	assert.Nil(t, err)
+	waitReceived(t, received)
+	_ = ep.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 2))
+	_, err = conn.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, os.ErrDeadlineExceeded))
+	assert.Equal(t, 0, len(received))
+}
+
+func waitReceived(t *testing.T, received chan time.Time) time.Time {
+	select {
+	case v := <-received:
+		return v
+	case <-time.After(time.Second * 2):
+		t.Fatal("message not received")
+		return time.Time{}
+	}
+}
@@ -39,6 +39,7 @@ import (
 	"net"
 	"net/http"
 	"net/textproto"
+	"strconv"
 	"strings"
 
 	"github.com/julienschmidt/httprouter"
@@ -59,6 +60,7 @@ const (
 	HeaderKeyAccessControlAllowHeaders  = "Access-Control-Allow-Headers"
 	HeaderKeyAccessControlAllowOrigin   = "Access-Control-Allow-Origin"
 	HeaderValueAll                      = "*"
+	HeaderKeyRetryAfter                 = "Retry-After"
 )
 
 // Type 组件类型
@@ -165,6 +167,8 @@ type ResponseMessage struct {
 	to       string
 	msg      *types.RuleMsg
 	err      error
+	//并发限制时，Retry-After响应头的值，单位秒
+	retryAfter int
 }
 
 func (r *ResponseMessage) Body() []byte {
@@ -212,8 +216,12 @@ func (r *ResponseMessage) SetBody(body []byte) {
 	}
 }
 
+// SetError 设置错误，如果是并发限制错误，响应429状态码和Retry-After响应头
 func (r *ResponseMessage) SetError(err error) {
 	r.err = err
+	if errors.Is(err, types.ErrConcurrencyLimitReached) && r.response != nil {
+		writeTooManyRequests(r.response, r.retryAfter)
+	}
 }
 
 func (r *ResponseMessage) GetError() error {
@@ -231,6 +239,8 @@ type Config struct {
 	CertKeyFile string
 	//是否允许跨域
 	AllowCors bool
+	//返回429状态码时，Retry-After响应头的值，单位秒，默认1
+	RetryAfter int
 }
 
 // Rest 接收端端点
@@ -244,6 +254,10 @@ type Rest struct {
 	//http路由器
 	router  *httprouter.Router
 	started bool
+	//背压配置，路由目标规则链池正在处理的消息数达到高水位线时返回429状态码，回落到低水位线以下时恢复接收请求
+	BackpressureConfig impl.BackpressureConfig
+	//背压控制器
+	backpressure *impl.Backpressure
 }
 
 // Type 组件类型
@@ -265,7 +279,11 @@ func (rest *Rest) Init(ruleConfig types.Config, configuration types.Configuratio
 	if err != nil {
 		return err
 	}
+	if err = maps.Map2Struct(configuration, &rest.BackpressureConfig); err != nil {
+		return err
+	}
 	rest.RuleConfig = ruleConfig
+	rest.backpressure = impl.NewBackpressure(rest.BackpressureConfig, rest.InFlight)
 	return rest.SharedNode.Init(rest.RuleConfig, rest.Type(), rest.Config.Server, false, func() (*Rest, error) {
 		return rest.initServer()
 	})
@@ -531,14 +549,20 @@ func (rest *Rest) handler(router endpoint.Router, isWait bool) httprouter.Handle
 			//w.WriteHeader(http.NotFound())
 			return
 		}
+		//规则链池负载过高，拒绝请求
+		if rest.backpressure.Saturated() {
+			writeTooManyRequests(w, rest.Config.RetryAfter)
+			return
+		}
 		exchange := &endpoint.Exchange{
 			In: &RequestMessage{
 				request: r,
 				Params:  params,
 			},
 			Out: &ResponseMessage{
-				request:  r,
-				response: w,
+				request:    r,
+				response:   w,
+				retryAfter: rest.Config.RetryAfter,
 			},
 		}
 
@@ -566,6 +590,15 @@ func (rest *Rest) handler(router endpoint.Router, isWait bool) httprouter.Handle
 	}
 }
 
+// writeTooManyRequests 响应429状态码，并设置Retry-After响应头
+func writeTooManyRequests(w http.ResponseWriter, retryAfter int) {
+	if retryAfter <= 0 {
+		retryAfter = 1
+	}
+	w.Header().Set(HeaderKeyRetryAfter, strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
 func (rest *Rest) Printf(format string, v ...interface{}) {
 	if rest.RuleConfig.Logger != nil {
 		rest.RuleConfig.Logger.Printf(format, v...)
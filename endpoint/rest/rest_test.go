@@ -11,6 +11,7 @@ import (
 	"github.com/rulego/rulego/test/assert"
 	"github.com/rulego/rulego/utils/maps"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"sync"
@@ -333,3 +334,76 @@ func startServer(t *testing.T, stop chan struct{}, wg *sync.WaitGroup) {
 	restEndpoint.Destroy()
 	wg.Done()
 }
+
+// 测试背压
+func TestRestBackpressure(t *testing.T) {
+	var ruleChainFile = `{
+          "ruleChain": {
+            "id": "testBackpressure"
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "restBackpressureSleep"
+                }
+              }
+            ]
+          }
+        }`
+	action.Functions.Register("restBackpressureSleep", func(ctx types.RuleContext, msg types.RuleMsg) {
+		time.Sleep(time.Millisecond * 300)
+		ctx.TellSuccess(msg)
+	})
+	config := engine.NewConfig(types.WithDefaultPool())
+	pool := engine.NewPool()
+	_, err := pool.New("", []byte(ruleChainFile), engine.WithConfig(config))
+	assert.Nil(t, err)
+
+	var nodeConfig = make(types.Configuration)
+	_ = maps.Map2Struct(&Config{
+		Server:     ":9092",
+		RetryAfter: 2,
+	}, nodeConfig)
+	nodeConfig["highWatermark"] = 1
+	var restEndpoint = &Endpoint{}
+	err = restEndpoint.Init(config, nodeConfig)
+	assert.Nil(t, err)
+	router := impl.NewRouter(endpoint.RouterOptions.WithRuleGo(pool)).From("/api/v1/bp").To("chain:testBackpressure").End()
+	_, err = restEndpoint.AddRouter(router, "POST")
+	assert.Nil(t, err)
+	err = restEndpoint.Start()
+	assert.Nil(t, err)
+	defer restEndpoint.Destroy()
+	time.Sleep(time.Millisecond * 200)
+
+	url := "http://127.0.0.1:9092/api/v1/bp"
+	resp, err := http.Post(url, JsonContextType, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+	time.Sleep(time.Millisecond * 50)
+
+	//达到高水位线
+	resp, err = http.Post(url, JsonContextType, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get(HeaderKeyRetryAfter))
+	_ = resp.Body.Close()
+
+	//负载回落
+	time.Sleep(time.Millisecond * 400)
+	resp, err = http.Post(url, JsonContextType, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	//并发限制错误
+	recorder := httptest.NewRecorder()
+	response := &ResponseMessage{response: recorder}
+	response.SetError(types.ErrConcurrencyLimitReached)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "1", recorder.Header().Get(HeaderKeyRetryAfter))
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego/api/pool"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/action"
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestChainBulkhead(t *testing.T) {
+	var ruleChainFile = `{
+          "ruleChain": {
+            "id": "testChainBulkhead",
+            "configuration": {
+              "pool": {
+                "maxWorkers": 1,
+                "overflowPolicy": "%s"
+              }
+            }
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "bulkheadSleep"
+                }
+              }
+            ]
+          }
+        }`
+	action.Functions.Register("bulkheadSleep", func(ctx types.RuleContext, msg types.RuleMsg) {
+		time.Sleep(time.Millisecond * 200)
+		ctx.TellSuccess(msg)
+	})
+	newMsg := func() types.RuleMsg {
+		return types.NewMsg(0, "TEST_MSG_TYPE1", types.JSON, types.NewMetadata(), "{\"temperature\":41}")
+	}
+
+	t.Run("Reject", func(t *testing.T) {
+		ruleEngine, err := New("testChainBulkhead", []byte(fmtChain(ruleChainFile, "reject")), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		defer Del("testChainBulkhead")
+
+		var firstErr = make(chan error, 1)
+		ruleEngine.OnMsg(newMsg(), types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			firstErr <- err
+		}))
+		time.Sleep(time.Millisecond * 50)
+		pool := ruleEngine.(*RuleEngine).PoolMetrics()
+		assert.Equal(t, int64(1), pool.MaxWorkers)
+		assert.Equal(t, int64(1), pool.Active)
+
+		//规则链协程池已满，拒绝新的消息
+		var secondErr error
+		ruleEngine.OnMsgAndWait(newMsg(), types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			secondErr = err
+		}))
+		assert.True(t, errors.Is(secondErr, types.ErrConcurrencyLimitReached))
+		//已经在处理的消息不受影响
+		assert.Nil(t, <-firstErr)
+		time.Sleep(time.Millisecond * 50)
+		pool = ruleEngine.(*RuleEngine).PoolMetrics()
+		assert.Equal(t, int64(0), pool.Active)
+		assert.Equal(t, int64(1), pool.Rejected)
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		ruleEngine, err := New("testChainBulkhead", []byte(fmtChain(ruleChainFile, "block")), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		defer Del("testChainBulkhead")
+
+		ruleEngine.OnMsg(newMsg())
+		time.Sleep(time.Millisecond * 50)
+		start := time.Now()
+		var relation string
+		ruleEngine.OnMsgAndWait(newMsg(), types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			relation = relationType
+		}))
+		//等待上一条消息处理完
+		assert.True(t, time.Since(start) >= time.Millisecond*300)
+		assert.Equal(t, types.Success, relation)
+	})
+
+	t.Run("Defer", func(t *testing.T) {
+		var fanOutChainFile = `{
+          "ruleChain": {
+            "id": "testChainBulkhead",
+            "configuration": {
+              "pool": {
+                "maxWorkers": 1
+              }
+            }
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "bulkheadSleep"
+                }
+              },
+              {
+                "id": "s2",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "bulkheadSleep"
+                }
+              },
+              {
+                "id": "s3",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "bulkheadSleep"
+                }
+              }
+            ],
+            "connections": [
+              {
+                "fromId": "s1",
+                "toId": "s2",
+                "type": "Success"
+              },
+              {
+                "fromId": "s1",
+                "toId": "s3",
+                "type": "Success"
+              }
+            ]
+          }
+        }`
+		ruleEngine, err := New("testChainBulkhead", []byte(fanOutChainFile), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		defer Del("testChainBulkhead")
+
+		//规则链协程池已满，已经接收的消息的后续任务等待空闲协程，不会被拒绝
+		var lock sync.Mutex
+		var relations []string
+		ruleEngine.OnMsgAndWait(newMsg(), types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			lock.Lock()
+			defer lock.Unlock()
+			relations = append(relations, relationType)
+		}))
+		lock.Lock()
+		assert.Equal(t, []string{types.Success, types.Success}, relations)
+		lock.Unlock()
+		assert.Equal(t, int64(0), ruleEngine.(*RuleEngine).PoolMetrics().Rejected)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		ruleEngine, err := New("testChainBulkhead", []byte(fmtChain(ruleChainFile, "reject")), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		bulkhead := ruleEngine.(*RuleEngine).rootRuleChainCtx.bulkhead
+		Del("testChainBulkhead")
+		//销毁规则链时停止协程池
+		assert.Equal(t, pool.ErrBulkheadReleased, bulkhead.Submit(func() {}))
+	})
+
+	t.Run("SharedPool", func(t *testing.T) {
+		ruleEngine, err := New("testChainBulkhead", []byte(loadFile("chain_call_rest_api.json")), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		defer Del("testChainBulkhead")
+		assert.Equal(t, int64(0), ruleEngine.(*RuleEngine).PoolMetrics().MaxWorkers)
+	})
+
+	t.Run("PerChainMetrics", func(t *testing.T) {
+		var started = make(chan struct{}, 2)
+		var release = make(chan struct{})
+		action.Functions.Register("bulkheadBlock", func(ctx types.RuleContext, msg types.RuleMsg) {
+			started <- struct{}{}
+			<-release
+			ctx.TellSuccess(msg)
+		})
+		var blockChainFile = `{
+          "ruleChain": {
+            "id": "%s",
+            "configuration": {
+              "pool": {
+                "maxWorkers": %d,
+                "queueSize": %d
+              }
+            }
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "bulkheadBlock"
+                }
+              }
+            ]
+          }
+        }`
+		enginePool := NewPool()
+		defer enginePool.Stop()
+		small, err := enginePool.New("", []byte(fmt.Sprintf(blockChainFile, "testBulkheadSmall", 1, 0)), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		large, err := enginePool.New("", []byte(fmt.Sprintf(blockChainFile, "testBulkheadLarge", 4, 8)), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		small.OnMsg(newMsg(), types.WithOnAllNodeCompleted(wg.Done))
+		<-started
+		//每个规则链报告自己的协程池
+		smallMetrics := small.(*RuleEngine).PoolMetrics()
+		assert.Equal(t, int64(1), smallMetrics.MaxWorkers)
+		assert.Equal(t, int64(1), smallMetrics.Active)
+		largeMetrics := large.(*RuleEngine).PoolMetrics()
+		assert.Equal(t, int64(4), largeMetrics.MaxWorkers)
+		assert.Equal(t, int64(8), largeMetrics.QueueSize)
+		assert.Equal(t, int64(0), largeMetrics.Active)
+
+		//重新加载其中一个规则链，不影响另一个规则链的指标
+		err = large.ReloadSelf([]byte(fmt.Sprintf(blockChainFile, "testBulkheadLarge", 2, 4)))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), small.(*RuleEngine).PoolMetrics().MaxWorkers)
+		assert.Equal(t, int64(2), large.(*RuleEngine).PoolMetrics().MaxWorkers)
+		close(release)
+		wg.Wait()
+	})
+
+	t.Run("InvalidPolicy", func(t *testing.T) {
+		_, err := New("testChainBulkhead", []byte(fmtChain(ruleChainFile, "drop")), WithConfig(NewConfig()))
+		assert.NotNil(t, err)
+		Del("testChainBulkhead")
+	})
+}
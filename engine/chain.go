@@ -21,8 +21,10 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/rulego/rulego/api/pool"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/utils/aes"
+	"github.com/rulego/rulego/utils/maps"
 	"github.com/rulego/rulego/utils/str"
 )
 
@@ -32,6 +34,18 @@ type RelationCache struct {
 	relationType string           // Type of relationship with the outgoing node
 }
 
+// bulkheadPool submits the tasks of the rule chain to its dedicated worker pool.
+// The overflow policy is applied when messages enter the rule chain (see Bulkhead.Acquire). The tasks of in-flight messages
+// are deferred to the backlog of the pool if it is full, so they are neither lost nor run in the goroutines of
+// the endpoints or other rule chains.
+type bulkheadPool struct {
+	*pool.Bulkhead
+}
+
+func (p bulkheadPool) Submit(task func()) error {
+	return p.SubmitOrDefer(task)
+}
+
 // RuleChainCtx defines an instance of a rule chain.
 // It initializes all nodes and records the routing relationships between all nodes in the rule chain.
 type RuleChainCtx struct {
@@ -53,6 +67,7 @@ type RuleChainCtx struct {
 	vars               map[string]string                             // Map of variables
 	decryptSecrets     map[string]string                             // Map of decrypted secrets
 	isEmpty            bool                                          // Indicates whether the rule chain has no nodes
+	bulkhead           *pool.Bulkhead                                // Worker pool dedicated to the rule chain, nil means using config.Pool
 	sync.RWMutex                                                     // Read/write mutex lock
 }
 
@@ -66,6 +81,13 @@ func InitRuleChainCtx(config types.Config, aspects types.AspectList, ruleChainDe
 		}
 	}
 
+	// Validate the contract of the rule chain
+	if ruleChainDef.RuleChain.Contract != nil {
+		if err := ruleChainDef.RuleChain.Contract.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Initialize a new RuleChainCtx with the provided configuration and aspects
 	var ruleChainCtx = &RuleChainCtx{
 		config:             config,
@@ -91,6 +113,17 @@ func InitRuleChainCtx(config types.Config, aspects types.AspectList, ruleChainDe
 		envConfig := ruleChainDef.RuleChain.Configuration[types.Secrets]
 		secrets := str.ToStringMapString(envConfig)
 		ruleChainCtx.decryptSecrets = decryptSecret(secrets, []byte(config.SecretKey))
+		// Create the worker pool dedicated to the rule chain
+		if poolConfig, ok := ruleChainDef.RuleChain.Configuration[types.ChainConfigurationKeyPool]; ok && poolConfig != nil {
+			var bulkheadConfig pool.BulkheadConfig
+			if err := maps.Map2Struct(poolConfig, &bulkheadConfig); err != nil {
+				return nil, err
+			}
+			if err := bulkheadConfig.Validate(); err != nil {
+				return nil, err
+			}
+			ruleChainCtx.bulkhead = pool.NewBulkhead(bulkheadConfig)
+		}
 	}
 	nodeLen := len(ruleChainDef.Metadata.Nodes)
 	ruleChainCtx.nodeIds = make([]types.RuleNodeId, nodeLen)
@@ -161,15 +194,19 @@ func InitRuleChainCtx(config types.Config, aspects types.AspectList, ruleChainDe
 		}
 		ruleChainCtx.parentNodeIds[outNodeId] = parentNodeIds
 	}
+	var chainPool = config.Pool
+	if ruleChainCtx.bulkhead != nil {
+		chainPool = bulkheadPool{ruleChainCtx.bulkhead}
+	}
 	// Initialize the root rule context
 	if firstNode, ok := ruleChainCtx.GetFirstNode(); ok {
 		ruleChainCtx.rootRuleContext = NewRuleContext(context.TODO(), ruleChainCtx.config, ruleChainCtx, nil,
-			firstNode, config.Pool, nil, nil)
+			firstNode, chainPool, nil, nil)
 	} else {
 		// If there are no nodes, initialize an empty node context
 		ruleNodeCtx, _ := InitRuleNodeCtx(config, ruleChainCtx, aspects, &types.RuleNode{})
 		ruleChainCtx.rootRuleContext = NewRuleContext(context.TODO(), ruleChainCtx.config, ruleChainCtx, nil,
-			ruleNodeCtx, config.Pool, nil, nil)
+			ruleNodeCtx, chainPool, nil, nil)
 		ruleChainCtx.isEmpty = true
 	}
 
@@ -290,15 +327,21 @@ func (rc *RuleChainCtx) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 }
 
 // Destroy cleans up resources and executes destroy aspects
+// Nodes are destroyed without holding the lock, because nodes such as aggregate and batch
+// send their pending messages to the next nodes when they are destroyed.
 func (rc *RuleChainCtx) Destroy() {
 	rc.RLock()
-	defer rc.RUnlock()
+	var nodes = make([]types.NodeCtx, 0, len(rc.nodes))
 	for _, v := range rc.nodes {
-		temp := v
-		temp.Destroy()
+		nodes = append(nodes, v)
+	}
+	destroyAspects := rc.destroyAspects
+	rc.RUnlock()
+	for _, v := range nodes {
+		v.Destroy()
 	}
 	// Execute destroy aspects
-	for _, aop := range rc.destroyAspects {
+	for _, aop := range destroyAspects {
 		aop.OnDestroy(rc)
 	}
 }
@@ -327,6 +370,12 @@ func (rc *RuleChainCtx) ReloadSelfFromDef(def types.RuleChain) error {
 	if def.RuleChain.Disabled {
 		return ErrDisabled
 	}
+	if err := migrateOnLoad(rc.GetRuleEnginePool(), rc.config, &def); err != nil {
+		return err
+	}
+	if err := validateContracts(rc.GetRuleEnginePool(), rc.Id.Id, &def); err != nil {
+		return err
+	}
 	if ctx, err := InitRuleChainCtx(rc.config, rc.aspects, &def); err == nil {
 		rc.Destroy()
 		rc.Copy(ctx)
@@ -387,6 +436,7 @@ func (rc *RuleChainCtx) Copy(newCtx *RuleChainCtx) {
 	rc.destroyAspects = newCtx.destroyAspects
 	rc.vars = newCtx.vars
 	rc.decryptSecrets = newCtx.decryptSecrets
+	rc.bulkhead = newCtx.bulkhead
 	// Clear cache
 	rc.relationCache = make(map[RelationCache][]types.NodeCtx)
 }
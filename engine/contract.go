@@ -0,0 +1,111 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/maps"
+	"strings"
+)
+
+// contractCallConfiguration flow/ref 节点调用子规则链契约的配置
+type contractCallConfiguration struct {
+	TargetId string
+	Inputs   map[string]string
+	Outputs  map[string]string
+}
+
+// validateContracts 校验规则链中 flow/ref 节点的输入输出映射是否满足目标规则链的契约
+// 只校验已经加载到规则链池的目标规则链，未加载的目标规则链在加载时校验
+// 同时使用该规则链的契约重新校验已经加载的调用方规则链，契约不满足调用方的映射则加载失败
+// id 是该规则链在规则链池中的ID
+func validateContracts(pool types.RuleEnginePool, id string, def *types.RuleChain) error {
+	if pool == nil || def == nil {
+		return nil
+	}
+	if err := validateCalls(def, func(targetId string) (*types.RuleChain, bool) {
+		if e, ok := pool.Get(targetId); ok {
+			target := e.Definition()
+			return &target, true
+		}
+		return nil, false
+	}); err != nil {
+		return err
+	}
+	//只校验调用本规则链的节点，使用新的定义
+	getSelf := func(targetId string) (*types.RuleChain, bool) {
+		if targetId == id {
+			return def, true
+		}
+		return nil, false
+	}
+	var err error
+	pool.Range(func(key, value any) bool {
+		e, ok := value.(types.RuleEngine)
+		if !ok || e.Id() == id {
+			return true
+		}
+		caller := e.Definition()
+		if callErr := validateCalls(&caller, getSelf); callErr != nil {
+			err = fmt.Errorf("ruleChain id=%s %w", e.Id(), callErr)
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+// validateCalls 校验规则链中 flow/ref 节点的输入输出映射，getTarget 获取目标规则链定义，不存在则跳过
+func validateCalls(def *types.RuleChain, getTarget func(targetId string) (*types.RuleChain, bool)) error {
+	for _, node := range def.Metadata.Nodes {
+		if node == nil || (node.Type != "flow" && node.Type != "ref") {
+			continue
+		}
+		var config contractCallConfiguration
+		if err := maps.Map2Struct(node.Configuration, &config); err != nil {
+			return fmt.Errorf("node id=%s %w", node.Id, err)
+		}
+		targetId := config.TargetId
+		if node.Type == "ref" {
+			values := strings.Split(targetId, ":")
+			if len(values) < 2 {
+				//引用本规则链节点
+				continue
+			}
+			targetId = values[0]
+		}
+		if targetId == "" || targetId == def.RuleChain.ID {
+			continue
+		}
+		target, ok := getTarget(targetId)
+		if !ok {
+			continue
+		}
+		contract := target.RuleChain.Contract
+		if contract == nil {
+			if len(config.Inputs) > 0 || len(config.Outputs) > 0 {
+				return fmt.Errorf("node id=%s ruleChain id=%s has no contract", node.Id, targetId)
+			}
+			continue
+		}
+		if err := contract.ValidateMapping(config.Inputs, config.Outputs); err != nil {
+			return fmt.Errorf("node id=%s %w", node.Id, err)
+		}
+	}
+	return nil
+}
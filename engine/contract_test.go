@@ -0,0 +1,177 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test/assert"
+)
+
+var contractSubChain = `{
+  "ruleChain": {
+    "id": "testAlarmLevel",
+    "name": "告警等级",
+    "contract": {
+      "inputs": [
+        {"name": "deviceId", "type": "string", "required": true},
+        {"name": "threshold", "type": "number", "default": 50}
+      ],
+      "outputs": [
+        {"name": "level", "type": "string"},
+        {"name": "leak", "type": "string"}
+      ]
+    }
+  },
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata['level']=msg.temperature > Number(metadata['threshold']) ? 'high' : 'low';metadata['leak']=metadata['productType'] || 'none';metadata['device']=metadata['deviceId'];return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      }
+    ],
+    "connections": []
+  }
+}`
+
+var contractCallerChain = `{
+  "ruleChain": {
+    "id": "testContractCaller"
+  },
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "flow",
+        "configuration": {
+          "targetId": "testAlarmLevel",
+          "extend": true,
+          "inputs": {"deviceId": "metadata.deviceName", "threshold": "metadata.threshold"},
+          "outputs": {"alarmLevel": "level", "leak": "leak"}
+        }
+      },
+      {
+        "id": "s2",
+        "type": "ref",
+        "configuration": {
+          "targetId": "testAlarmLevel:s1",
+          "inputs": {"deviceId": "'ref-' + metadata.deviceName"}
+        }
+      }
+    ],
+    "connections": [
+      {"fromId": "s1", "toId": "s2", "type": "Success"}
+    ]
+  }
+}`
+
+func TestContract(t *testing.T) {
+	pool := NewPool()
+	defer pool.Stop()
+	_, err := pool.New("testAlarmLevel", []byte(contractSubChain))
+	assert.Nil(t, err)
+
+	t.Run("Validate", func(t *testing.T) {
+		_, err := pool.New("testContractInvalid", []byte(strings.Replace(contractSubChain, `"type": "number", "default": 50`, `"type": "number", "default": "abc"`, 1)))
+		assert.Equal(t, "contract inputs: param threshold: must be a number", err.Error())
+
+		_, err = pool.New("testContractCaller", []byte(strings.Replace(contractCallerChain, `"deviceId": "metadata.deviceName", `, "", 1)))
+		assert.Equal(t, "node id=s1 contract input deviceId is required", err.Error())
+		_, err = pool.New("testContractCaller", []byte(strings.Replace(contractCallerChain, `"leak": "leak"`, `"leak": "unknown"`, 1)))
+		assert.Equal(t, "node id=s1 contract output unknown is not declared", err.Error())
+		_, err = pool.New("testContractCaller", []byte(strings.Replace(contractCallerChain, `"threshold": "metadata.threshold"`, `"url": "metadata.url"`, 1)))
+		assert.Equal(t, "node id=s1 contract input url is not declared", err.Error())
+	})
+
+	t.Run("Call", func(t *testing.T) {
+		caller, err := pool.New("testContractCaller", []byte(contractCallerChain))
+		assert.Nil(t, err)
+
+		onMsg := func(metadata types.Metadata) (types.RuleMsg, string, error) {
+			var endMsg types.RuleMsg
+			var relation string
+			var endErr error
+			msg := types.NewMsg(0, "TEST_MSG_TYPE1", types.JSON, metadata, "{\"temperature\":41}")
+			caller.OnMsgAndWait(msg, types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+				endMsg = msg
+				relation = relationType
+				endErr = err
+			}))
+			return endMsg, relation, endErr
+		}
+
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceName", "aa")
+		metadata.PutValue("threshold", "30")
+		metadata.PutValue("productType", "test")
+		msg, relation, err := onMsg(metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relation)
+		//只合并声明的输出字段，调用方元数据保持不变
+		assert.Equal(t, "high", msg.Metadata.GetValue("alarmLevel"))
+		//调用方元数据不会泄露到子规则链
+		assert.Equal(t, "none", msg.Metadata.GetValue("leak"))
+		assert.Equal(t, "", msg.Metadata.GetValue("device"))
+		assert.Equal(t, "test", msg.Metadata.GetValue("productType"))
+		assert.Equal(t, "aa", msg.Metadata.GetValue("deviceName"))
+		//ref 节点没有配置输出映射，合并所有声明的输出字段，并使用默认值
+		assert.Equal(t, "low", msg.Metadata.GetValue("level"))
+
+		//输入参数类型错误
+		metadata.PutValue("threshold", "abc")
+		_, relation, err = onMsg(metadata)
+		assert.Equal(t, types.Failure, relation)
+		assert.Equal(t, "contract input threshold must be a number", err.Error())
+
+		//缺少必填参数
+		metadata = types.NewMetadata()
+		_, relation, err = onMsg(metadata)
+		assert.Equal(t, types.Failure, relation)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReverseOrder", func(t *testing.T) {
+		pool := NewPool()
+		defer pool.Stop()
+		//目标规则链还没有加载，加载时校验
+		_, err := pool.New("testContractCaller", []byte(contractCallerChain))
+		assert.Nil(t, err)
+		_, err = pool.New("testAlarmLevel", []byte(strings.Replace(contractSubChain, `"name": "threshold"`, `"name": "limit"`, 1)))
+		assert.Equal(t, "ruleChain id=testContractCaller node id=s1 contract input threshold is not declared", err.Error())
+
+		target, err := pool.New("testAlarmLevel", []byte(contractSubChain))
+		assert.Nil(t, err)
+		//重新加载的契约不满足调用方的映射，保留原来的规则链
+		err = target.ReloadSelf([]byte(strings.Replace(contractSubChain, `{"name": "leak", "type": "string"}`, `{"name": "leakType", "type": "string"}`, 1)))
+		assert.Equal(t, "ruleChain id=testContractCaller node id=s1 contract output leak is not declared", err.Error())
+		assert.NotNil(t, target.Definition().RuleChain.Contract)
+		assert.Equal(t, 2, len(target.Definition().RuleChain.Contract.Outputs))
+		assert.Equal(t, "leak", target.Definition().RuleChain.Contract.Outputs[1].Name)
+	})
+
+	t.Run("NoContract", func(t *testing.T) {
+		_, err := pool.New("testNoContract", []byte(strings.Replace(contractSubChain, `"contract"`, `"noContract"`, 1)))
+		assert.Nil(t, err)
+		_, err = pool.New("testContractCaller2", []byte(strings.Replace(contractCallerChain, `"targetId": "testAlarmLevel",`, `"targetId": "testNoContract",`, 1)))
+		assert.Equal(t, "node id=s1 ruleChain id=testNoContract has no contract", err.Error())
+	})
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+)
+
+// CoverageCollector aggregates the node hits and the relation edge hits per rule chain from test and production runs,
+// and reports the unvisited nodes and edges. It collects the node run logs by types.WithOnNodeCompleted:
+//
+//	collector := engine.NewCoverageCollector()
+//	ruleEngine.OnMsg(msg, collector.WithCoverage())
+//	fmt.Println(collector.Report().Text())
+type CoverageCollector struct {
+	chains map[string]*chainCoverage
+	lock   sync.Mutex
+}
+
+// chainCoverage 规则链覆盖率统计
+type chainCoverage struct {
+	def      *types.RuleChain
+	nodeHits map[string]int64
+	//edgeHits key:fromId+relationType
+	edgeHits map[edgeKey]int64
+}
+
+type edgeKey struct {
+	fromId       string
+	relationType string
+}
+
+// NewCoverageCollector creates a coverage collector.
+func NewCoverageCollector() *CoverageCollector {
+	return &CoverageCollector{chains: make(map[string]*chainCoverage)}
+}
+
+// WithCoverage returns the option that collects the coverage of a message execution.
+// It replaces the callback set by types.WithOnNodeCompleted, call OnNodeCompleted in that callback to use both.
+func (c *CoverageCollector) WithCoverage() types.RuleContextOption {
+	return types.WithOnNodeCompleted(c.OnNodeCompleted)
+}
+
+// AddChain registers a rule chain definition, so that it is reported even if it is never executed.
+func (c *CoverageCollector) AddChain(def types.RuleChain) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.getChain(&def)
+}
+
+// OnNodeCompleted records a node run log, it can be used as the callback of types.WithOnNodeCompleted.
+func (c *CoverageCollector) OnNodeCompleted(ctx types.RuleContext, nodeRunLog types.RuleNodeRunLog) {
+	if ctx == nil || ctx.RuleChain() == nil {
+		return
+	}
+	chainCtx, ok := ctx.RuleChain().(types.ChainCtx)
+	if !ok || chainCtx.Definition() == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.getChain(chainCtx.Definition()).hit(nodeRunLog)
+}
+
+// OnRuleChainCompleted records all the node run logs of a snapshot, it can be used as the callback of types.WithOnRuleChainCompleted.
+func (c *CoverageCollector) OnRuleChainCompleted(_ types.RuleContext, snapshot types.RuleChainRunSnapshot) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	def := snapshot.RuleChain
+	chain := c.getChain(&def)
+	for _, item := range snapshot.Logs {
+		chain.hit(item)
+	}
+}
+
+// Reset clears the collected hits.
+func (c *CoverageCollector) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.chains = make(map[string]*chainCoverage)
+}
+
+// getChain 获取规则链统计，如果规则链定义更新，则使用新的定义
+func (c *CoverageCollector) getChain(def *types.RuleChain) *chainCoverage {
+	chain, ok := c.chains[def.RuleChain.ID]
+	if !ok {
+		chain = &chainCoverage{
+			nodeHits: make(map[string]int64),
+			edgeHits: make(map[edgeKey]int64),
+		}
+		c.chains[def.RuleChain.ID] = chain
+	}
+	chain.def = def
+	return chain
+}
+
+func (c *chainCoverage) hit(nodeRunLog types.RuleNodeRunLog) {
+	c.nodeHits[nodeRunLog.Id]++
+	if nodeRunLog.RelationType != "" {
+		c.edgeHits[edgeKey{fromId: nodeRunLog.Id, relationType: nodeRunLog.RelationType}]++
+	}
+}
+
+// Report creates the coverage report of all rule chains, sorted by rule chain id.
+func (c *CoverageCollector) Report() CoverageReport {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var report CoverageReport
+	for _, chain := range c.chains {
+		report.Chains = append(report.Chains, chain.report())
+	}
+	sort.Slice(report.Chains, func(i, j int) bool {
+		return report.Chains[i].ChainId < report.Chains[j].ChainId
+	})
+	return report
+}
+
+// ChainReport creates the coverage report of a rule chain.
+func (c *CoverageCollector) ChainReport(chainId string) (ChainCoverageReport, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if chain, ok := c.chains[chainId]; ok {
+		return chain.report(), true
+	}
+	return ChainCoverageReport{}, false
+}
+
+func (c *chainCoverage) report() ChainCoverageReport {
+	report := ChainCoverageReport{
+		ChainId: c.def.RuleChain.ID,
+		Name:    c.def.RuleChain.Name,
+	}
+	for _, node := range c.def.Metadata.Nodes {
+		item := NodeCoverage{Id: node.Id, Type: node.Type, Name: node.Name, Hits: c.nodeHits[node.Id]}
+		report.Nodes = append(report.Nodes, item)
+		if item.Hits == 0 {
+			report.UnvisitedNodes = append(report.UnvisitedNodes, node.Id)
+		}
+	}
+	for _, conn := range c.def.Metadata.Connections {
+		item := EdgeCoverage{FromId: conn.FromId, ToId: conn.ToId, Type: conn.Type, Hits: c.edgeHits[edgeKey{fromId: conn.FromId, relationType: conn.Type}]}
+		report.Edges = append(report.Edges, item)
+		if item.Hits == 0 {
+			report.UnvisitedEdges = append(report.UnvisitedEdges, item)
+		}
+	}
+	report.NodeCoverage = coverageRatio(len(report.Nodes)-len(report.UnvisitedNodes), len(report.Nodes))
+	report.EdgeCoverage = coverageRatio(len(report.Edges)-len(report.UnvisitedEdges), len(report.Edges))
+	return report
+}
+
+func coverageRatio(visited, total int) float64 {
+	if total == 0 {
+		return 1
+	}
+	return float64(visited) / float64(total)
+}
+
+// CoverageReport is the coverage report of the rule chains.
+type CoverageReport struct {
+	Chains []ChainCoverageReport `json:"chains"`
+}
+
+// ChainCoverageReport is the coverage report of a rule chain.
+type ChainCoverageReport struct {
+	ChainId string `json:"chainId"`
+	Name    string `json:"name"`
+	// NodeCoverage is the ratio of visited nodes, from 0 to 1.
+	NodeCoverage float64 `json:"nodeCoverage"`
+	// EdgeCoverage is the ratio of visited edges, from 0 to 1.
+	EdgeCoverage float64 `json:"edgeCoverage"`
+	// Nodes are the hits of each node.
+	Nodes []NodeCoverage `json:"nodes"`
+	// Edges are the hits of each connection.
+	Edges []EdgeCoverage `json:"edges"`
+	// UnvisitedNodes are the ids of the nodes that are never executed.
+	UnvisitedNodes []string `json:"unvisitedNodes"`
+	// UnvisitedEdges are the connections that are never passed.
+	UnvisitedEdges []EdgeCoverage `json:"unvisitedEdges"`
+}
+
+// NodeCoverage is the hits of a node.
+type NodeCoverage struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	Hits int64  `json:"hits"`
+}
+
+// EdgeCoverage is the hits of a connection. The connection is hit when its from node ends with its relation type.
+type EdgeCoverage struct {
+	FromId string `json:"fromId"`
+	ToId   string `json:"toId"`
+	Type   string `json:"type"`
+	Hits   int64  `json:"hits"`
+}
+
+// JSON returns the report in JSON format.
+func (r CoverageReport) JSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Text returns the report in human-readable text format.
+func (r CoverageReport) Text() string {
+	var buf bytes.Buffer
+	for _, chain := range r.Chains {
+		buf.WriteString(chain.Text())
+	}
+	return buf.String()
+}
+
+// Text returns the report in human-readable text format.
+func (r ChainCoverageReport) Text() string {
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintf(&buf, "ruleChain %s(%s) nodes: %.1f%% edges: %.1f%%\n", r.ChainId, r.Name, r.NodeCoverage*100, r.EdgeCoverage*100)
+	for _, node := range r.Nodes {
+		_, _ = fmt.Fprintf(&buf, "  node %s(%s) hits: %d\n", node.Id, node.Type, node.Hits)
+	}
+	for _, edge := range r.Edges {
+		_, _ = fmt.Fprintf(&buf, "  edge %s -%s-> %s hits: %d\n", edge.FromId, edge.Type, edge.ToId, edge.Hits)
+	}
+	if len(r.UnvisitedNodes) > 0 {
+		_, _ = fmt.Fprintf(&buf, "  unvisited nodes: %v\n", r.UnvisitedNodes)
+	}
+	for _, edge := range r.UnvisitedEdges {
+		_, _ = fmt.Fprintf(&buf, "  unvisited edge: %s -%s-> %s\n", edge.FromId, edge.Type, edge.ToId)
+	}
+	return buf.String()
+}
+
+// Overlay returns the coverage overlay for the visual editor. The nodes and connections are keyed the same as the DSL:
+//
+//	{"ruleChainId":"rule01","nodes":{"s1":{"hits":3,"visited":true}},"connections":[{"fromId":"s1","toId":"s2","type":"True","hits":0,"visited":false}]}
+func (r ChainCoverageReport) Overlay() map[string]interface{} {
+	var nodes = make(map[string]interface{}, len(r.Nodes))
+	for _, node := range r.Nodes {
+		nodes[node.Id] = map[string]interface{}{
+			"hits":    node.Hits,
+			"visited": node.Hits > 0,
+		}
+	}
+	var connections = make([]map[string]interface{}, 0, len(r.Edges))
+	for _, edge := range r.Edges {
+		connections = append(connections, map[string]interface{}{
+			"fromId":  edge.FromId,
+			"toId":    edge.ToId,
+			"type":    edge.Type,
+			"hits":    edge.Hits,
+			"visited": edge.Hits > 0,
+		})
+	}
+	return map[string]interface{}{
+		"ruleChainId":  r.ChainId,
+		"nodeCoverage": r.NodeCoverage,
+		"edgeCoverage": r.EdgeCoverage,
+		"nodes":        nodes,
+		"connections":  connections,
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/json"
+)
+
+var coverageChain = `{
+  "ruleChain": {
+    "id": "testCoverage",
+    "name": "覆盖率"
+  },
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "jsFilter",
+        "configuration": {
+          "jsScript": "return msg.temperature > 50;"
+        }
+      },
+      {
+        "id": "s2",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s3",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s4",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      }
+    ],
+    "connections": [
+      {"fromId": "s1", "toId": "s2", "type": "True"},
+      {"fromId": "s1", "toId": "s3", "type": "False"},
+      {"fromId": "s1", "toId": "s4", "type": "Failure"}
+    ]
+  }
+}`
+
+func TestCoverage(t *testing.T) {
+	pool := NewPool()
+	defer pool.Stop()
+	ruleEngine, err := pool.New("testCoverage", []byte(coverageChain))
+	assert.Nil(t, err)
+
+	collector := NewCoverageCollector()
+	onMsg := func(data string) {
+		msg := types.NewMsg(0, "TEST_MSG_TYPE1", types.JSON, types.NewMetadata(), data)
+		ruleEngine.OnMsgAndWait(msg, collector.WithCoverage())
+	}
+	onMsg("{\"temperature\":60}")
+	onMsg("{\"temperature\":70}")
+
+	report, ok := collector.ChainReport("testCoverage")
+	assert.True(t, ok)
+	assert.Equal(t, "覆盖率", report.Name)
+	assert.Equal(t, 0.5, report.NodeCoverage)
+	assert.Equal(t, []string{"s3", "s4"}, report.UnvisitedNodes)
+	assert.Equal(t, int64(2), report.Nodes[0].Hits)
+	assert.Equal(t, int64(2), report.Edges[0].Hits)
+	assert.Equal(t, 2, len(report.UnvisitedEdges))
+	assert.Equal(t, "s3", report.UnvisitedEdges[0].ToId)
+
+	text := collector.Report().Text()
+	assert.True(t, strings.Contains(text, "ruleChain testCoverage(覆盖率) nodes: 50.0% edges: 33.3%"))
+	assert.True(t, strings.Contains(text, "unvisited edge: s1 -False-> s3"))
+
+	onMsg("{\"temperature\":20}")
+	report, _ = collector.ChainReport("testCoverage")
+	assert.Equal(t, []string{"s4"}, report.UnvisitedNodes)
+	assert.Equal(t, 1, len(report.UnvisitedEdges))
+
+	b, err := collector.Report().JSON()
+	assert.Nil(t, err)
+	var decoded CoverageReport
+	assert.Nil(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, "testCoverage", decoded.Chains[0].ChainId)
+
+	overlay := report.Overlay()
+	nodes := overlay["nodes"].(map[string]interface{})
+	assert.Equal(t, true, nodes["s3"].(map[string]interface{})["visited"])
+	assert.Equal(t, false, nodes["s4"].(map[string]interface{})["visited"])
+
+	//通过运行快照收集
+	collector.Reset()
+	msg := types.NewMsg(0, "TEST_MSG_TYPE1", types.JSON, types.NewMetadata(), "{\"temperature\":20}")
+	ruleEngine.OnMsgAndWait(msg, types.WithOnRuleChainCompleted(collector.OnRuleChainCompleted))
+	report, _ = collector.ChainReport("testCoverage")
+	assert.Equal(t, []string{"s2", "s4"}, report.UnvisitedNodes)
+
+	//未执行的规则链
+	def, err := (&JsonParser{}).DecodeRuleChain([]byte(strings.Replace(coverageChain, "testCoverage", "testCoverage2", 1)))
+	assert.Nil(t, err)
+	collector.AddChain(def)
+	report, ok = collector.ChainReport("testCoverage2")
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, report.NodeCoverage)
+	assert.Equal(t, 2, len(collector.Report().Chains))
+}
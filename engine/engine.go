@@ -39,9 +39,11 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/rulego/rulego/api/pool"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/builtin/aspect"
 	"github.com/rulego/rulego/builtin/funcs"
+	"github.com/rulego/rulego/utils/cache"
 )
 
 // Ensuring DefaultRuleContext implements types.RuleContext interface.
@@ -50,6 +52,9 @@ var _ types.RuleContext = (*DefaultRuleContext)(nil)
 // Ensuring RuleEngine implements types.RuleEngine interface.
 var _ types.RuleEngine = (*RuleEngine)(nil)
 
+// Ensuring RuleEngine implements types.LoadReporter interface.
+var _ types.LoadReporter = (*RuleEngine)(nil)
+
 var ErrDisabled = errors.New("the rule chain has been disabled")
 
 // BuiltinsAspects holds a list of built-in aspects for the rule engine.
@@ -558,6 +563,11 @@ func (ctx *DefaultRuleContext) SetOnAllNodeCompleted(onAllNodeCompleted func())
 	ctx.onAllNodeCompleted = onAllNodeCompleted
 }
 
+// GetOnAllNodeCompleted 获取所有节点执行完回调
+func (ctx *DefaultRuleContext) GetOnAllNodeCompleted() func() {
+	return ctx.onAllNodeCompleted
+}
+
 // DoOnEnd  结束规则链分支执行，触发 OnEnd 回调函数
 func (ctx *DefaultRuleContext) DoOnEnd(msg types.RuleMsg, err error, relationType string) {
 	//全局回调
@@ -808,6 +818,14 @@ func (ctx *DefaultRuleContext) tellNext(msg types.RuleMsg, nextNode types.NodeCt
 
 	nextCtx := ctx.NewNextNodeRuleContext(nextNode)
 
+	//节点被禁用，则通过配置的关系直接把消息发送到下一个节点
+	if nodeCtx, ok := nextNode.(*RuleNodeCtx); ok {
+		if disabled, disabledRelationType := nodeCtx.IsDisabled(msg); disabled {
+			nextCtx.TellNext(msg, disabledRelationType)
+			return
+		}
+	}
+
 	//环绕aop
 	if !nextCtx.executeAroundAop(msg, relationType) {
 		return
@@ -820,6 +838,9 @@ func (ctx *DefaultRuleContext) tellNext(msg types.RuleMsg, nextNode types.NodeCt
 // RuleEngine is the core structure for a rule engine instance.
 // Each RuleEngine instance has only one root rule chain, and it cannot process data without a set rule chain.
 type RuleEngine struct {
+	// inFlight is the number of messages currently being processed by the rule engine.
+	// It is the first field to guarantee 64-bit alignment for atomic operations.
+	inFlight int64
 	// Config is the configuration for the rule engine.
 	Config types.Config
 	// ruleChainPool is a pool of rule engine.
@@ -924,6 +945,20 @@ func (e *RuleEngine) initChain(def types.RuleChain) error {
 	if def.RuleChain.Disabled {
 		return ErrDisabled
 	}
+	pool := e.ruleChainPool
+	if pool == nil {
+		pool = DefaultPool
+	}
+	if err := migrateOnLoad(pool, e.Config, &def); err != nil {
+		return err
+	}
+	id := e.id
+	if id == "" {
+		id = def.RuleChain.ID
+	}
+	if err := validateContracts(pool, id, &def); err != nil {
+		return err
+	}
 	if ctx, err := InitRuleChainCtx(e.Config, e.Aspects, &def); err == nil {
 		if e.rootRuleChainCtx != nil {
 			ctx.Id = e.rootRuleChainCtx.Id
@@ -957,6 +992,8 @@ func (e *RuleEngine) ReloadSelf(dsl []byte, opts ...types.RuleEngineOption) erro
 		if len(e.Aspects) == 0 {
 			e.initBuiltinsAspects()
 		}
+		// Set the aspect lists before reloading, because destroying the old nodes may complete pending messages.
+		e.setChainAspects()
 		e.rootRuleChainCtx.config = e.Config
 		e.rootRuleChainCtx.SetAspects(e.Aspects)
 		//更新规则链
@@ -973,13 +1010,32 @@ func (e *RuleEngine) ReloadSelf(dsl []byte, opts ...types.RuleEngineOption) erro
 		} else {
 			return err
 		}
+		e.setChainAspects()
 	}
-	// Set the aspect lists.
+	return err
+}
+
+// setChainAspects sets the aspect lists applied to the rule chain execution.
+func (e *RuleEngine) setChainAspects() {
 	startAspects, endAspects, completedAspects := e.Aspects.GetChainAspects()
 	e.startAspects = startAspects
 	e.endAspects = endAspects
 	e.completedAspects = completedAspects
-	return err
+}
+
+// PoolMetrics returns the utilisation of the worker pool dedicated to the rule chain.
+// It is zero if the rule chain uses the shared pool.
+func (e *RuleEngine) PoolMetrics() metrics.PoolMetrics {
+	if e.rootRuleChainCtx == nil {
+		return metrics.PoolMetrics{}
+	}
+	e.rootRuleChainCtx.RLock()
+	bulkhead := e.rootRuleChainCtx.bulkhead
+	e.rootRuleChainCtx.RUnlock()
+	if bulkhead == nil {
+		return metrics.PoolMetrics{}
+	}
+	return bulkhead.Metrics()
 }
 
 // ReloadChild 更新根规则链或者其下某个节点
@@ -1046,6 +1102,10 @@ func (e *RuleEngine) RootRuleChainCtx() types.ChainCtx {
 func (e *RuleEngine) Stop() {
 	if e.rootRuleChainCtx != nil {
 		e.rootRuleChainCtx.Destroy()
+		// Stop the workers of the worker pool dedicated to the rule chain.
+		if e.rootRuleChainCtx.bulkhead != nil {
+			e.rootRuleChainCtx.bulkhead.Release()
+		}
 	}
 	e.initialized = false
 }
@@ -1078,6 +1138,13 @@ func (e *RuleEngine) GetMetrics() *metrics.EngineMetrics {
 	return nil
 }
 
+// InFlight returns the number of messages currently being processed by the rule engine.
+// It is used by endpoints as a backpressure signal.
+// Unlike EngineMetrics.Current, it is counted per rule engine and is not reset when other rule engines are created.
+func (e *RuleEngine) InFlight() int64 {
+	return atomic.LoadInt64(&e.inFlight)
+}
+
 // OnMsgWithEndFunc is a deprecated method that asynchronously processes a message using the rule engine.
 // The endFunc callback is used to obtain the results after the rule chain execution is complete.
 // Note: If the rule chain has multiple endpoints, the callback function will be executed multiple times.
@@ -1101,6 +1168,7 @@ func (e *RuleEngine) OnMsgWithOptions(msg types.RuleMsg, opts ...types.RuleConte
 func (e *RuleEngine) doOnAllNodeCompleted(rootCtxCopy *DefaultRuleContext, msg types.RuleMsg, customFunc func()) {
 	// Execute aspects upon completion of all nodes.
 	e.onAllNodeCompleted(rootCtxCopy, msg)
+	atomic.AddInt64(&e.inFlight, -1)
 
 	// Complete the run snapshot if it exists.
 	if rootCtxCopy.runSnapshot != nil {
@@ -1151,10 +1219,22 @@ func (e *RuleEngine) onMsgAndWait(msg types.RuleMsg, wait bool, opts ...types.Ru
 			e.onErrHandler(msg, rootCtxCopy, rootCtxCopy.err)
 			return
 		}
+		// Apply the overflow policy of the worker pool dedicated to the rule chain.
+		if b := rootCtxCopy.ruleChainCtx.bulkhead; b != nil {
+			if err := b.Acquire(); errors.Is(err, pool.ErrNoIdleWorkers) {
+				e.onErrHandler(msg, rootCtxCopy, types.ErrConcurrencyLimitReached)
+				return
+			} else if err != nil {
+				e.onErrHandler(msg, rootCtxCopy, err)
+				return
+			}
+		}
 		var err error
+		atomic.AddInt64(&e.inFlight, 1)
 		// Execute start aspects and update the message accordingly.
 		msg, err = e.onStart(rootCtxCopy, msg)
 		if err != nil {
+			atomic.AddInt64(&e.inFlight, -1)
 			e.onErrHandler(msg, rootCtxCopy, err)
 			return
 		}
@@ -1241,6 +1321,13 @@ func NewConfig(opts ...types.Option) types.Config {
 	if c.ComponentsRegistry == nil {
 		c.ComponentsRegistry = Registry
 	}
+	if c.Cache == nil {
+		c.Cache = cache.NewMemoryCache(cache.DefaultMaxSize)
+	}
+	if c.StateStore == nil {
+		//状态不能被淘汰，不限制容量
+		c.StateStore = cache.NewMemoryCache(0)
+	}
 	// register all udfs
 	for name, f := range funcs.ScriptFunc.GetAll() {
 		c.RegisterUdf(name, f)
@@ -1184,6 +1184,57 @@ func TestReload(t *testing.T) {
 	}))
 }
 
+// TestReloadAggregateMidWindow 测试窗口未关闭时重新加载规则链，发送聚合结果并结束挂起的消息
+func TestReloadAggregateMidWindow(t *testing.T) {
+	var ruleChainFile = `{
+          "ruleChain": {
+            "id": "testReloadAggregate"
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "aggregate",
+                "configuration": {
+                  "fields": {"temperature": "msg.temperature"},
+                  "functions": ["count", "max"],
+                  "sizeMs": %d
+                }
+              }
+            ]
+          }
+        }`
+	ruleEngine, err := New("testReloadAggregate", []byte(fmt.Sprintf(ruleChainFile, 60000)), WithConfig(NewConfig()))
+	assert.Nil(t, err)
+	defer Del("testReloadAggregate")
+
+	var results = make(chan types.RuleMsg, 2)
+	var completed = make(chan struct{})
+	ruleEngine.OnMsg(types.NewMsg(0, "TELEMETRY", types.JSON, types.NewMetadata(), "{\"temperature\":41}"),
+		types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			assert.Nil(t, err)
+			assert.Equal(t, types.Success, relationType)
+			results <- msg
+		}), types.WithOnAllNodeCompleted(func() {
+			close(completed)
+		}))
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, int64(1), ruleEngine.(*RuleEngine).InFlight())
+
+	err = ruleEngine.ReloadSelf([]byte(fmt.Sprintf(ruleChainFile, 30000)))
+	assert.Nil(t, err)
+	select {
+	case <-completed:
+	case <-time.After(time.Second * 2):
+		t.Fatal("held message is not completed after reload")
+	}
+	assert.Equal(t, 1, len(results))
+	msg := <-results
+	assert.True(t, strings.Contains(msg.Data, "\"count\":1"))
+	assert.True(t, strings.Contains(msg.Data, "\"max\":41"))
+	assert.Equal(t, int64(0), ruleEngine.(*RuleEngine).InFlight())
+}
+
 func TestUseVars(t *testing.T) {
 	var ruleChainFile = `{
           "ruleChain": {
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test/assert"
+)
+
+var featureFlagChain = `{
+  "ruleChain": {
+    "id": "testFeatureFlag",
+    "configuration": {
+      "vars": {"alarm": "on"}
+    }
+  },
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata['s1']='done';return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s2",
+        "type": "jsTransform",
+        "disabledWhen": "global.maintenance == 'true'",
+        "configuration": {
+          "jsScript": "metadata['s2']='sent';return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s3",
+        "type": "jsFilter",
+        "enabledWhen": "vars.alarm == 'on' && metadata.level == 'high'",
+        "disabledRelationType": "False",
+        "configuration": {
+          "jsScript": "return msg.temperature > 50;"
+        }
+      },
+      {
+        "id": "s4",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata['result']='alarm';return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s5",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata['result']='normal';return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      }
+    ],
+    "connections": [
+      {"fromId": "s1", "toId": "s2", "type": "Success"},
+      {"fromId": "s2", "toId": "s3", "type": "Success"},
+      {"fromId": "s3", "toId": "s4", "type": "True"},
+      {"fromId": "s3", "toId": "s5", "type": "False"}
+    ]
+  }
+}`
+
+func TestFeatureFlag(t *testing.T) {
+	config := NewConfig()
+	config.Properties.PutValue("maintenance", "false")
+	pool := NewPool()
+	defer pool.Stop()
+	ruleEngine, err := pool.New("testFeatureFlag", []byte(featureFlagChain), types.WithConfig(config))
+	assert.Nil(t, err)
+
+	onMsg := func(level string) types.RuleMsg {
+		var endMsg types.RuleMsg
+		metadata := types.NewMetadata()
+		metadata.PutValue("level", level)
+		msg := types.NewMsg(0, "TEST_MSG_TYPE1", types.JSON, metadata, "{\"temperature\":60}")
+		ruleEngine.OnMsgAndWait(msg, types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			endMsg = msg
+		}))
+		return endMsg
+	}
+
+	msg := onMsg("high")
+	assert.Equal(t, "done", msg.Metadata.GetValue("s1"))
+	assert.Equal(t, "sent", msg.Metadata.GetValue("s2"))
+	assert.Equal(t, "alarm", msg.Metadata.GetValue("result"))
+
+	//enabledWhen 不满足，通过 False 关系跳过节点
+	msg = onMsg("low")
+	assert.Equal(t, "normal", msg.Metadata.GetValue("result"))
+
+	//开启维护模式，跳过 s2 节点
+	pool.SetFlag("maintenance", "true")
+	v, ok := pool.GetFlag("maintenance")
+	assert.True(t, ok)
+	assert.Equal(t, "true", v)
+	msg = onMsg("high")
+	assert.Equal(t, "done", msg.Metadata.GetValue("s1"))
+	assert.Equal(t, "", msg.Metadata.GetValue("s2"))
+	assert.Equal(t, "alarm", msg.Metadata.GetValue("result"))
+
+	//删除标志，恢复使用全局配置
+	pool.DelFlag("maintenance")
+	msg = onMsg("high")
+	assert.Equal(t, "sent", msg.Metadata.GetValue("s2"))
+
+	_, err = pool.New("testFeatureFlag2", []byte(strings.Replace(featureFlagChain, "global.maintenance == 'true'", "global.maintenance ==", 1)))
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "node id=s2 disabledWhen"))
+}
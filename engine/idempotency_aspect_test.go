@@ -0,0 +1,146 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/builtin/aspect"
+	"github.com/rulego/rulego/components/action"
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestIdempotencyAspect(t *testing.T) {
+	var ruleChainFile = `{
+          "ruleChain": {
+            "id": "testIdempotencyAspect"
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "idempotencyRun"
+                }
+              }
+            ]
+          }
+        }`
+	var count int64
+	action.Functions.Register("idempotencyRun", func(ctx types.RuleContext, msg types.RuleMsg) {
+		time.Sleep(time.Millisecond * 100)
+		if msg.Metadata.GetValue("fail") == "true" {
+			atomic.AddInt64(&count, 1)
+			ctx.TellFailure(msg, errors.New("fail"))
+			return
+		}
+		msg.Data = "result" + strconv.FormatInt(atomic.AddInt64(&count, 1), 10)
+		ctx.TellSuccess(msg)
+	})
+	newMsg := func(metadata types.Metadata) types.RuleMsg {
+		return types.NewMsg(0, "TEST_MSG_TYPE1", types.JSON, metadata, "{\"temperature\":41}")
+	}
+	type result struct {
+		data         string
+		err          error
+		relationType string
+	}
+	onMsg := func(ruleEngine types.RuleEngine, msg types.RuleMsg) result {
+		var r result
+		ruleEngine.OnMsgAndWait(msg, types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			r = result{data: msg.Data, err: err, relationType: relationType}
+		}))
+		return r
+	}
+
+	t.Run("ReplayResult", func(t *testing.T) {
+		atomic.StoreInt64(&count, 0)
+		ruleEngine, err := New("testIdempotencyAspect", []byte(ruleChainFile), WithConfig(NewConfig()),
+			types.WithAspects(aspect.NewIdempotencyAspect("", time.Minute)))
+		assert.Nil(t, err)
+		defer Del("testIdempotencyAspect")
+
+		msg := newMsg(types.NewMetadata())
+		r := onMsg(ruleEngine, msg)
+		assert.Equal(t, result{data: "result1", relationType: types.Success}, r)
+		//重复消息返回首次执行的结果，不再执行节点
+		r = onMsg(ruleEngine, msg)
+		assert.Equal(t, result{data: "result1", relationType: types.Success}, r)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&count))
+
+		r = onMsg(ruleEngine, newMsg(types.NewMetadata()))
+		assert.Equal(t, "result2", r.data)
+
+		//首次执行还未结束
+		msg = newMsg(types.NewMetadata())
+		ruleEngine.OnMsg(msg)
+		time.Sleep(time.Millisecond * 20)
+		r = onMsg(ruleEngine, msg)
+		assert.True(t, errors.Is(r.err, types.ErrDuplicateMsg))
+		assert.Equal(t, types.Duplicate, r.relationType)
+		time.Sleep(time.Millisecond * 150)
+		assert.Equal(t, int64(3), atomic.LoadInt64(&count))
+	})
+
+	t.Run("RetryAfterFailure", func(t *testing.T) {
+		atomic.StoreInt64(&count, 0)
+		ruleEngine, err := New("testIdempotencyAspect", []byte(ruleChainFile), WithConfig(NewConfig()),
+			types.WithAspects(aspect.NewIdempotencyAspect("", time.Minute)))
+		assert.Nil(t, err)
+		defer Del("testIdempotencyAspect")
+
+		metadata := types.NewMetadata()
+		metadata.PutValue("fail", "true")
+		msg := newMsg(metadata)
+		r := onMsg(ruleEngine, msg)
+		assert.Equal(t, types.Failure, r.relationType)
+		//执行失败不缓存结果，重试重新执行
+		r = onMsg(ruleEngine, msg)
+		assert.Equal(t, types.Failure, r.relationType)
+		assert.Equal(t, int64(2), atomic.LoadInt64(&count))
+	})
+
+	t.Run("KeyAndWindow", func(t *testing.T) {
+		atomic.StoreInt64(&count, 0)
+		ruleEngine, err := New("testIdempotencyAspect", []byte(ruleChainFile), WithConfig(NewConfig()),
+			types.WithAspects(aspect.NewIdempotencyAspect("metadata.requestId", time.Millisecond*300)))
+		assert.Nil(t, err)
+		defer Del("testIdempotencyAspect")
+
+		metadata := types.NewMetadata()
+		metadata.PutValue("requestId", "r1")
+		//消息ID不同，key相同
+		assert.Equal(t, "result1", onMsg(ruleEngine, newMsg(metadata)).data)
+		assert.Equal(t, "result1", onMsg(ruleEngine, newMsg(metadata)).data)
+		time.Sleep(time.Millisecond * 300)
+		//超过时间窗口，重新执行
+		assert.Equal(t, "result2", onMsg(ruleEngine, newMsg(metadata)).data)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		_, err := New("testIdempotencyAspect", []byte(ruleChainFile), WithConfig(NewConfig()),
+			types.WithAspects(aspect.NewIdempotencyAspect("metadata.requestId +", time.Minute)))
+		assert.NotNil(t, err)
+		Del("testIdempotencyAspect")
+	})
+}
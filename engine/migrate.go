@@ -0,0 +1,159 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/rulego/rulego/api/types"
+)
+
+// maxMigrateRounds 节点类型被迁移成其他类型后，继续执行新类型迁移器的最大次数，防止迁移器互相迁移导致死循环
+const maxMigrateRounds = 10
+
+// MigrationChange 迁移变更记录
+type MigrationChange struct {
+	// NodeId 节点ID
+	NodeId string `json:"nodeId"`
+	// NodeType 迁移前的节点类型
+	NodeType string `json:"nodeType"`
+	// NewNodeType 迁移后的节点类型
+	NewNodeType string `json:"newNodeType"`
+	// Version 引入该变更的版本
+	Version string `json:"version"`
+	// Desc 变更说明
+	Desc string `json:"desc"`
+}
+
+// MigrationReport 规则链迁移报告
+type MigrationReport struct {
+	// ChainId 规则链ID
+	ChainId string `json:"chainId"`
+	// Changes 变更列表
+	Changes []MigrationChange `json:"changes"`
+}
+
+// Changed 规则链是否有变更
+func (r MigrationReport) Changed() bool {
+	return len(r.Changes) > 0
+}
+
+// String 文本格式的迁移报告
+func (r MigrationReport) String() string {
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintf(&buf, "ruleChain %s: %d changes\n", r.ChainId, len(r.Changes))
+	for _, change := range r.Changes {
+		_, _ = fmt.Fprintf(&buf, "  node %s %s -> %s [%s] %s\n", change.NodeId, change.NodeType, change.NewNodeType, change.Version, change.Desc)
+	}
+	return buf.String()
+}
+
+// Migrate 使用默认注册器已注册组件的迁移器，把规则链中废弃的节点类型和配置升级到新版本
+// 组件通过实现 types.Migratable 接口提供迁移器
+func Migrate(def *types.RuleChain) (MigrationReport, error) {
+	return MigrateWithRegistry(Registry, def)
+}
+
+// MigrateWithRegistry 使用指定组件注册器的迁移器迁移规则链
+func MigrateWithRegistry(registry types.ComponentRegistry, def *types.RuleChain) (MigrationReport, error) {
+	report := MigrationReport{ChainId: def.RuleChain.ID}
+	if registry == nil {
+		registry = Registry
+	}
+	components := registry.GetComponents()
+	//迁移过程中增加的节点也会执行迁移
+	for i := 0; i < len(def.Metadata.Nodes); i++ {
+		node := def.Metadata.Nodes[i]
+		if node == nil {
+			continue
+		}
+		for round := 0; round < maxMigrateRounds; round++ {
+			nodeType := node.Type
+			migratable, ok := components[nodeType].(types.Migratable)
+			if !ok {
+				break
+			}
+			for _, migration := range migratable.Migrations() {
+				if node.Configuration == nil {
+					node.Configuration = make(types.Configuration)
+				}
+				oldType := node.Type
+				changed, err := migration.Migrate(def, node)
+				if err != nil {
+					return report, fmt.Errorf("migrate node id=%s type=%s %s: %w", node.Id, oldType, migration.Version, err)
+				}
+				if changed {
+					report.Changes = append(report.Changes, MigrationChange{
+						NodeId:      node.Id,
+						NodeType:    oldType,
+						NewNodeType: node.Type,
+						Version:     migration.Version,
+						Desc:        migration.Desc,
+					})
+				}
+				if node.Type != oldType {
+					break
+				}
+			}
+			if node.Type == nodeType {
+				break
+			}
+		}
+	}
+	return report, nil
+}
+
+// MigrateDsl 迁移规则链DSL，返回迁移后的DSL和迁移报告
+// 如果没有变更，返回原DSL
+func MigrateDsl(config types.Config, dsl []byte) ([]byte, MigrationReport, error) {
+	if config.Parser == nil {
+		config.Parser = &JsonParser{}
+	}
+	def, err := config.Parser.DecodeRuleChain(dsl)
+	if err != nil {
+		return nil, MigrationReport{}, err
+	}
+	report, err := MigrateWithRegistry(config.ComponentsRegistry, &def)
+	if err != nil || !report.Changed() {
+		return dsl, report, err
+	}
+	newDsl, err := config.Parser.EncodeRuleChain(def)
+	return newDsl, report, err
+}
+
+// migrateOnLoad 如果规则链池开启了加载时迁移，迁移规则链定义
+func migrateOnLoad(pool types.RuleEnginePool, config types.Config, def *types.RuleChain) error {
+	p, ok := pool.(*Pool)
+	if !ok || def == nil {
+		return nil
+	}
+	p.migrationLock.RLock()
+	enabled, onMigrate := p.migrate, p.onMigrate
+	p.migrationLock.RUnlock()
+	if !enabled {
+		return nil
+	}
+	report, err := MigrateWithRegistry(config.ComponentsRegistry, def)
+	if err != nil {
+		return err
+	}
+	if report.Changed() && onMigrate != nil {
+		onMigrate(report)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test/assert"
+)
+
+var iteratorChain = `{
+  "ruleChain": {
+    "id": "testMigrateIterator"
+  },
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "iterator",
+        "configuration": {
+          "fieldName": "items",
+          "jsScript": "return item.value > 10 && index >= 0;"
+        }
+      },
+      {
+        "id": "s2",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata['high']=(metadata['high']||'')+msg.value+',';return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s3",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata['low']=(metadata['low']||'')+msg.value+',';return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s4",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata['result']='done';return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s5",
+        "type": "dbClient",
+        "configuration": {
+          "driverName": "mysql",
+          "sql": "select * from users where name = '${msg.name}' and age > ? and city = ${metadata.city}",
+          "params": [18]
+        }
+      }
+    ],
+    "connections": [
+      {"fromId": "s1", "toId": "s2", "type": "True"},
+      {"fromId": "s1", "toId": "s3", "type": "False"},
+      {"fromId": "s1", "toId": "s4", "type": "Success"}
+    ]
+  }
+}`
+
+func TestMigrate(t *testing.T) {
+	def, err := (&JsonParser{}).DecodeRuleChain([]byte(iteratorChain))
+	assert.Nil(t, err)
+	report, err := Migrate(&def)
+	assert.Nil(t, err)
+	assert.True(t, report.Changed())
+	assert.Equal(t, 1, len(report.Changes))
+	assert.Equal(t, "s1", report.Changes[0].NodeId)
+	assert.Equal(t, "iterator", report.Changes[0].NodeType)
+	assert.Equal(t, "for", report.Changes[0].NewNodeType)
+	assert.Equal(t, "v0.22.0", report.Changes[0].Version)
+	assert.True(t, strings.Contains(report.String(), "node s1 iterator -> for [v0.22.0]"))
+
+	assert.Equal(t, 6, len(def.Metadata.Nodes))
+	assert.Equal(t, "for", def.Metadata.Nodes[0].Type)
+	assert.Equal(t, "msg.items", def.Metadata.Nodes[0].Configuration["range"])
+	assert.Equal(t, "s1_filter", def.Metadata.Nodes[0].Configuration["do"])
+	filter := def.Metadata.Nodes[5]
+	assert.Equal(t, "s1_filter", filter.Id)
+	assert.Equal(t, "jsFilter", filter.Type)
+	var relations []string
+	for _, conn := range def.Metadata.Connections {
+		relations = append(relations, conn.FromId+":"+conn.Type+":"+conn.ToId)
+	}
+	assert.Equal(t, []string{"s1_filter:True:s2", "s1_filter:False:s3", "s1:Success:s4"}, relations)
+
+	//dbClient sql仍然支持变量，不迁移
+	db := def.Metadata.Nodes[4]
+	assert.Equal(t, "select * from users where name = '${msg.name}' and age > ? and city = ${metadata.city}", db.Configuration["sql"])
+	assert.Equal(t, []interface{}{float64(18)}, db.Configuration["params"])
+
+	//迁移是幂等的
+	report, err = Migrate(&def)
+	assert.Nil(t, err)
+	assert.False(t, report.Changed())
+
+	dsl, report, err := MigrateDsl(NewConfig(), []byte(iteratorChain))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(report.Changes))
+	assert.True(t, strings.Contains(string(dsl), "\"type\": \"for\""))
+	newDsl, report, err := MigrateDsl(NewConfig(), dsl)
+	assert.Nil(t, err)
+	assert.False(t, report.Changed())
+	assert.Equal(t, string(dsl), string(newDsl))
+}
+
+func TestMigrateDbClientSql(t *testing.T) {
+	for _, sql := range []string{
+		//变量作为表名
+		"select * from ${metadata.table} where id = ?",
+		//变量在like字符串中
+		"select * from users where name like '%${msg.name}%'",
+		//字符串中包含?
+		"select * from users where remark = 'why?' and name = '${msg.name}' and age > ?",
+	} {
+		def := types.RuleChain{RuleChain: types.RuleChainBaseInfo{ID: "testMigrateDbClient"}}
+		def.Metadata.Nodes = []*types.RuleNode{{Id: "s1", Type: "dbClient", Configuration: types.Configuration{
+			"sql":    sql,
+			"params": []interface{}{18},
+		}}}
+		report, err := Migrate(&def)
+		assert.Nil(t, err)
+		assert.False(t, report.Changed())
+		assert.Equal(t, sql, def.Metadata.Nodes[0].Configuration["sql"])
+		assert.Equal(t, []interface{}{18}, def.Metadata.Nodes[0].Configuration["params"])
+	}
+}
+
+func TestMigrateOnLoad(t *testing.T) {
+	//去掉dbClient节点，避免初始化时连接数据库
+	dsl := strings.Replace(iteratorChain, `"type": "dbClient"`, `"type": "log"`, 1)
+	pool := NewPool()
+	defer pool.Stop()
+
+	ruleEngine, err := pool.New("", []byte(dsl))
+	assert.Nil(t, err)
+	assert.Equal(t, "iterator", ruleEngine.Definition().Metadata.Nodes[0].Type)
+	pool.Del(ruleEngine.Id())
+
+	var reports []MigrationReport
+	pool.SetMigrateOnLoad(true, func(report MigrationReport) {
+		reports = append(reports, report)
+	})
+	ruleEngine, err = pool.New("", []byte(dsl))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(reports))
+	assert.Equal(t, "testMigrateIterator", reports[0].ChainId)
+	assert.Equal(t, "for", ruleEngine.Definition().Metadata.Nodes[0].Type)
+
+	var endMsg types.RuleMsg
+	var endNodeId string
+	msg := types.NewMsg(0, "TEST_MSG_TYPE1", types.JSON, types.NewMetadata(), `{"items":[{"value":1},{"value":20},{"value":30}]}`)
+	ruleEngine.OnMsgAndWait(msg, types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+		endMsg = msg
+		endNodeId = ctx.GetSelfId()
+	}))
+	assert.Equal(t, "s4", endNodeId)
+	assert.Equal(t, "done", endMsg.Metadata.GetValue("result"))
+	assert.Equal(t, "20,30,", endMsg.Metadata.GetValue("high"))
+	assert.Equal(t, "1,", endMsg.Metadata.GetValue("low"))
+
+	//重新加载同样执行迁移
+	assert.Nil(t, ruleEngine.ReloadSelf([]byte(dsl)))
+	assert.Equal(t, 2, len(reports))
+}
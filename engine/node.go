@@ -18,7 +18,11 @@ package engine
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/utils/str"
 )
@@ -35,6 +39,8 @@ type RuleNodeCtx struct {
 	config            types.Config     // Configuration of the rule engine
 	aspects           types.AspectList // List of AOP (Aspect-Oriented Programming) aspects
 	isInitNetResource bool             // Indicates if network resources should be initialized
+	disabledWhen      *vm.Program      // Compiled DisabledWhen expression
+	enabledWhen       *vm.Program      // Compiled EnabledWhen expression
 }
 
 // InitRuleNodeCtx initializes a RuleNodeCtx with the given parameters.
@@ -79,6 +85,15 @@ func initRuleNodeCtx(config types.Config, chainCtx *RuleChainCtx, aspects types.
 		if isInitNetResource {
 			configuration[types.NodeConfigurationKeyIsInitNetResource] = true
 		}
+		// Compile the conditions for enabling or disabling the node.
+		disabledWhen, err := compileNodeCondition(selfDefinition.DisabledWhen)
+		if err != nil {
+			return &RuleNodeCtx{}, fmt.Errorf("node id=%s disabledWhen: %w", selfDefinition.Id, err)
+		}
+		enabledWhen, err := compileNodeCondition(selfDefinition.EnabledWhen)
+		if err != nil {
+			return &RuleNodeCtx{}, fmt.Errorf("node id=%s enabledWhen: %w", selfDefinition.Id, err)
+		}
 		// Initialize the node with the processed configuration.
 		if err = node.Init(config, configuration); err != nil {
 			return &RuleNodeCtx{}, err
@@ -91,6 +106,8 @@ func initRuleNodeCtx(config types.Config, chainCtx *RuleChainCtx, aspects types.
 				config:            config,
 				aspects:           aspects,
 				isInitNetResource: isInitNetResource,
+				disabledWhen:      disabledWhen,
+				enabledWhen:       enabledWhen,
 			}, nil
 		}
 	}
@@ -161,6 +178,74 @@ func (rn *RuleNodeCtx) Copy(newCtx *RuleNodeCtx) {
 	rn.config = newCtx.config
 	rn.aspects = newCtx.aspects
 	rn.SelfDefinition = newCtx.SelfDefinition
+	rn.disabledWhen = newCtx.disabledWhen
+	rn.enabledWhen = newCtx.enabledWhen
+}
+
+// IsDisabled evaluates the DisabledWhen and EnabledWhen expressions of the node.
+// If the node is disabled, it returns true and the relation type used to pass the message through.
+func (rn *RuleNodeCtx) IsDisabled(msg types.RuleMsg) (bool, string) {
+	if rn.disabledWhen == nil && rn.enabledWhen == nil {
+		return false, ""
+	}
+	env := rn.conditionEnv(msg)
+	disabled := false
+	if rn.disabledWhen != nil {
+		disabled = runNodeCondition(rn.disabledWhen, env)
+	}
+	if !disabled && rn.enabledWhen != nil {
+		disabled = !runNodeCondition(rn.enabledWhen, env)
+	}
+	if !disabled {
+		return false, ""
+	}
+	if rn.SelfDefinition.DisabledRelationType != "" {
+		return true, rn.SelfDefinition.DisabledRelationType
+	}
+	return true, types.Success
+}
+
+// conditionEnv creates the environment of the node conditions: global properties overridden by the pool flags,
+// rule chain vars and message metadata.
+func (rn *RuleNodeCtx) conditionEnv(msg types.RuleMsg) map[string]interface{} {
+	global := make(map[string]string)
+	if rn.config.Properties != nil {
+		for k, v := range rn.config.Properties.Values() {
+			global[k] = v
+		}
+	}
+	var vars map[string]string
+	if rn.ChainCtx != nil {
+		vars = rn.ChainCtx.vars
+		if pool, ok := rn.ChainCtx.GetRuleEnginePool().(*Pool); ok {
+			pool.rangeFlags(func(key, value string) {
+				global[key] = value
+			})
+		}
+	}
+	return map[string]interface{}{
+		types.Global:      global,
+		types.Vars:        vars,
+		types.MetadataKey: msg.Metadata.Values(),
+	}
+}
+
+// compileNodeCondition compiles the expression of a node condition, returns nil if the expression is empty.
+func compileNodeCondition(condition string) (*vm.Program, error) {
+	if strings.TrimSpace(condition) == "" {
+		return nil, nil
+	}
+	return expr.Compile(condition, expr.AllowUndefinedVariables(), expr.AsBool())
+}
+
+// runNodeCondition runs the expression of a node condition, returns false if it fails.
+func runNodeCondition(program *vm.Program, env map[string]interface{}) bool {
+	out, err := vm.Run(program, env)
+	if err != nil {
+		return false
+	}
+	result, _ := out.(bool)
+	return result
 }
 
 // processVariables replaces placeholders in the node configuration with global and chain-specific variables.
@@ -17,8 +17,11 @@
 package engine
 
 import (
+	"context"
+	"fmt"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/utils/fs"
+	"github.com/rulego/rulego/utils/json"
 	"log"
 	"strings"
 	"sync"
@@ -26,12 +29,31 @@ import (
 
 var _ types.RuleEnginePool = (*Pool)(nil)
 
+// Ensuring Pool implements types.LoadReporter interface.
+var _ types.LoadReporter = (*Pool)(nil)
+
 var DefaultPool = &Pool{}
 
+// TestFileSuffix is the suffix of the declarative test case files placed next to the rule chain files.
+// Load and Watch skip them.
+const TestFileSuffix = ".test.json"
+
 // Pool is a pool of rule engine instances.
 type Pool struct {
 	// A concurrent map to store rule engine instances.
 	entries sync.Map
+	// Rule chain templates, templateId -> *types.RuleChainTemplate
+	templates sync.Map
+	// Rule chains instantiated from templates, ruleChainId -> *templateInstance
+	instances sync.Map
+	// Feature flags shared by all rule chains in the pool, key -> value.
+	// They override the global properties when evaluating node conditions.
+	flags sync.Map
+	// Whether to migrate the deprecated components and configurations when loading rule chains.
+	migrate bool
+	// Called when a loaded rule chain is migrated.
+	onMigrate     func(report MigrationReport)
+	migrationLock sync.RWMutex
 }
 
 // NewPool creates a new instance of a rule engine pool.
@@ -42,18 +64,8 @@ func NewPool() *Pool {
 // Load loads all rule chain configurations from a specified folder and its subfolders into the rule engine instance pool.
 // The rule chain ID is taken from the configuration file's ruleChain.id.
 func (g *Pool) Load(folderPath string, opts ...types.RuleEngineOption) error {
-	// Ensure the folder path ends with a pattern that matches JSON files.
-	if !strings.HasSuffix(folderPath, "*.json") && !strings.HasSuffix(folderPath, "*.JSON") {
-		if strings.HasSuffix(folderPath, "/") || strings.HasSuffix(folderPath, "\\") {
-			folderPath = folderPath + "*.json"
-		} else if folderPath == "" {
-			folderPath = "./*.json"
-		} else {
-			folderPath = folderPath + "/*.json"
-		}
-	}
 	// Get all file paths that match the pattern.
-	paths, err := fs.GetFilePaths(folderPath)
+	paths, err := ruleChainFilePaths(loadFilePattern(folderPath))
 	if err != nil {
 		return err
 	}
@@ -69,6 +81,35 @@ func (g *Pool) Load(folderPath string, opts ...types.RuleEngineOption) error {
 	return nil
 }
 
+// loadFilePattern ensures the folder path ends with a pattern that matches JSON files.
+func loadFilePattern(folderPath string) string {
+	if !strings.HasSuffix(folderPath, "*.json") && !strings.HasSuffix(folderPath, "*.JSON") {
+		if strings.HasSuffix(folderPath, "/") || strings.HasSuffix(folderPath, "\\") {
+			folderPath = folderPath + "*.json"
+		} else if folderPath == "" {
+			folderPath = "./*.json"
+		} else {
+			folderPath = folderPath + "/*.json"
+		}
+	}
+	return folderPath
+}
+
+// ruleChainFilePaths returns the files matching the pattern, skipping the test case files.
+func ruleChainFilePaths(pattern string) ([]string, error) {
+	paths, err := fs.GetFilePaths(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var result = paths[:0]
+	for _, path := range paths {
+		if !strings.HasSuffix(path, TestFileSuffix) {
+			result = append(result, path)
+		}
+	}
+	return result, nil
+}
+
 // New creates a new RuleEngine instance and stores it in the rule chain pool.
 // If the specified id is empty, the ruleChain.id from the rule chain file is used.
 func (g *Pool) New(id string, rootRuleChainSrc []byte, opts ...types.RuleEngineOption) (types.RuleEngine, error) {
@@ -108,6 +149,7 @@ func (g *Pool) Del(id string) {
 		v.(*RuleEngine).Stop()
 		g.entries.Delete(id)
 	}
+	g.instances.Delete(id)
 }
 
 // Stop releases all rule engine instances in the pool.
@@ -145,6 +187,39 @@ func (g *Pool) OnMsg(msg types.RuleMsg) {
 	})
 }
 
+// InFlight returns the total number of messages currently being processed by all rule engine instances in the pool.
+func (g *Pool) InFlight() int64 {
+	var total int64
+	g.entries.Range(func(key, value any) bool {
+		if item, ok := value.(*RuleEngine); ok {
+			total += item.InFlight()
+		}
+		return true
+	})
+	return total
+}
+
+// SetMigrateOnLoad sets whether the default rule engine instance pool migrates the deprecated components and configurations when loading rule chains.
+func SetMigrateOnLoad(enable bool, onMigrate func(report MigrationReport)) {
+	DefaultPool.SetMigrateOnLoad(enable, onMigrate)
+}
+
+// SetFlag sets a feature flag for all rule chains in the default rule engine instance pool.
+func SetFlag(key string, value string) {
+	DefaultPool.SetFlag(key, value)
+}
+
+// DelFlag deletes a feature flag from the default rule engine instance pool.
+func DelFlag(key string) {
+	DefaultPool.DelFlag(key)
+}
+
+// Watch loads all rule chain configurations from the specified folder and its subfolders into the default rule engine instance pool,
+// and keeps them in sync with the files. See Pool.Watch.
+func Watch(folderPath string, config WatchConfig, opts ...types.RuleEngineOption) (*Watcher, error) {
+	return DefaultPool.Watch(folderPath, config, opts...)
+}
+
 // Load loads all rule chain configurations from the specified folder and its subfolders into the default rule engine instance pool.
 // The rule chain ID is taken from the configuration file's ruleChain.id.
 func Load(folderPath string, opts ...types.RuleEngineOption) error {
@@ -171,6 +246,11 @@ func Stop() {
 	DefaultPool.Stop()
 }
 
+// Shutdown gracefully stops all rule engine instances in the default rule chain pool.
+func Shutdown(ctx context.Context) error {
+	return DefaultPool.Shutdown(ctx)
+}
+
 // OnMsg calls all rule engine instances in the default rule chain pool to process a message.
 // All rule chains in the rule engine instance pool will attempt to process the message.
 func OnMsg(msg types.RuleMsg) {
@@ -189,3 +269,139 @@ func Reload(opts ...types.RuleEngineOption) {
 func Range(f func(key, value any) bool) {
 	DefaultPool.entries.Range(f)
 }
+
+// SetMigrateOnLoad sets whether to migrate the deprecated components and configurations when loading or reloading rule chains,
+// onMigrate is called with the migration report when a rule chain is changed, it can be nil.
+// The migrated definition is used by the rule engine, the source file is not changed.
+func (g *Pool) SetMigrateOnLoad(enable bool, onMigrate func(report MigrationReport)) {
+	g.migrationLock.Lock()
+	defer g.migrationLock.Unlock()
+	g.migrate = enable
+	g.onMigrate = onMigrate
+}
+
+// SetFlag sets a feature flag for all rule chains in the pool.
+// Flags override the global properties when evaluating the disabledWhen/enabledWhen conditions of the nodes,
+// so that nodes can be switched on and off at runtime, for example: pool.SetFlag("maintenance", "true")
+func (g *Pool) SetFlag(key string, value string) {
+	g.flags.Store(key, value)
+}
+
+// GetFlag retrieves a feature flag by its key.
+func (g *Pool) GetFlag(key string) (string, bool) {
+	if v, ok := g.flags.Load(key); ok {
+		return v.(string), true
+	}
+	return "", false
+}
+
+// DelFlag deletes a feature flag, the global property with the same key takes effect again.
+func (g *Pool) DelFlag(key string) {
+	g.flags.Delete(key)
+}
+
+func (g *Pool) rangeFlags(f func(key, value string)) {
+	g.flags.Range(func(key, value any) bool {
+		f(key.(string), value.(string))
+		return true
+	})
+}
+
+// LoadTemplate loads or updates a rule chain template.
+// If the template already exists, every rule chain instantiated from it is re-instantiated with its parameters and reloaded.
+// The template is updated only if every rule chain is re-instantiated successfully. Otherwise the previous template is kept,
+// the rule chains that have been reloaded are restored to their previous definition and the errors are returned.
+func (g *Pool) LoadTemplate(def []byte) error {
+	tpl, err := DecodeTemplate(def)
+	if err != nil {
+		return err
+	}
+	//先实例化所有依赖的规则链，任意一个失败则不更新模板
+	var reloads []templateReload
+	var errs []string
+	g.instances.Range(func(key, value any) bool {
+		instance := value.(*templateInstance)
+		if instance.templateId != tpl.Template.ID {
+			return true
+		}
+		id := key.(string)
+		ruleEngine, ok := g.Get(id)
+		if !ok {
+			g.instances.Delete(id)
+			return true
+		}
+		if dsl, err := instantiateDsl(tpl, id, instance.params); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", id, err))
+		} else {
+			reloads = append(reloads, templateReload{id: id, ruleEngine: ruleEngine, dsl: dsl, prevDsl: ruleEngine.DSL()})
+		}
+		return true
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("reinstantiate rule chains error: %s", strings.Join(errs, "; "))
+	}
+	for i, item := range reloads {
+		if err := item.ruleEngine.ReloadSelf(item.dsl); err != nil {
+			//恢复已经重新加载的规则链
+			for _, done := range reloads[:i] {
+				_ = done.ruleEngine.ReloadSelf(done.prevDsl)
+			}
+			return fmt.Errorf("reinstantiate rule chains error: %s: %s", item.id, err)
+		}
+	}
+	g.templates.Store(tpl.Template.ID, &tpl)
+	return nil
+}
+
+// GetTemplate retrieves a rule chain template by its ID.
+func (g *Pool) GetTemplate(templateId string) (types.RuleChainTemplate, bool) {
+	if v, ok := g.templates.Load(templateId); ok {
+		return *v.(*types.RuleChainTemplate), true
+	}
+	return types.RuleChainTemplate{}, false
+}
+
+// DelTemplate deletes a rule chain template by its ID.
+// The rule chains instantiated from it are kept, but are no longer re-instantiated.
+func (g *Pool) DelTemplate(templateId string) {
+	g.templates.Delete(templateId)
+	g.instances.Range(func(key, value any) bool {
+		if value.(*templateInstance).templateId == templateId {
+			g.instances.Delete(key)
+		}
+		return true
+	})
+}
+
+// NewFromTemplate instantiates the template with the parameters and creates a new RuleEngine instance in the pool.
+// If the specified id is empty, the ruleChain.id in the template definition is used.
+// The rule chain is re-instantiated when the template is updated by LoadTemplate.
+func (g *Pool) NewFromTemplate(id string, templateId string, params map[string]interface{}, opts ...types.RuleEngineOption) (types.RuleEngine, error) {
+	tpl, ok := g.GetTemplate(templateId)
+	if !ok {
+		return nil, fmt.Errorf("template not found: %s", templateId)
+	}
+	def, err := InstantiateTemplate(tpl, id, params)
+	if err != nil {
+		return nil, err
+	}
+	dsl, err := json.Marshal(def)
+	if err != nil {
+		return nil, err
+	}
+	ruleEngine, err := g.New(def.RuleChain.ID, dsl, opts...)
+	if err != nil {
+		return nil, err
+	}
+	g.instances.Store(ruleEngine.Id(), &templateInstance{templateId: templateId, params: params})
+	return ruleEngine, nil
+}
+
+// instantiateDsl instantiates the template and returns the rule chain DSL.
+func instantiateDsl(tpl types.RuleChainTemplate, id string, params map[string]interface{}) ([]byte, error) {
+	def, err := InstantiateTemplate(tpl, id, params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(def)
+}
@@ -18,8 +18,10 @@ package engine
 
 import (
 	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/action"
 	"github.com/rulego/rulego/test"
 	"github.com/rulego/rulego/test/assert"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -120,3 +122,56 @@ func TestRuleGo(t *testing.T) {
 	assert.Equal(t, false, ok)
 
 }
+
+// TestPoolInFlight 测试每个规则引擎单独统计正在处理的消息数
+func TestPoolInFlight(t *testing.T) {
+	var started = make(chan struct{}, 1)
+	var release = make(chan struct{})
+	action.Functions.Register("inFlightBlock", func(ctx types.RuleContext, msg types.RuleMsg) {
+		started <- struct{}{}
+		<-release
+		ctx.TellSuccess(msg)
+	})
+	var ruleChainFile = `{
+          "ruleChain": {
+            "id": "%s"
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "inFlightBlock"
+                }
+              }
+            ]
+          }
+        }`
+	pool := NewPool()
+	defer pool.Stop()
+	c1, err := pool.New("inFlight01", []byte(fmt.Sprintf(ruleChainFile, "inFlight01")))
+	assert.Nil(t, err)
+	c2, err := pool.New("inFlight02", []byte(fmt.Sprintf(ruleChainFile, "inFlight02")))
+	assert.Nil(t, err)
+
+	var done = make(chan struct{})
+	c1.OnMsg(types.NewMsg(0, "TEST_MSG_TYPE1", types.JSON, types.NewMetadata(), "{}"), types.WithOnAllNodeCompleted(func() {
+		close(done)
+	}))
+	<-started
+	assert.Equal(t, int64(1), c1.(*RuleEngine).InFlight())
+	assert.Equal(t, int64(0), c2.(*RuleEngine).InFlight())
+	assert.Equal(t, int64(1), pool.InFlight())
+
+	//创建新的规则引擎不影响其他规则引擎的计数
+	_, err = pool.New("inFlight03", []byte(fmt.Sprintf(ruleChainFile, "inFlight03")))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), c1.(*RuleEngine).InFlight())
+	assert.Equal(t, int64(1), pool.InFlight())
+
+	close(release)
+	<-done
+	assert.Equal(t, int64(0), c1.(*RuleEngine).InFlight())
+	assert.Equal(t, int64(0), pool.InFlight())
+}
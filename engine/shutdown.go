@@ -0,0 +1,218 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+)
+
+var _ types.GracefulShutdowner = (*RuleEngine)(nil)
+var _ types.GracefulShutdowner = (*Pool)(nil)
+
+// shutdownCheckInterval is the interval used to poll in-flight messages during graceful shutdown.
+var shutdownCheckInterval = 10 * time.Millisecond
+
+// Shutdown gracefully stops the rule engine:
+//  1. stops the endpoints of the rule chain from accepting new input;
+//  2. drains the messages pending in nodes such as delay, sending them to the next nodes immediately,
+//     or handing them over to Config.OnShutdownPendingMsg if it is set, so that in-flight runs are not blocked by them;
+//  3. waits for the in-flight messages of the rule engine (RuleEngine.InFlight) to reach zero, or ctx to be done,
+//     messages being processed by other rule engines are not waited for;
+//  4. destroys the nodes.
+//
+// If ctx is done before in-flight messages complete, the nodes are still destroyed and ctx.Err() is returned.
+// Shared resources in Config.NetPool are not released, because they may be used by other rule engines; use Pool.Shutdown instead.
+func (e *RuleEngine) Shutdown(ctx context.Context) error {
+	return shutdownEngines(ctx, []*RuleEngine{e})
+}
+
+// Shutdown gracefully stops all rule engine instances in the pool and removes them from the pool.
+// Rule chains are destroyed in dependency order: a rule chain is destroyed before the sub rule chains it calls
+// through flow or ref nodes. Finally, the shared resources in Config.NetPool are released.
+func (g *Pool) Shutdown(ctx context.Context) error {
+	var engines []*RuleEngine
+	g.entries.Range(func(key, value any) bool {
+		if item, ok := value.(*RuleEngine); ok {
+			engines = append(engines, item)
+		}
+		return true
+	})
+	engines = sortByDependency(engines)
+	err := shutdownEngines(ctx, engines)
+
+	var netPools []types.NodePool
+	for _, item := range engines {
+		g.entries.Delete(item.Id())
+		g.instances.Delete(item.Id())
+		if item.Config.NetPool != nil && !containsNodePool(netPools, item.Config.NetPool) {
+			netPools = append(netPools, item.Config.NetPool)
+		}
+	}
+	for _, netPool := range netPools {
+		netPool.Stop()
+	}
+	return err
+}
+
+func shutdownEngines(ctx context.Context, engines []*RuleEngine) error {
+	//停止接收新的输入
+	for _, item := range engines {
+		item.onShutdown()
+	}
+	//排空节点挂起的消息
+	for _, item := range engines {
+		item.drain()
+	}
+	//等待正在处理的消息完成
+	err := waitInFlight(ctx, engines)
+	//按顺序销毁
+	for _, item := range engines {
+		item.Stop()
+	}
+	return err
+}
+
+// waitInFlight waits until the in-flight messages of the engines reach zero or ctx is done.
+func waitInFlight(ctx context.Context, engines []*RuleEngine) error {
+	for {
+		var inFlight int64
+		for _, item := range engines {
+			inFlight += item.InFlight()
+		}
+		if inFlight == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(shutdownCheckInterval):
+		}
+	}
+}
+
+// onShutdown executes the shutdown aspects, e.g. stops the endpoints of the rule chain.
+func (e *RuleEngine) onShutdown() {
+	if e.rootRuleChainCtx == nil {
+		return
+	}
+	for _, aop := range e.Aspects.GetShutdownAspects() {
+		aop.OnShutdown(e.rootRuleChainCtx)
+	}
+}
+
+// drain drains the messages pending in the nodes of the rule chain.
+func (e *RuleEngine) drain() {
+	onPendingMsg := e.Config.OnShutdownPendingMsg
+	e.rangeDrainableNodes(func(nodeId string, node types.DrainableNode) {
+		if onPendingMsg == nil {
+			node.Drain(nil)
+		} else {
+			node.Drain(func(msg types.RuleMsg) {
+				onPendingMsg(e.Id(), nodeId, msg)
+			})
+		}
+	})
+}
+
+func (e *RuleEngine) rangeDrainableNodes(f func(nodeId string, node types.DrainableNode)) {
+	if e.rootRuleChainCtx == nil {
+		return
+	}
+	var nodes = make(map[string]types.DrainableNode)
+	e.rootRuleChainCtx.RLock()
+	for id, nodeCtx := range e.rootRuleChainCtx.nodes {
+		if ruleNodeCtx, ok := nodeCtx.(*RuleNodeCtx); ok {
+			if node, ok := ruleNodeCtx.Node.(types.DrainableNode); ok {
+				nodes[id.Id] = node
+			}
+		}
+	}
+	//排空时会执行下一个节点，不能持有规则链锁
+	e.rootRuleChainCtx.RUnlock()
+	for id, node := range nodes {
+		f(id, node)
+	}
+}
+
+// sortByDependency sorts the rule engines so that callers come before the sub rule chains they call.
+func sortByDependency(engines []*RuleEngine) []*RuleEngine {
+	var byId = make(map[string]*RuleEngine)
+	for _, item := range engines {
+		byId[item.Id()] = item
+	}
+	sort.Slice(engines, func(i, j int) bool {
+		return engines[i].Id() < engines[j].Id()
+	})
+	var result []*RuleEngine
+	var visited = make(map[string]bool)
+	var visit func(item *RuleEngine)
+	//后序遍历，被调用的规则链排在前面，最后反转
+	visit = func(item *RuleEngine) {
+		if visited[item.Id()] {
+			return
+		}
+		visited[item.Id()] = true
+		for _, targetId := range subChainIds(item) {
+			if target, ok := byId[targetId]; ok {
+				visit(target)
+			}
+		}
+		result = append(result, item)
+	}
+	for _, item := range engines {
+		visit(item)
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// subChainIds returns the ids of the sub rule chains called by the rule engine through flow or ref nodes.
+func subChainIds(e *RuleEngine) []string {
+	if e.rootRuleChainCtx == nil || e.rootRuleChainCtx.SelfDefinition == nil {
+		return nil
+	}
+	var ids []string
+	for _, node := range e.rootRuleChainCtx.SelfDefinition.Metadata.Nodes {
+		if node == nil || (node.Type != "flow" && node.Type != "ref") {
+			continue
+		}
+		for k, v := range node.Configuration {
+			if strings.EqualFold(k, "targetId") {
+				if targetId, ok := v.(string); ok && targetId != "" {
+					ids = append(ids, strings.Split(targetId, ":")[0])
+				}
+			}
+		}
+	}
+	return ids
+}
+
+func containsNodePool(list []types.NodePool, item types.NodePool) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
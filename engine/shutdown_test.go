@@ -0,0 +1,267 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/action"
+	"github.com/rulego/rulego/test/assert"
+)
+
+var shutdownDelayChain = `{
+  "ruleChain": {
+    "id": "%s"
+  },
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "delay",
+        "configuration": {
+          "periodInSeconds": 10
+        }
+      },
+      {
+        "id": "s2",
+        "type": "functions",
+        "configuration": {
+          "functionName": "shutdownCount"
+        }
+      }
+    ],
+    "connections": [
+      {
+        "fromId": "s1",
+        "toId": "s2",
+        "type": "Success"
+      }
+    ]
+  }
+}`
+
+func TestShutdown(t *testing.T) {
+	var count int64
+	action.Functions.Register("shutdownCount", func(ctx types.RuleContext, msg types.RuleMsg) {
+		atomic.AddInt64(&count, 1)
+		ctx.TellSuccess(msg)
+	})
+	action.Functions.Register("shutdownSleep", func(ctx types.RuleContext, msg types.RuleMsg) {
+		time.Sleep(time.Millisecond * 300)
+		ctx.TellSuccess(msg)
+	})
+	newMsg := func() types.RuleMsg {
+		return types.NewMsg(0, "TEST_MSG_TYPE1", types.JSON, types.NewMetadata(), "{\"temperature\":41}")
+	}
+
+	t.Run("FlushPendingMsgs", func(t *testing.T) {
+		atomic.StoreInt64(&count, 0)
+		ruleEngine, err := New("testShutdownFlush", []byte(fmtChain(shutdownDelayChain, "testShutdownFlush")), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		ruleEngine.OnMsg(newMsg())
+		ruleEngine.OnMsg(newMsg())
+		time.Sleep(time.Millisecond * 100)
+		assert.Equal(t, int64(0), atomic.LoadInt64(&count))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+		defer cancel()
+		start := time.Now()
+		err = ruleEngine.(*RuleEngine).Shutdown(ctx)
+		assert.Nil(t, err)
+		assert.True(t, time.Since(start) < time.Second)
+		//挂起的消息立即发送到下一个节点
+		assert.Equal(t, int64(2), atomic.LoadInt64(&count))
+		assert.False(t, ruleEngine.Initialized())
+		Del("testShutdownFlush")
+	})
+
+	t.Run("PersistPendingMsgs", func(t *testing.T) {
+		atomic.StoreInt64(&count, 0)
+		var persisted []string
+		var lock sync.Mutex
+		config := NewConfig(types.WithOnShutdownPendingMsg(func(ruleChainId string, nodeId string, msg types.RuleMsg) {
+			lock.Lock()
+			defer lock.Unlock()
+			persisted = append(persisted, ruleChainId+":"+nodeId)
+		}))
+		ruleEngine, err := New("testShutdownPersist", []byte(fmtChain(shutdownDelayChain, "testShutdownPersist")), WithConfig(config))
+		assert.Nil(t, err)
+		ruleEngine.OnMsg(newMsg())
+		time.Sleep(time.Millisecond * 100)
+
+		err = ruleEngine.(*RuleEngine).Shutdown(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), atomic.LoadInt64(&count))
+		assert.Equal(t, []string{"testShutdownPersist:s1"}, persisted)
+		Del("testShutdownPersist")
+	})
+
+	t.Run("WaitInFlight", func(t *testing.T) {
+		var ruleChainFile = `{
+          "ruleChain": {
+            "id": "testShutdownWait"
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "shutdownSleep"
+                }
+              }
+            ]
+          }
+        }`
+		ruleEngine, err := New("testShutdownWait", []byte(ruleChainFile), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		var completed int32
+		ruleEngine.OnMsg(newMsg(), types.WithOnAllNodeCompleted(func() {
+			atomic.StoreInt32(&completed, 1)
+		}))
+		time.Sleep(time.Millisecond * 50)
+		//通过可选接口优雅停止
+		shutdowner, ok := ruleEngine.(types.GracefulShutdowner)
+		assert.True(t, ok)
+		err = shutdowner.Shutdown(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&completed))
+		Del("testShutdownWait")
+
+		//超时
+		ruleEngine, err = New("testShutdownWait", []byte(ruleChainFile), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		ruleEngine.OnMsg(newMsg())
+		time.Sleep(time.Millisecond * 50)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		err = ruleEngine.(*RuleEngine).Shutdown(ctx)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.False(t, ruleEngine.Initialized())
+		Del("testShutdownWait")
+	})
+
+	t.Run("WaitOwnInFlight", func(t *testing.T) {
+		var started = make(chan struct{}, 1)
+		var release = make(chan struct{})
+		action.Functions.Register("shutdownBlock", func(ctx types.RuleContext, msg types.RuleMsg) {
+			started <- struct{}{}
+			<-release
+			ctx.TellSuccess(msg)
+		})
+		var ruleChainFile = `{
+          "ruleChain": {
+            "id": "%s"
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "functions",
+                "configuration": {
+                  "functionName": "shutdownBlock"
+                }
+              }
+            ]
+          }
+        }`
+		pool := NewPool()
+		defer pool.Stop()
+		busy, err := pool.New("", []byte(fmtChain(ruleChainFile, "testShutdownBusy")), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		idle, err := pool.New("", []byte(fmtChain(ruleChainFile, "testShutdownIdle")), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		var completed int32
+		busy.OnMsg(newMsg(), types.WithOnAllNodeCompleted(func() {
+			atomic.StoreInt32(&completed, 1)
+		}))
+		<-started
+
+		//不等待其他规则链正在处理的消息
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err = idle.(*RuleEngine).Shutdown(ctx)
+		assert.Nil(t, err)
+
+		var shutdownErr = make(chan error, 1)
+		go func() {
+			shutdownErr <- busy.(*RuleEngine).Shutdown(context.Background())
+		}()
+		//停止期间创建新的规则引擎，不影响等待
+		_, err = pool.New("", []byte(fmtChain(ruleChainFile, "testShutdownNew")), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		select {
+		case <-shutdownErr:
+			t.Fatal("shutdown returned before in-flight messages completed")
+		case <-time.After(time.Millisecond * 100):
+		}
+		close(release)
+		assert.Nil(t, <-shutdownErr)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&completed))
+	})
+
+	t.Run("Pool", func(t *testing.T) {
+		atomic.StoreInt64(&count, 0)
+		var parentChain = `{
+          "ruleChain": {
+            "id": "testShutdownParent"
+          },
+          "metadata": {
+            "nodes": [
+              {
+                "id": "s1",
+                "type": "flow",
+                "configuration": {
+                  "targetId": "testShutdownChild"
+                }
+              }
+            ]
+          }
+        }`
+		pool := NewPool()
+		child, err := pool.New("", []byte(fmtChain(shutdownDelayChain, "testShutdownChild")), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+		parent, err := pool.New("", []byte(parentChain), WithConfig(NewConfig()))
+		assert.Nil(t, err)
+
+		sorted := sortByDependency([]*RuleEngine{child.(*RuleEngine), parent.(*RuleEngine)})
+		assert.Equal(t, "testShutdownParent", sorted[0].Id())
+		assert.Equal(t, "testShutdownChild", sorted[1].Id())
+
+		parent.OnMsg(newMsg())
+		time.Sleep(time.Millisecond * 100)
+		assert.Equal(t, int64(0), atomic.LoadInt64(&count))
+		err = pool.Shutdown(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&count))
+		_, ok := pool.Get("testShutdownParent")
+		assert.False(t, ok)
+		_, ok = pool.Get("testShutdownChild")
+		assert.False(t, ok)
+	})
+}
+
+func fmtChain(format string, id string) string {
+	return strings.Replace(format, "%s", id, 1)
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/str"
+)
+
+// 匹配模板参数占位符 ${params.name}
+var templateParamRegex = regexp.MustCompile(`\$\{ *params\.([^} ]+) *\}`)
+
+// templateInstance 由模板实例化的规则链
+type templateInstance struct {
+	templateId string
+	params     map[string]interface{}
+}
+
+// templateReload 模板更新时需要重新加载的规则链
+type templateReload struct {
+	id         string
+	ruleEngine types.RuleEngine
+	//新模板实例化的定义
+	dsl []byte
+	//重新加载前的定义，用于失败时恢复
+	prevDsl []byte
+}
+
+// DecodeTemplate 解析并校验规则链模板
+func DecodeTemplate(def []byte) (types.RuleChainTemplate, error) {
+	var tpl types.RuleChainTemplate
+	if err := json.Unmarshal(def, &tpl); err != nil {
+		return tpl, err
+	}
+	return tpl, ValidateTemplate(tpl)
+}
+
+// ValidateTemplate 校验规则链模板：模板ID不能为空，参数名不能为空且不能重复，参数类型和默认值必须合法
+func ValidateTemplate(tpl types.RuleChainTemplate) error {
+	if tpl.Template.ID == "" {
+		return errors.New("template id can not empty")
+	}
+	if tpl.Definition == nil {
+		return errors.New("template definition can not empty")
+	}
+	var names = make(map[string]struct{})
+	for _, param := range tpl.Params {
+		if param.Name == "" {
+			return errors.New("template param name can not empty")
+		}
+		if _, ok := names[param.Name]; ok {
+			return fmt.Errorf("duplicate template param: %s", param.Name)
+		}
+		names[param.Name] = struct{}{}
+		switch param.Type {
+		case "", types.TemplateParamTypeString, types.TemplateParamTypeNumber, types.TemplateParamTypeBool, types.TemplateParamTypeObject:
+		default:
+			return fmt.Errorf("template param %s has unknown type: %s", param.Name, param.Type)
+		}
+		if param.Default != nil {
+			if _, err := convertTemplateParam(param, param.Default); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// InstantiateTemplate 使用参数实例化规则链模板
+// 未提供的参数使用默认值，必填参数缺失、参数类型错误或者参数未在模板中声明，返回错误。
+// id 规则链ID，如果为空则使用模板定义中的 ruleChain.id
+func InstantiateTemplate(tpl types.RuleChainTemplate, id string, params map[string]interface{}) (types.RuleChain, error) {
+	var def types.RuleChain
+	values, err := resolveTemplateParams(tpl, params)
+	if err != nil {
+		return def, err
+	}
+	definition := replaceTemplateParams(tpl.Definition, values)
+	b, err := json.Marshal(definition)
+	if err != nil {
+		return def, err
+	}
+	if err = json.Unmarshal(b, &def); err != nil {
+		return def, err
+	}
+	if id != "" {
+		def.RuleChain.ID = id
+	}
+	if def.RuleChain.ID == "" {
+		return def, errors.New("rule chain id can not empty")
+	}
+	return def, nil
+}
+
+// resolveTemplateParams 校验参数，并返回包含默认值的参数值
+func resolveTemplateParams(tpl types.RuleChainTemplate, params map[string]interface{}) (map[string]interface{}, error) {
+	var values = make(map[string]interface{})
+	var declared = make(map[string]struct{})
+	for _, param := range tpl.Params {
+		declared[param.Name] = struct{}{}
+		v, ok := params[param.Name]
+		if !ok || v == nil {
+			if param.Required {
+				return nil, fmt.Errorf("template param %s is required", param.Name)
+			}
+			v = param.Default
+		}
+		if v == nil {
+			continue
+		}
+		converted, err := convertTemplateParam(param, v)
+		if err != nil {
+			return nil, err
+		}
+		values[param.Name] = converted
+	}
+	for name := range params {
+		if _, ok := declared[name]; !ok {
+			return nil, fmt.Errorf("template param %s is not declared", name)
+		}
+	}
+	return values, nil
+}
+
+// convertTemplateParam 把参数值转换成声明的类型
+func convertTemplateParam(param types.TemplateParam, v interface{}) (interface{}, error) {
+	switch param.Type {
+	case "", types.TemplateParamTypeObject:
+		return v, nil
+	case types.TemplateParamTypeString:
+		return str.ToStringMaybeErr(v)
+	case types.TemplateParamTypeNumber:
+		switch value := v.(type) {
+		case float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return value, nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("template param %s must be a number", param.Name)
+	case types.TemplateParamTypeBool:
+		switch value := v.(type) {
+		case bool:
+			return value, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("template param %s must be a bool", param.Name)
+	default:
+		return nil, fmt.Errorf("template param %s has unknown type: %s", param.Name, param.Type)
+	}
+}
+
+// replaceTemplateParams 递归替换定义中的参数占位符，返回新的定义
+func replaceTemplateParams(v interface{}, values map[string]interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		var result = make(map[string]interface{}, len(value))
+		for k, item := range value {
+			result[k] = replaceTemplateParams(item, values)
+		}
+		return result
+	case []interface{}:
+		var result = make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = replaceTemplateParams(item, values)
+		}
+		return result
+	case string:
+		//整个值是占位符，替换成参数原始类型的值
+		if matches := templateParamRegex.FindStringSubmatch(value); matches != nil && matches[0] == strings.TrimSpace(value) {
+			if paramValue, ok := values[matches[1]]; ok {
+				return paramValue
+			}
+			return value
+		}
+		return templateParamRegex.ReplaceAllStringFunc(value, func(s string) string {
+			matches := templateParamRegex.FindStringSubmatch(s)
+			if paramValue, ok := values[matches[1]]; ok {
+				if _, isObject := paramValue.(map[string]interface{}); isObject {
+					b, _ := json.Marshal(paramValue)
+					return string(b)
+				}
+				return str.ToString(paramValue)
+			}
+			return s
+		})
+	default:
+		return v
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test/assert"
+)
+
+var testTemplate = `{
+  "template": {
+    "id": "testDeviceTemplate",
+    "name": "测试设备模板"
+  },
+  "params": [
+    {"name": "topic", "type": "string", "required": true},
+    {"name": "threshold", "type": "number", "default": 50},
+    {"name": "debug", "type": "bool", "default": false}
+  ],
+  "definition": {
+    "ruleChain": {
+      "id": "testDevice",
+      "name": "设备 ${params.topic}",
+      "debugMode": "${params.debug}"
+    },
+    "metadata": {
+      "nodes": [
+        {
+          "id": "s1",
+          "type": "jsFilter",
+          "configuration": {
+            "jsScript": "return msg.temperature > ${params.threshold};"
+          }
+        },
+        {
+          "id": "s2",
+          "type": "jsTransform",
+          "configuration": {
+            "jsScript": "metadata['topic']='${params.topic}';metadata['threshold']='${params.threshold}';return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+          }
+        }
+      ],
+      "connections": [
+        {"fromId": "s1", "toId": "s2", "type": "True"}
+      ]
+    }
+  }
+}`
+
+func TestTemplate(t *testing.T) {
+	t.Run("Instantiate", func(t *testing.T) {
+		tpl, err := DecodeTemplate([]byte(testTemplate))
+		assert.Nil(t, err)
+
+		def, err := InstantiateTemplate(tpl, "", map[string]interface{}{"topic": "device/a", "threshold": "30", "debug": "true"})
+		assert.Nil(t, err)
+		assert.Equal(t, "testDevice", def.RuleChain.ID)
+		assert.Equal(t, "设备 device/a", def.RuleChain.Name)
+		//整个值是占位符，替换成参数类型的值
+		assert.True(t, def.RuleChain.DebugMode)
+		assert.Equal(t, "return msg.temperature > 30;", def.Metadata.Nodes[0].Configuration["jsScript"])
+
+		//默认值
+		def, err = InstantiateTemplate(tpl, "testDevice2", map[string]interface{}{"topic": "device/b"})
+		assert.Nil(t, err)
+		assert.Equal(t, "testDevice2", def.RuleChain.ID)
+		assert.False(t, def.RuleChain.DebugMode)
+		assert.Equal(t, "return msg.temperature > 50;", def.Metadata.Nodes[0].Configuration["jsScript"])
+
+		_, err = InstantiateTemplate(tpl, "", nil)
+		assert.Equal(t, "template param topic is required", err.Error())
+		_, err = InstantiateTemplate(tpl, "", map[string]interface{}{"topic": "a", "threshold": "abc"})
+		assert.Equal(t, "template param threshold must be a number", err.Error())
+		_, err = InstantiateTemplate(tpl, "", map[string]interface{}{"topic": "a", "url": "http://127.0.0.1"})
+		assert.Equal(t, "template param url is not declared", err.Error())
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		_, err := DecodeTemplate([]byte(`{"template":{},"definition":{}}`))
+		assert.NotNil(t, err)
+		_, err = DecodeTemplate([]byte(`{"template":{"id":"a"},"params":[{"name":"a"},{"name":"a"}],"definition":{}}`))
+		assert.Equal(t, "duplicate template param: a", err.Error())
+		_, err = DecodeTemplate([]byte(`{"template":{"id":"a"},"params":[{"name":"a","type":"date"}],"definition":{}}`))
+		assert.Equal(t, "template param a has unknown type: date", err.Error())
+		_, err = DecodeTemplate([]byte(`{"template":{"id":"a"},"params":[{"name":"a","type":"bool","default":"yes"}],"definition":{}}`))
+		assert.Equal(t, "template param a must be a bool", err.Error())
+	})
+
+	t.Run("Pool", func(t *testing.T) {
+		pool := NewPool()
+		_, err := pool.NewFromTemplate("testDeviceA", "testDeviceTemplate", nil)
+		assert.Equal(t, "template not found: testDeviceTemplate", err.Error())
+
+		assert.Nil(t, pool.LoadTemplate([]byte(testTemplate)))
+		deviceA, err := pool.NewFromTemplate("testDeviceA", "testDeviceTemplate", map[string]interface{}{"topic": "device/a", "threshold": 30})
+		assert.Nil(t, err)
+		deviceB, err := pool.NewFromTemplate("testDeviceB", "testDeviceTemplate", map[string]interface{}{"topic": "device/b"})
+		assert.Nil(t, err)
+		_, err = pool.NewFromTemplate("testDeviceC", "testDeviceTemplate", map[string]interface{}{})
+		assert.NotNil(t, err)
+
+		onMsg := func(ruleEngine types.RuleEngine) (types.RuleMsg, string) {
+			var endMsg types.RuleMsg
+			var relation string
+			msg := types.NewMsg(0, "TEST_MSG_TYPE1", types.JSON, types.NewMetadata(), "{\"temperature\":41}")
+			ruleEngine.OnMsgAndWait(msg, types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+				endMsg = msg
+				relation = relationType
+			}))
+			return endMsg, relation
+		}
+		msg, relation := onMsg(deviceA)
+		assert.Equal(t, types.Success, relation)
+		assert.Equal(t, "device/a", msg.Metadata.GetValue("topic"))
+		_, relation = onMsg(deviceB)
+		assert.Equal(t, types.False, relation)
+
+		//更新模板，重新实例化所有依赖的规则链
+		assert.Nil(t, pool.LoadTemplate([]byte(strings.Replace(testTemplate, "msg.temperature >", "msg.temperature <", 1))))
+		_, relation = onMsg(deviceA)
+		assert.Equal(t, types.False, relation)
+		msg, relation = onMsg(deviceB)
+		assert.Equal(t, types.Success, relation)
+		assert.Equal(t, "device/b", msg.Metadata.GetValue("topic"))
+		assert.Equal(t, "50", msg.Metadata.GetValue("threshold"))
+
+		//新模板缺少参数，实例化失败的规则链保持原定义
+		err = pool.LoadTemplate([]byte(strings.Replace(testTemplate, `"params": [`, `"params": [{"name": "url", "required": true},`, 1)))
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "testDeviceA"))
+		//模板保持不变
+		tpl, _ := pool.GetTemplate("testDeviceTemplate")
+		assert.Equal(t, 3, len(tpl.Params))
+		_, relation = onMsg(deviceA)
+		assert.Equal(t, types.False, relation)
+
+		pool.Del("testDeviceA")
+		assert.Nil(t, pool.LoadTemplate([]byte(testTemplate)))
+		_, ok := pool.Get("testDeviceA")
+		assert.False(t, ok)
+		_, relation = onMsg(deviceB)
+		assert.Equal(t, types.False, relation)
+
+		pool.DelTemplate("testDeviceTemplate")
+		_, ok = pool.GetTemplate("testDeviceTemplate")
+		assert.False(t, ok)
+		pool.Stop()
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		pool := NewPool()
+		defer pool.Stop()
+		var rollbackTemplate = `{
+		  "template": {"id": "testRollbackTemplate"},
+		  "params": [{"name": "condition", "type": "string", "required": true}],
+		  "definition": {
+			"ruleChain": {"id": "testRollback", "name": "v1"},
+			"metadata": {
+			  "nodes": [{"id": "s1", "type": "jsFilter", "configuration": {"jsScript": "return (${params.condition});"}}]
+			}
+		  }
+		}`
+		assert.Nil(t, pool.LoadTemplate([]byte(rollbackTemplate)))
+		var ids []string
+		for i := 0; i < 5; i++ {
+			ruleEngine, err := pool.NewFromTemplate(fmt.Sprintf("testRollback%d", i), "testRollbackTemplate", map[string]interface{}{"condition": "true"})
+			assert.Nil(t, err)
+			ids = append(ids, ruleEngine.Id())
+		}
+		_, err := pool.NewFromTemplate("testRollbackBad", "testRollbackTemplate", map[string]interface{}{"condition": "true) || (false"})
+		assert.Nil(t, err)
+
+		//新模板实例化都成功，但是其中一个规则链加载失败，已经重新加载的规则链恢复原来的定义
+		newTemplate := strings.Replace(strings.Replace(rollbackTemplate, `"v1"`, `"v2"`, 1), "return (${params.condition});", "return ${params.condition};", 1)
+		err = pool.LoadTemplate([]byte(newTemplate))
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "testRollbackBad"))
+		for _, id := range append(ids, "testRollbackBad") {
+			ruleEngine, ok := pool.Get(id)
+			assert.True(t, ok)
+			assert.Equal(t, "v1", ruleEngine.Definition().RuleChain.Name)
+		}
+		tpl, _ := pool.GetTemplate("testRollbackTemplate")
+		assert.Equal(t, "v1", tpl.Definition["ruleChain"].(map[string]interface{})["name"])
+
+		//删除加载失败的规则链后更新成功
+		pool.Del("testRollbackBad")
+		assert.Nil(t, pool.LoadTemplate([]byte(newTemplate)))
+		for _, id := range ids {
+			ruleEngine, _ := pool.Get(id)
+			assert.Equal(t, "v2", ruleEngine.Definition().RuleChain.Name)
+		}
+	})
+}
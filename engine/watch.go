@@ -0,0 +1,246 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+)
+
+const (
+	// WatchOpCreate a rule chain file is added and a new rule engine instance is created.
+	WatchOpCreate = "create"
+	// WatchOpUpdate a rule chain file is changed and the rule engine instance is reloaded.
+	WatchOpUpdate = "update"
+	// WatchOpDelete a rule chain file is removed and the rule engine instance is deleted.
+	WatchOpDelete = "delete"
+
+	// DefaultWatchInterval is the default polling interval of the watched folder.
+	DefaultWatchInterval = time.Second
+	// DefaultWatchDebounce is the default time a file must stay unchanged before it is reloaded.
+	DefaultWatchDebounce = 500 * time.Millisecond
+)
+
+// WatchEvent is the outcome of reloading a rule chain file.
+type WatchEvent struct {
+	// Op is the operation: create, update or delete.
+	Op string
+	// Path is the path of the rule chain file.
+	Path string
+	// ChainId is the ID of the rule chain.
+	ChainId string
+	// Err is the error of the operation. If it is not nil, the previous version of the rule chain keeps running.
+	Err error
+}
+
+// WatchConfig is the configuration of watching a rule chain folder.
+type WatchConfig struct {
+	// Interval is the polling interval of the folder. Default: DefaultWatchInterval.
+	Interval time.Duration
+	// Debounce is the time a file must stay unchanged before it is reloaded, so that rapid edits are reloaded once.
+	// Default: DefaultWatchDebounce.
+	Debounce time.Duration
+	// Parser is used to validate the rule chain file before swapping. Default: JsonParser.
+	Parser types.Parser
+	// OnReload is called with the outcome of each create, update or delete. Optional.
+	OnReload func(event WatchEvent)
+}
+
+// watchedFile is the state of a watched rule chain file.
+type watchedFile struct {
+	modTime time.Time
+	size    int64
+	// changedAt is the time the file was last seen changed, zero if the change has been applied.
+	changedAt time.Time
+	// removed indicates the file is no longer found.
+	removed bool
+	// chainId is the ID of the rule chain loaded from the file.
+	chainId string
+}
+
+// Watcher polls a rule chain folder and keeps the rule engine instance pool in sync with the files.
+type Watcher struct {
+	pool     *Pool
+	pattern  string
+	config   WatchConfig
+	opts     []types.RuleEngineOption
+	files    map[string]*watchedFile
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// Watch loads all rule chain configurations from the specified folder and its subfolders into the rule engine instance pool,
+// then polls the folder: new files are loaded with New, changed files are reloaded with ReloadSelf,
+// and the rule chains of removed files are deleted with Del.
+// A changed file is validated before swapping, if it fails to parse the previous version of the rule chain keeps running.
+// The outcome of each reload is reported by config.OnReload. Call Watcher.Stop to stop watching.
+func (g *Pool) Watch(folderPath string, config WatchConfig, opts ...types.RuleEngineOption) (*Watcher, error) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultWatchInterval
+	}
+	if config.Debounce < 0 {
+		config.Debounce = 0
+	} else if config.Debounce == 0 {
+		config.Debounce = DefaultWatchDebounce
+	}
+	if config.Parser == nil {
+		config.Parser = &JsonParser{}
+	}
+	w := &Watcher{
+		pool:    g,
+		pattern: loadFilePattern(folderPath),
+		config:  config,
+		opts:    opts,
+		files:   make(map[string]*watchedFile),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	now := time.Now()
+	if err := w.scan(now); err != nil {
+		return nil, err
+	}
+	//首次加载不需要等待防抖时间
+	w.apply(now)
+	go w.run()
+	return w, nil
+}
+
+// Stop stops watching the folder. The loaded rule chains are kept in the pool.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+	})
+	<-w.doneCh
+}
+
+func (w *Watcher) run() {
+	defer close(w.doneCh)
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case now := <-ticker.C:
+			if err := w.scan(now); err == nil {
+				w.apply(now.Add(-w.config.Debounce))
+			}
+		}
+	}
+}
+
+// scan records the files added, changed or removed since the last scan
+func (w *Watcher) scan(now time.Time) error {
+	paths, err := ruleChainFilePaths(w.pattern)
+	if err != nil {
+		return err
+	}
+	var found = make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		found[path] = struct{}{}
+		file, ok := w.files[path]
+		if !ok {
+			w.files[path] = &watchedFile{modTime: info.ModTime(), size: info.Size(), changedAt: now}
+		} else if file.removed || !file.modTime.Equal(info.ModTime()) || file.size != info.Size() {
+			file.modTime = info.ModTime()
+			file.size = info.Size()
+			file.removed = false
+			file.changedAt = now
+		}
+	}
+	for path, file := range w.files {
+		if _, ok := found[path]; !ok && !file.removed {
+			file.removed = true
+			file.changedAt = now
+		}
+	}
+	return nil
+}
+
+// apply reloads the files that have not changed since the deadline
+func (w *Watcher) apply(deadline time.Time) {
+	for path, file := range w.files {
+		if file.changedAt.IsZero() || file.changedAt.After(deadline) {
+			continue
+		}
+		file.changedAt = time.Time{}
+		if file.removed {
+			delete(w.files, path)
+			if file.chainId != "" {
+				w.pool.Del(file.chainId)
+				w.report(WatchEvent{Op: WatchOpDelete, Path: path, ChainId: file.chainId})
+			}
+		} else {
+			w.load(path, file)
+		}
+	}
+}
+
+// load validates the rule chain file and creates or reloads the rule chain
+func (w *Watcher) load(path string, file *watchedFile) {
+	var event = WatchEvent{Op: WatchOpCreate, Path: path, ChainId: file.chainId}
+	if file.chainId != "" {
+		event.Op = WatchOpUpdate
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		event.Err = err
+		w.report(event)
+		return
+	}
+	def, err := w.config.Parser.DecodeRuleChain(b)
+	if err != nil {
+		event.Err = err
+		w.report(event)
+		return
+	}
+	if def.RuleChain.ID == "" {
+		event.Err = errors.New("ruleChain id is empty")
+		w.report(event)
+		return
+	}
+	event.ChainId = def.RuleChain.ID
+	if e, ok := w.pool.Get(def.RuleChain.ID); ok {
+		event.Op = WatchOpUpdate
+		event.Err = e.ReloadSelf(b)
+	} else {
+		_, event.Err = w.pool.New("", b, w.opts...)
+	}
+	if event.Err == nil {
+		//规则链ID改变，删除原来的规则链
+		if file.chainId != "" && file.chainId != def.RuleChain.ID {
+			w.pool.Del(file.chainId)
+		}
+		file.chainId = def.RuleChain.ID
+	}
+	w.report(event)
+}
+
+func (w *Watcher) report(event WatchEvent) {
+	if w.config.OnReload != nil {
+		w.config.OnReload(event)
+	}
+}
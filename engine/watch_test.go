@@ -0,0 +1,156 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego/test/assert"
+)
+
+var watchChain = `{
+  "ruleChain": {
+    "id": "testWatch01",
+    "name": "NAME"
+  },
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "log",
+        "configuration": {
+          "jsScript": "return 'log';"
+        }
+      }
+    ],
+    "connections": []
+  }
+}`
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	writeChain := func(path string, id string, name string) {
+		def := strings.Replace(strings.Replace(watchChain, "testWatch01", id, 1), "NAME", name, 1)
+		assert.Nil(t, os.WriteFile(path, []byte(def), 0644))
+	}
+	file01 := filepath.Join(dir, "chain01.json")
+	file02 := filepath.Join(dir, "sub", "chain02.json")
+	writeChain(file01, "testWatch01", "v1")
+
+	events := make(chan WatchEvent, 100)
+	pool := NewPool()
+	defer pool.Stop()
+	watcher, err := pool.Watch(dir, WatchConfig{
+		Interval: 10 * time.Millisecond,
+		Debounce: 50 * time.Millisecond,
+		OnReload: func(event WatchEvent) {
+			events <- event
+		},
+	})
+	assert.Nil(t, err)
+	defer watcher.Stop()
+
+	nextEvent := func() WatchEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second * 2):
+			t.Fatal("wait watch event timeout")
+			return WatchEvent{}
+		}
+	}
+	name := func(id string) string {
+		e, ok := pool.Get(id)
+		if !ok {
+			return ""
+		}
+		return e.Definition().RuleChain.Name
+	}
+
+	event := nextEvent()
+	assert.Equal(t, WatchOpCreate, event.Op)
+	assert.Equal(t, "testWatch01", event.ChainId)
+	assert.Equal(t, "v1", name("testWatch01"))
+
+	//子目录新增文件
+	writeChain(file02, "testWatch02", "v1")
+	event = nextEvent()
+	assert.Equal(t, WatchOpCreate, event.Op)
+	assert.Equal(t, file02, event.Path)
+	assert.Equal(t, "v1", name("testWatch02"))
+
+	//快速多次修改，只重新加载一次
+	writeChain(file01, "testWatch01", "v2")
+	writeChain(file01, "testWatch01", "v22")
+	writeChain(file01, "testWatch01", "v222")
+	event = nextEvent()
+	assert.Nil(t, event.Err)
+	assert.Equal(t, WatchOpUpdate, event.Op)
+	assert.Equal(t, "v222", name("testWatch01"))
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, 0, len(events))
+
+	//解析失败，保持原版本运行
+	assert.Nil(t, os.WriteFile(file01, []byte(`{"ruleChain":`), 0644))
+	event = nextEvent()
+	assert.NotNil(t, event.Err)
+	assert.Equal(t, WatchOpUpdate, event.Op)
+	assert.Equal(t, "v222", name("testWatch01"))
+
+	//忽略测试用例文件
+	writeChain(filepath.Join(dir, "chain01"+TestFileSuffix), "testWatchCase", "v1")
+	writeChain(file01, "testWatch01", "v3")
+	event = nextEvent()
+	assert.Nil(t, event.Err)
+	assert.Equal(t, "v3", name("testWatch01"))
+	_, ok := pool.Get("testWatchCase")
+	assert.False(t, ok)
+
+	//删除文件
+	assert.Nil(t, os.Remove(file02))
+	event = nextEvent()
+	assert.Equal(t, WatchOpDelete, event.Op)
+	assert.Equal(t, "testWatch02", event.ChainId)
+	_, ok = pool.Get("testWatch02")
+	assert.False(t, ok)
+
+	//停止监听后不再重新加载
+	watcher.Stop()
+	writeChain(file01, "testWatch01", "v4")
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, "v3", name("testWatch01"))
+}
+
+func TestLoadSkipTestFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "chain01.json"), []byte(watchChain), 0644))
+	testCase := strings.Replace(watchChain, "testWatch01", "testWatchCase", 1)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "chain01"+TestFileSuffix), []byte(testCase), 0644))
+
+	pool := NewPool()
+	defer pool.Stop()
+	assert.Nil(t, pool.Load(dir))
+	_, ok := pool.Get("testWatch01")
+	assert.True(t, ok)
+	_, ok = pool.Get("testWatchCase")
+	assert.False(t, ok)
+}
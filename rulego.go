@@ -109,6 +109,8 @@
 package rulego
 
 import (
+	"context"
+
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/builtin/aspect"
 	"github.com/rulego/rulego/endpoint"
@@ -159,12 +161,48 @@ func (g *RuleGo) Load(folderPath string, opts ...types.RuleEngineOption) error {
 	return g.pool.Load(folderPath, opts...)
 }
 
+// SetFlag sets a feature flag for all rule chains in the RuleGo rule chain pool.
+// Flags override the global properties when evaluating the disabledWhen/enabledWhen conditions of the nodes.
+func (g *RuleGo) SetFlag(key string, value string) {
+	g.pool.SetFlag(key, value)
+}
+
+// DelFlag deletes a feature flag from the RuleGo rule chain pool.
+func (g *RuleGo) DelFlag(key string) {
+	g.pool.DelFlag(key)
+}
+
+// SetMigrateOnLoad sets whether to migrate the deprecated components and configurations when loading rule chains.
+// See engine.Pool.SetMigrateOnLoad.
+func (g *RuleGo) SetMigrateOnLoad(enable bool, onMigrate func(report engine.MigrationReport)) {
+	g.pool.SetMigrateOnLoad(enable, onMigrate)
+}
+
+// Watch loads all rule chain configurations from the specified folder and its subFolders into the rule engine instance pool,
+// and reloads the rule chains when the files are added, changed or removed.
+func (g *RuleGo) Watch(folderPath string, config engine.WatchConfig, opts ...types.RuleEngineOption) (*engine.Watcher, error) {
+	if g.pool == nil {
+		g.pool = engine.NewPool()
+	}
+	return g.pool.Watch(folderPath, config, opts...)
+}
+
 // New creates a new RuleEngine and stores it in the RuleGo rule chain pool.
 // If the specified id is empty (""), the ruleChain.id from the rule chain file is used.
 func (g *RuleGo) New(id string, rootRuleChainSrc []byte, opts ...types.RuleEngineOption) (types.RuleEngine, error) {
 	return g.pool.New(id, rootRuleChainSrc, opts...)
 }
 
+// LoadTemplate loads or updates a rule chain template, and re-instantiates the rule chains created from it.
+func (g *RuleGo) LoadTemplate(def []byte) error {
+	return g.pool.LoadTemplate(def)
+}
+
+// NewFromTemplate instantiates the template with the parameters and stores the new RuleEngine in the RuleGo rule chain pool.
+func (g *RuleGo) NewFromTemplate(id string, templateId string, params map[string]interface{}, opts ...types.RuleEngineOption) (types.RuleEngine, error) {
+	return g.pool.NewFromTemplate(id, templateId, params, opts...)
+}
+
 // Get retrieves a rule engine instance by its ID.
 func (g *RuleGo) Get(id string) (types.RuleEngine, bool) {
 	return g.pool.Get(id)
@@ -180,6 +218,11 @@ func (g *RuleGo) Stop() {
 	g.pool.Stop()
 }
 
+// Shutdown gracefully stops and releases all rule engine instances.
+func (g *RuleGo) Shutdown(ctx context.Context) error {
+	return g.pool.Shutdown(ctx)
+}
+
 // Range iterates over all rule engine instances.
 func (g *RuleGo) Range(f func(key, value any) bool) {
 	g.pool.Range(f)
@@ -190,6 +233,11 @@ func (g *RuleGo) Reload(opts ...types.RuleEngineOption) {
 	g.pool.Reload(opts...)
 }
 
+// InFlight returns the total number of messages currently being processed by all rule engine instances.
+func (g *RuleGo) InFlight() int64 {
+	return g.pool.InFlight()
+}
+
 // OnMsg calls all rule engine instances to process a message.
 // All rule chains in the rule engine instance pool will attempt to process the message.
 func (g *RuleGo) OnMsg(msg types.RuleMsg) {
@@ -227,6 +275,11 @@ func Stop() {
 	Rules.Stop()
 }
 
+// Shutdown gracefully stops all rule engine instances.
+func Shutdown(ctx context.Context) error {
+	return Rules.Shutdown(ctx)
+}
+
 // OnMsg calls all rule engine instances to process a message.
 // All rule chains in the rule engine instance pool will attempt to process the message.
 func OnMsg(msg types.RuleMsg) {
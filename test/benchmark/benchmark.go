@@ -0,0 +1,270 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package benchmark measures the throughput and latency of a rule chain with the real worker pool and aspects.
+// Messages are generated from templates or a recorded corpus, and sent at a fixed rate or concurrency:
+//
+//	report, err := benchmark.Run(dsl, benchmark.Config{
+//		Templates:   []benchmark.MsgTemplate{{Type: "TELEMETRY", Data: "{\"temperature\":${rand}}"}},
+//		Concurrency: 8,
+//		Duration:    10 * time.Second,
+//		Mocks:       []chaintest.Mock{{NodeType: "restApiCall"}},
+//	})
+//	fmt.Println(report.Text())
+package benchmark
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/engine"
+	"github.com/rulego/rulego/test/chaintest"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/str"
+)
+
+// DefaultWaitTimeout is the default time to wait for the in-flight messages after sending.
+const DefaultWaitTimeout = 30 * time.Second
+
+// MsgTemplate is a message template. Data and metadata values support the variables:
+// ${seq} the sequence number of the message, ${ts} the current unix milliseconds, ${rand} a random integer in [0,100).
+type MsgTemplate struct {
+	Type     string            `json:"type"`
+	DataType string            `json:"dataType,omitempty"`
+	Data     string            `json:"data"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Msg creates a message from the template.
+func (t MsgTemplate) Msg(seq int64) types.RuleMsg {
+	env := map[string]interface{}{
+		"seq":  seq,
+		"ts":   time.Now().UnixMilli(),
+		"rand": rand.Intn(100),
+	}
+	metadata := types.NewMetadata()
+	for k, v := range t.Metadata {
+		metadata.PutValue(k, str.ExecuteTemplate(v, env))
+	}
+	dataType := types.JSON
+	if t.DataType != "" {
+		dataType = types.DataType(t.DataType)
+	}
+	return types.NewMsg(0, t.Type, dataType, metadata, str.ExecuteTemplate(t.Data, env))
+}
+
+// Config is the configuration of a benchmark.
+type Config struct {
+	// Templates generate the messages in round-robin order.
+	Templates []MsgTemplate
+	// Corpus is the recorded messages sent in round-robin order, used if Templates is empty. See LoadCorpus.
+	Corpus []types.RuleMsg
+	// Rate is the number of messages sent per second. If it is 0, messages are sent by Concurrency workers as fast as possible.
+	Rate int
+	// Concurrency is the number of workers, each worker sends a message after the previous one completes. Default: 1.
+	Concurrency int
+	// Duration is the sending duration. At least one of Duration and Total must be set.
+	Duration time.Duration
+	// Total is the maximum number of messages to send.
+	Total int64
+	// WaitTimeout is the time to wait for the in-flight messages after sending. Default: DefaultWaitTimeout.
+	WaitTimeout time.Duration
+	// Mocks replace the external nodes, see chaintest.Mock.
+	Mocks []chaintest.Mock
+}
+
+// Run creates the rule engine from the rule chain DSL with the options, sends the messages and reports the result.
+func Run(def []byte, config Config, opts ...types.RuleEngineOption) (*Report, error) {
+	if len(config.Templates) == 0 && len(config.Corpus) == 0 {
+		return nil, errors.New("templates and corpus can not both empty")
+	}
+	if config.Duration <= 0 && config.Total <= 0 {
+		return nil, errors.New("duration or total must be set")
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.WaitTimeout <= 0 {
+		config.WaitTimeout = DefaultWaitTimeout
+	}
+	collector := newCollector()
+	runner, err := chaintest.New(def, append(append([]types.RuleEngineOption{}, opts...), withLatencyAspect(collector))...)
+	if err != nil {
+		return nil, err
+	}
+	defer runner.Stop()
+	ruleEngine, err := runner.Mock(config.Mocks...).Engine()
+	if err != nil {
+		return nil, err
+	}
+	b := &bench{config: config, engine: ruleEngine, collector: collector}
+	return b.run(), nil
+}
+
+// LoadCorpus loads the recorded messages from a file, the format is a JSON array or JSON lines of types.RuleMsg.
+func LoadCorpus(path string) ([]types.RuleMsg, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []types.RuleMsg
+	content := strings.TrimSpace(string(b))
+	if strings.HasPrefix(content, "[") {
+		err = json.Unmarshal([]byte(content), &msgs)
+		return msgs, err
+	}
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var msg types.RuleMsg
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			return nil, errors.New("line " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// bench 一次压测
+type bench struct {
+	config    Config
+	engine    types.RuleEngine
+	collector *collector
+	seq       int64
+	wg        sync.WaitGroup
+}
+
+// next 生成下一条消息，达到总数则返回false
+func (b *bench) next() (types.RuleMsg, bool) {
+	seq := atomic.AddInt64(&b.seq, 1)
+	if b.config.Total > 0 && seq > b.config.Total {
+		return types.RuleMsg{}, false
+	}
+	if len(b.config.Templates) > 0 {
+		return b.config.Templates[(seq-1)%int64(len(b.config.Templates))].Msg(seq), true
+	}
+	msg := b.config.Corpus[(seq-1)%int64(len(b.config.Corpus))]
+	return types.NewMsg(0, msg.Type, msg.DataType, msg.Metadata.Copy(), msg.Data), true
+}
+
+// send 异步发送消息，done在所有节点执行完成后调用
+func (b *bench) send(msg types.RuleMsg, done func()) {
+	b.wg.Add(1)
+	start := time.Now()
+	var failed int32
+	b.engine.OnMsg(msg,
+		types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			if err != nil {
+				atomic.StoreInt32(&failed, 1)
+			}
+		}),
+		types.WithOnAllNodeCompleted(func() {
+			b.collector.addMsg(time.Since(start), atomic.LoadInt32(&failed) == 1)
+			b.wg.Done()
+			if done != nil {
+				done()
+			}
+		}))
+}
+
+func (b *bench) run() *Report {
+	var deadline time.Time
+	if b.config.Duration > 0 {
+		deadline = time.Now().Add(b.config.Duration)
+	}
+	expired := func() bool {
+		return !deadline.IsZero() && time.Now().After(deadline)
+	}
+	start := time.Now()
+	var sent int64
+	if b.config.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(b.config.Rate))
+		for !expired() {
+			msg, ok := b.next()
+			if !ok {
+				break
+			}
+			sent++
+			b.send(msg, nil)
+			<-ticker.C
+		}
+		ticker.Stop()
+	} else {
+		var workers sync.WaitGroup
+		for i := 0; i < b.config.Concurrency; i++ {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				for !expired() {
+					msg, ok := b.next()
+					if !ok {
+						return
+					}
+					atomic.AddInt64(&sent, 1)
+					done := make(chan struct{})
+					b.send(msg, func() {
+						close(done)
+					})
+					select {
+					case <-done:
+					case <-time.After(b.config.WaitTimeout):
+						return
+					}
+				}
+			}()
+		}
+		workers.Wait()
+	}
+	sendDuration := time.Since(start)
+	waitTimeout(&b.wg, b.config.WaitTimeout)
+	return b.collector.report(sent, sendDuration, time.Since(start))
+}
+
+// waitTimeout 等待所有消息处理完成或者超时
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// withLatencyAspect 增加统计节点耗时的切面
+func withLatencyAspect(c *collector) types.RuleEngineOption {
+	return func(re types.RuleEngine) error {
+		e, ok := re.(*engine.RuleEngine)
+		if !ok {
+			return errors.New("not support this rule engine")
+		}
+		e.Aspects = append(e.Aspects, &latencyAspect{collector: c})
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package benchmark
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/test/chaintest"
+)
+
+func TestBenchmark(t *testing.T) {
+	def, err := os.ReadFile("../chaintest/testdata/alarm_chain.json")
+	assert.Nil(t, err)
+
+	t.Run("Concurrency", func(t *testing.T) {
+		report, err := Run(def, Config{
+			Templates: []MsgTemplate{
+				{Type: "TELEMETRY", Data: "{\"temperature\":60,\"seq\":${seq}}", Metadata: map[string]string{"deviceId": "device${rand}"}},
+				{Type: "TELEMETRY", Data: "{\"temperature\":20}"},
+			},
+			Concurrency: 4,
+			Total:       100,
+			Mocks: []chaintest.Mock{{NodeType: "restApiCall", Func: func(msg types.RuleMsg) (types.RuleMsg, string, error) {
+				if strings.Contains(msg.Data, "\"seq\":1}") {
+					return msg, types.Failure, os.ErrDeadlineExceeded
+				}
+				return msg, types.Success, nil
+			}}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(100), report.Sent)
+		assert.Equal(t, int64(100), report.Completed)
+		assert.True(t, report.Throughput > 0)
+		assert.True(t, report.Latency.P50 > 0)
+		assert.True(t, report.Latency.P50 <= report.Latency.P99)
+
+		s1, ok := report.Node("s1")
+		assert.True(t, ok)
+		assert.Equal(t, int64(100), s1.Count)
+		assert.Equal(t, int64(50), s1.Relations[types.True])
+		assert.Equal(t, int64(50), s1.Relations[types.False])
+		s2, _ := report.Node("s2")
+		assert.Equal(t, int64(50), s2.Count)
+		assert.Equal(t, int64(1), s2.Errors[types.Failure])
+		assert.True(t, strings.Contains(report.Text(), "node s2 count: 50"))
+	})
+
+	t.Run("Rate", func(t *testing.T) {
+		report, err := Run(def, Config{
+			Corpus:   []types.RuleMsg{types.NewMsg(0, "TELEMETRY", types.JSON, types.NewMetadata(), "{\"temperature\":20}")},
+			Rate:     100,
+			Duration: 200 * time.Millisecond,
+		})
+		assert.Nil(t, err)
+		assert.True(t, report.Sent >= 10 && report.Sent <= 30)
+		assert.Equal(t, report.Sent, report.Completed)
+	})
+
+	t.Run("Corpus", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "corpus.jsonl")
+		assert.Nil(t, os.WriteFile(file, []byte("{\"type\":\"TELEMETRY\",\"dataType\":\"JSON\",\"data\":\"{\\\"temperature\\\":20}\"}\n\n{\"type\":\"TELEMETRY\",\"data\":\"{}\"}\n"), 0644))
+		msgs, err := LoadCorpus(file)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(msgs))
+		assert.Equal(t, "{\"temperature\":20}", msgs[0].Data)
+
+		assert.Nil(t, os.WriteFile(file, []byte("[{\"type\":\"A\"},{\"type\":\"B\"}]"), 0644))
+		msgs, err = LoadCorpus(file)
+		assert.Nil(t, err)
+		assert.Equal(t, "B", msgs[1].Type)
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		_, err := Run(def, Config{Total: 1})
+		assert.NotNil(t, err)
+		_, err = Run(def, Config{Templates: []MsgTemplate{{Type: "A"}}})
+		assert.NotNil(t, err)
+	})
+}
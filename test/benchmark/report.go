@@ -0,0 +1,244 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+)
+
+// LatencyStats is the latency distribution.
+type LatencyStats struct {
+	Min  time.Duration `json:"min"`
+	Max  time.Duration `json:"max"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+}
+
+// newLatencyStats 计算耗时分布
+func newLatencyStats(durations []time.Duration) LatencyStats {
+	if len(durations) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	percentile := func(p float64) time.Duration {
+		i := int(float64(len(sorted))*p+0.5) - 1
+		if i < 0 {
+			i = 0
+		} else if i >= len(sorted) {
+			i = len(sorted) - 1
+		}
+		return sorted[i]
+	}
+	return LatencyStats{
+		Min:  sorted[0],
+		Max:  sorted[len(sorted)-1],
+		Mean: sum / time.Duration(len(sorted)),
+		P50:  percentile(0.50),
+		P95:  percentile(0.95),
+		P99:  percentile(0.99),
+	}
+}
+
+// NodeStats is the statistics of a node.
+type NodeStats struct {
+	NodeId string `json:"nodeId"`
+	// Count is the number of executions.
+	Count int64 `json:"count"`
+	// Latency is the latency of the node, from the node receiving the message to the node sending it to the next nodes.
+	Latency LatencyStats `json:"latency"`
+	// Relations is the number of messages per output relation type.
+	Relations map[string]int64 `json:"relations"`
+	// Errors is the number of errors per output relation type.
+	Errors map[string]int64 `json:"errors,omitempty"`
+}
+
+// Report is the result of a benchmark.
+type Report struct {
+	// Sent is the number of messages sent.
+	Sent int64 `json:"sent"`
+	// Completed is the number of messages whose all nodes are completed.
+	Completed int64 `json:"completed"`
+	// Failed is the number of completed messages that end with an error on any branch.
+	Failed int64 `json:"failed"`
+	// Duration is the time from the first message sent to the last message completed.
+	Duration time.Duration `json:"duration"`
+	// Throughput is the number of completed messages per second.
+	Throughput float64 `json:"throughput"`
+	// Latency is the end-to-end latency of the messages.
+	Latency LatencyStats `json:"latency"`
+	// Nodes are the statistics of each node, sorted by node id.
+	Nodes []NodeStats `json:"nodes"`
+}
+
+// Node returns the statistics of the node.
+func (r *Report) Node(nodeId string) (NodeStats, bool) {
+	for _, item := range r.Nodes {
+		if item.NodeId == nodeId {
+			return item, true
+		}
+	}
+	return NodeStats{}, false
+}
+
+// Text returns the report in human-readable text format.
+func (r *Report) Text() string {
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintf(&buf, "sent: %d completed: %d failed: %d duration: %s throughput: %.1f msg/s\n",
+		r.Sent, r.Completed, r.Failed, r.Duration, r.Throughput)
+	_, _ = fmt.Fprintf(&buf, "latency: %s\n", r.Latency)
+	for _, node := range r.Nodes {
+		_, _ = fmt.Fprintf(&buf, "  node %s count: %d latency: %s relations: %v", node.NodeId, node.Count, node.Latency, node.Relations)
+		if len(node.Errors) > 0 {
+			_, _ = fmt.Fprintf(&buf, " errors: %v", node.Errors)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func (s LatencyStats) String() string {
+	return fmt.Sprintf("min=%s mean=%s p50=%s p95=%s p99=%s max=%s", s.Min, s.Mean, s.P50, s.P95, s.P99, s.Max)
+}
+
+// collector 收集消息和节点的耗时
+type collector struct {
+	lock      sync.Mutex
+	latencies []time.Duration
+	failed    int64
+	nodes     map[string]*nodeCollector
+	//starts 节点开始执行时间，key:节点执行上下文
+	starts sync.Map
+}
+
+type nodeCollector struct {
+	latencies []time.Duration
+	relations map[string]int64
+	errors    map[string]int64
+}
+
+func newCollector() *collector {
+	return &collector{nodes: make(map[string]*nodeCollector)}
+}
+
+func (c *collector) addMsg(latency time.Duration, failed bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.latencies = append(c.latencies, latency)
+	if failed {
+		c.failed++
+	}
+}
+
+func (c *collector) addNode(nodeId string, latency time.Duration, relationType string, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	node, ok := c.nodes[nodeId]
+	if !ok {
+		node = &nodeCollector{relations: make(map[string]int64), errors: make(map[string]int64)}
+		c.nodes[nodeId] = node
+	}
+	if latency >= 0 {
+		node.latencies = append(node.latencies, latency)
+	}
+	node.relations[relationType]++
+	if err != nil {
+		node.errors[relationType]++
+	}
+}
+
+func (c *collector) report(sent int64, sendDuration, duration time.Duration) *Report {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	report := &Report{
+		Sent:      sent,
+		Completed: int64(len(c.latencies)),
+		Failed:    c.failed,
+		Duration:  duration,
+		Latency:   newLatencyStats(c.latencies),
+	}
+	if duration > 0 {
+		report.Throughput = float64(report.Completed) / duration.Seconds()
+	}
+	for nodeId, node := range c.nodes {
+		stats := NodeStats{
+			NodeId:    nodeId,
+			Count:     int64(len(node.latencies)),
+			Latency:   newLatencyStats(node.latencies),
+			Relations: node.relations,
+		}
+		if len(node.errors) > 0 {
+			stats.Errors = node.errors
+		}
+		report.Nodes = append(report.Nodes, stats)
+	}
+	sort.Slice(report.Nodes, func(i, j int) bool {
+		return report.Nodes[i].NodeId < report.Nodes[j].NodeId
+	})
+	return report
+}
+
+// latencyAspect 统计节点耗时、输出关系和错误
+// 节点执行前记录开始时间，节点通知下一个节点时计算耗时，如果节点通过多个关系通知下一个节点，只统计第一次耗时
+type latencyAspect struct {
+	collector *collector
+}
+
+var (
+	_ types.BeforeAspect = (*latencyAspect)(nil)
+	_ types.AfterAspect  = (*latencyAspect)(nil)
+)
+
+func (a *latencyAspect) Order() int {
+	return 1000
+}
+
+func (a *latencyAspect) New() types.Aspect {
+	return &latencyAspect{collector: a.collector}
+}
+
+func (a *latencyAspect) PointCut(ctx types.RuleContext, msg types.RuleMsg, relationType string) bool {
+	return true
+}
+
+func (a *latencyAspect) Before(ctx types.RuleContext, msg types.RuleMsg, relationType string) types.RuleMsg {
+	a.collector.starts.Store(ctx, time.Now())
+	return msg
+}
+
+func (a *latencyAspect) After(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) types.RuleMsg {
+	var latency time.Duration = -1
+	if v, ok := a.collector.starts.LoadAndDelete(ctx); ok {
+		latency = time.Since(v.(time.Time))
+	}
+	a.collector.addNode(ctx.GetSelfId(), latency, relationType, err)
+	return msg
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chaintest
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/engine"
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestRunner(t *testing.T) {
+	def, err := os.ReadFile("testdata/alarm_chain.json")
+	assert.Nil(t, err)
+
+	newMsg := func(data string) types.RuleMsg {
+		return types.NewMsg(0, "TELEMETRY", types.JSON, types.NewMetadata(), data)
+	}
+
+	t.Run("Mock", func(t *testing.T) {
+		runner, err := New(def)
+		assert.Nil(t, err)
+		defer runner.Stop()
+		//按顺序返回脚本结果，最后一个结果重复返回
+		runner.Mock(Mock{NodeId: "s2", Results: []MockResult{{Err: "timeout"}, {Data: "{\"code\":0}"}}})
+
+		result, err := runner.Run(newMsg("{\"temperature\":60}"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"s1", "s2", "s4"}, result.Path)
+		end, ok := result.End("s4")
+		assert.True(t, ok)
+		assert.Equal(t, "false", end.Msg.Metadata.GetValue("notified"))
+
+		for i := 0; i < 2; i++ {
+			result, err = runner.Run(newMsg("{\"temperature\":60}"))
+			assert.Nil(t, err)
+			assert.Nil(t, result.Check(Expect{
+				Path:  []string{"s1", "s2", "s3"},
+				Calls: map[string]int{"s1": 1, "s2": 1, "s4": 0},
+				Ends:  map[string]ExpectEnd{"s3": {RelationType: types.Success, Data: "{\"code\":0}"}},
+			}))
+		}
+
+		err = result.Check(Expect{Path: []string{"s1", "s2", "s4"}})
+		assert.Equal(t, "path expected [s1 s2 s4], but got [s1 s2 s3]", err.Error())
+		err = result.Check(Expect{Calls: map[string]int{"s4": 1}})
+		assert.Equal(t, "node s4 calls expected 1, but got 0", err.Error())
+		err = result.Check(Expect{Ends: map[string]ExpectEnd{"s3": {Metadata: map[string]string{"notified": "false"}}}})
+		assert.Equal(t, "end node s3 metadata notified expected false, but got true", err.Error())
+		err = result.Check(Expect{Ends: map[string]ExpectEnd{"s4": {}}})
+		assert.Equal(t, "end node s4 not found", err.Error())
+
+		//替换节点组件不注册到全局注册器
+		_, ok = engine.Registry.GetComponents()[MockNodeType]
+		assert.False(t, ok)
+	})
+
+	t.Run("MockFunc", func(t *testing.T) {
+		runner, err := New(def)
+		assert.Nil(t, err)
+		defer runner.Stop()
+		runner.Mock(Mock{NodeType: "jsFilter", Func: func(msg types.RuleMsg) (types.RuleMsg, string, error) {
+			return msg, types.Failure, errors.New("script error")
+		}})
+		result, err := runner.Run(newMsg("{\"temperature\":60}"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"s1"}, result.Path)
+		assert.Nil(t, result.Check(Expect{Ends: map[string]ExpectEnd{"s1": {RelationType: types.Failure, Err: "script error"}}}))
+	})
+
+	t.Run("Coverage", func(t *testing.T) {
+		collector := engine.NewCoverageCollector()
+		runner, err := New(def)
+		assert.Nil(t, err)
+		defer runner.Stop()
+		runner.Mock(Mock{NodeId: "s2"}).Coverage(collector)
+		_, err = runner.Run(newMsg("{\"temperature\":60}"))
+		assert.Nil(t, err)
+		report, ok := collector.ChainReport("testAlarmChain")
+		assert.True(t, ok)
+		assert.Equal(t, []string{"s4"}, report.UnvisitedNodes)
+	})
+
+	t.Run("InvalidDef", func(t *testing.T) {
+		_, err := New([]byte("{"))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestRunFile(t *testing.T) {
+	RunDir(t, "testdata")
+
+	paths, err := TestFiles("testdata")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(paths))
+	assert.True(t, IsTestFile("testdata/alarm_notify.test.yaml"))
+	assert.True(t, IsTestFile("testdata/alarm_chain.test.json"))
+	assert.False(t, IsTestFile("testdata/alarm_chain.json"))
+
+	//YAML和JSON格式的用例文件字段名相同
+	testFile, _, err := LoadFile("testdata/alarm_notify.test.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "alarm_chain.json", testFile.Chain)
+	assert.Equal(t, "restApiCall", testFile.Mocks[0].NodeType)
+	assert.Equal(t, "TELEMETRY", testFile.Cases[0].Input.MsgType)
+	assert.Equal(t, "aa", testFile.Cases[0].Input.Metadata["deviceId"])
+
+	_, _, err = LoadFile("testdata/not_found.test.json")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chaintest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/engine"
+	"github.com/rulego/rulego/utils/fs"
+	"github.com/rulego/rulego/utils/json"
+	"gopkg.in/yaml.v3"
+)
+
+// Suffixes of the declarative test case files.
+const (
+	// TestFileSuffix is the suffix of the JSON test case files.
+	TestFileSuffix = engine.TestFileSuffix
+	// TestFileYamlSuffix is the suffix of the YAML test case files.
+	TestFileYamlSuffix = ".test.yaml"
+	// TestFileYmlSuffix is the short suffix of the YAML test case files.
+	TestFileYmlSuffix = ".test.yml"
+)
+
+// TestFileSuffixes are the suffixes of all supported test case files.
+var TestFileSuffixes = []string{TestFileSuffix, TestFileYamlSuffix, TestFileYmlSuffix}
+
+// TestFile is a declarative test case file stored next to the rule chain, in JSON or YAML format, for example:
+//
+//	{
+//	  "chain": "filter_chain.json",
+//	  "mocks": [{"nodeType": "restApiCall", "results": [{"data": "{\"ok\":true}"}]}],
+//	  "cases": [
+//	    {
+//	      "name": "high temperature",
+//	      "input": {"msgType": "TELEMETRY", "data": "{\"temperature\":60}", "metadata": {"deviceId": "aa"}},
+//	      "expect": {"path": ["s1", "s2"], "calls": {"s3": 0}, "ends": {"s2": {"relationType": "Success"}}}
+//	    }
+//	  ]
+//	}
+//
+// The same test cases in YAML:
+//
+//	chain: filter_chain.json
+//	mocks:
+//	  - nodeType: restApiCall
+//	    results:
+//	      - data: '{"ok":true}'
+//	cases:
+//	  - name: high temperature
+//	    input: {msgType: TELEMETRY, data: '{"temperature":60}', metadata: {deviceId: aa}}
+//	    expect: {path: [s1, s2], calls: {s3: 0}, ends: {s2: {relationType: Success}}}
+type TestFile struct {
+	// Chain is the path of the rule chain DSL, relative to the test case file.
+	Chain string `json:"chain"`
+	// Mocks are applied to all test cases.
+	Mocks []Mock `json:"mocks,omitempty"`
+	// Cases are the test cases.
+	Cases []TestCase `json:"cases"`
+}
+
+// TestCase is a declarative test case.
+type TestCase struct {
+	// Name is the name of the test case.
+	Name string `json:"name"`
+	// Mocks are applied to this test case, they take precedence over the mocks of the file.
+	Mocks []Mock `json:"mocks,omitempty"`
+	// Input is the input message.
+	Input Input `json:"input"`
+	// Expect is the expected execution record.
+	Expect Expect `json:"expect"`
+}
+
+// Input is the input message of a test case.
+type Input struct {
+	MsgType string `json:"msgType"`
+	// DataType is the data type of the message, default is JSON.
+	DataType string            `json:"dataType,omitempty"`
+	Data     string            `json:"data"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Msg creates the input message.
+func (in Input) Msg() types.RuleMsg {
+	dataType := types.JSON
+	if in.DataType != "" {
+		dataType = types.DataType(in.DataType)
+	}
+	return types.NewMsg(0, in.MsgType, dataType, types.BuildMetadata(in.Metadata), in.Data)
+}
+
+// RunDir runs all the declarative test case files(*.test.json, *.test.yaml, *.test.yml) in the folder and its subfolders.
+func RunDir(t *testing.T, folderPath string, opts ...types.RuleEngineOption) {
+	paths, err := TestFiles(folderPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		t.Run(trimTestFileSuffix(filepath.Base(path)), func(t *testing.T) {
+			RunFile(t, path, opts...)
+		})
+	}
+}
+
+// TestFiles returns the declarative test case files in the folder and its subfolders.
+func TestFiles(folderPath string) ([]string, error) {
+	var paths []string
+	for _, suffix := range TestFileSuffixes {
+		files, err := fs.GetFilePaths(filepath.Join(folderPath, "*"+suffix))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, files...)
+	}
+	return paths, nil
+}
+
+// IsTestFile reports whether the path is a declarative test case file.
+func IsTestFile(path string) bool {
+	return trimTestFileSuffix(path) != path
+}
+
+func trimTestFileSuffix(path string) string {
+	for _, suffix := range TestFileSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return strings.TrimSuffix(path, suffix)
+		}
+	}
+	return path
+}
+
+// RunFile runs the test cases of the declarative test case file, each test case is a subtest.
+func RunFile(t *testing.T, path string, opts ...types.RuleEngineOption) {
+	testFile, def, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, testCase := range testFile.Cases {
+		tc := testCase
+		t.Run(tc.Name, func(t *testing.T) {
+			if err := RunCase(def, testFile.Mocks, tc, opts...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// LoadFile loads the declarative test case file and the rule chain DSL it refers to.
+// Files with the .yaml or .yml extension are parsed as YAML, others as JSON.
+func LoadFile(path string) (TestFile, []byte, error) {
+	var testFile TestFile
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return testFile, nil, err
+	}
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		//YAML转换成JSON，与JSON格式使用相同的字段名
+		if b, err = yamlToJson(b); err != nil {
+			return testFile, nil, err
+		}
+	}
+	if err = json.Unmarshal(b, &testFile); err != nil {
+		return testFile, nil, err
+	}
+	chainPath := testFile.Chain
+	if !filepath.IsAbs(chainPath) {
+		chainPath = filepath.Join(filepath.Dir(path), chainPath)
+	}
+	def, err := os.ReadFile(chainPath)
+	return testFile, def, err
+}
+
+// RunCase runs a test case with the rule chain DSL, and returns an error if the execution record is not as expected.
+func RunCase(def []byte, mocks []Mock, testCase TestCase, opts ...types.RuleEngineOption) error {
+	runner, err := New(def, opts...)
+	if err != nil {
+		return err
+	}
+	defer runner.Stop()
+	runner.Mock(mocks...)
+	runner.Mock(testCase.Mocks...)
+	result, err := runner.Run(testCase.Input.Msg())
+	if err != nil {
+		return err
+	}
+	return result.Check(testCase.Expect)
+}
+
+// yamlToJson 把YAML转换成JSON
+func yamlToJson(b []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chaintest
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/engine"
+	"github.com/rulego/rulego/utils/maps"
+)
+
+// MockNodeType is the component type that replaces the mocked nodes.
+const MockNodeType = "chainTestMock"
+
+// mocks 已经替换的节点，key:runnerId:nodeId
+var mocks sync.Map
+
+// mockRegistry 在规则引擎的组件注册器基础上提供替换节点组件，不注册到全局注册器
+type mockRegistry struct {
+	types.ComponentRegistry
+}
+
+// NewNode 创建替换节点组件，其他类型交给原注册器创建
+func (r *mockRegistry) NewNode(nodeType string) (types.Node, error) {
+	if nodeType == MockNodeType {
+		return &mockNode{}, nil
+	}
+	return r.ComponentRegistry.NewNode(nodeType)
+}
+
+// withMockRegistry 使用提供替换节点组件的注册器
+func withMockRegistry() types.RuleEngineOption {
+	return func(re types.RuleEngine) error {
+		e, ok := re.(*engine.RuleEngine)
+		if !ok {
+			return errors.New("not support this rule engine")
+		}
+		if _, ok := e.Config.ComponentsRegistry.(*mockRegistry); ok {
+			return nil
+		}
+		registry := e.Config.ComponentsRegistry
+		if registry == nil {
+			registry = engine.Registry
+		}
+		e.Config.ComponentsRegistry = &mockRegistry{ComponentRegistry: registry}
+		return nil
+	}
+}
+
+// MockResult is a scripted output of a mocked node.
+type MockResult struct {
+	// MsgType replaces the message type if it is not empty.
+	MsgType string `json:"msgType,omitempty"`
+	// Data replaces the message data if it is not empty.
+	Data string `json:"data,omitempty"`
+	// Metadata is merged into the message metadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// RelationType is the relation type used to send the message to the next nodes.
+	// Default is Success, or Failure if Err is not empty.
+	RelationType string `json:"relationType,omitempty"`
+	// Err is the error returned by the node.
+	Err string `json:"err,omitempty"`
+}
+
+// Mock replaces the nodes with the specified id or type by a mock that returns scripted messages or errors.
+type Mock struct {
+	// NodeId is the id of the node to replace.
+	NodeId string `json:"nodeId,omitempty"`
+	// NodeType is the type of the nodes to replace, used if NodeId is empty.
+	NodeType string `json:"nodeType,omitempty"`
+	// Results are returned in order for each call, the last result is repeated.
+	// If it is empty, the message is passed through the Success relation.
+	Results []MockResult `json:"results,omitempty"`
+	// Func processes the message instead of Results if it is not nil.
+	Func func(msg types.RuleMsg) (types.RuleMsg, string, error) `json:"-"`
+}
+
+// match 是否替换该节点
+func (m *Mock) match(node *types.RuleNode) bool {
+	if m.NodeId != "" {
+		return m.NodeId == node.Id
+	}
+	return m.NodeType != "" && m.NodeType == node.Type
+}
+
+// mockState 替换节点的调用状态
+type mockState struct {
+	mock  Mock
+	calls int64
+}
+
+// output 返回第n次调用的输出
+func (s *mockState) output(msg types.RuleMsg) (types.RuleMsg, string, error) {
+	n := atomic.AddInt64(&s.calls, 1) - 1
+	if s.mock.Func != nil {
+		return s.mock.Func(msg)
+	}
+	if len(s.mock.Results) == 0 {
+		return msg, types.Success, nil
+	}
+	if n >= int64(len(s.mock.Results)) {
+		n = int64(len(s.mock.Results)) - 1
+	}
+	result := s.mock.Results[n]
+	if result.MsgType != "" {
+		msg.Type = result.MsgType
+	}
+	if result.Data != "" {
+		msg.Data = result.Data
+	}
+	for k, v := range result.Metadata {
+		msg.Metadata.PutValue(k, v)
+	}
+	var err error
+	relationType := result.RelationType
+	if result.Err != "" {
+		err = errors.New(result.Err)
+		if relationType == "" {
+			relationType = types.Failure
+		}
+	}
+	if relationType == "" {
+		relationType = types.Success
+	}
+	return msg, relationType, err
+}
+
+// mockNodeConfiguration 替换节点配置
+type mockNodeConfiguration struct {
+	//Key 替换节点的key
+	Key string
+}
+
+// mockNode 替换节点组件，按照脚本返回消息或者错误
+type mockNode struct {
+	Config mockNodeConfiguration
+}
+
+func (x *mockNode) Type() string {
+	return MockNodeType
+}
+
+func (x *mockNode) New() types.Node {
+	return &mockNode{}
+}
+
+func (x *mockNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return maps.Map2Struct(configuration, &x.Config)
+}
+
+func (x *mockNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	v, ok := mocks.Load(x.Config.Key)
+	if !ok {
+		ctx.TellFailure(msg, errors.New("mock not found: "+x.Config.Key))
+		return
+	}
+	out, relationType, err := v.(*mockState).output(msg)
+	if err != nil && relationType == types.Failure {
+		ctx.TellFailure(out, err)
+	} else {
+		ctx.TellNext(out, relationType)
+	}
+}
+
+func (x *mockNode) Destroy() {
+}
@@ -0,0 +1,317 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package chaintest tests whole rule chains: load a rule chain DSL, replace nodes by id or type with mocks,
+// feed input messages, and assert the path taken, the final messages per branch and the call counts per node.
+//
+//	runner, err := chaintest.New(dsl)
+//	runner.Mock(chaintest.Mock{NodeType: "restApiCall", Results: []chaintest.MockResult{{Data: "{\"ok\":true}"}}})
+//	result, err := runner.Run(msg)
+//	err = result.Check(chaintest.Expect{Path: []string{"s1", "s2"}, Calls: map[string]int{"s2": 1}})
+//
+// The test cases can also be declared in JSON files stored next to the rule chains, see RunFile.
+package chaintest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/engine"
+)
+
+// runnerSeq 用于生成runner ID
+var runnerSeq int64
+
+// runKey 用于在上下文中保存当前执行记录
+type runKey struct{}
+
+// End is the final message of a branch of the rule chain.
+type End struct {
+	// NodeId is the id of the last node of the branch.
+	NodeId string
+	// Msg is the final message.
+	Msg types.RuleMsg
+	// RelationType is the relation type of the last node.
+	RelationType string
+	// Err is the error of the last node.
+	Err error
+}
+
+// Result is the execution record of an input message.
+type Result struct {
+	// Path is the ids of the executed nodes in execution order.
+	// The order of the nodes on parallel branches is not deterministic.
+	Path []string
+	// Calls is the call count per node id.
+	Calls map[string]int
+	// Ends are the final messages of each branch.
+	Ends []End
+	lock sync.Mutex
+}
+
+// End returns the final message of the branch ending at the node.
+func (r *Result) End(nodeId string) (End, bool) {
+	for _, end := range r.Ends {
+		if end.NodeId == nodeId {
+			return end, true
+		}
+	}
+	return End{}, false
+}
+
+func (r *Result) addNode(nodeId string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Path = append(r.Path, nodeId)
+	r.Calls[nodeId]++
+}
+
+func (r *Result) addEnd(end End) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Ends = append(r.Ends, end)
+}
+
+// Runner loads a rule chain with mocked nodes and runs input messages.
+type Runner struct {
+	id    string
+	def   types.RuleChain
+	opts  []types.RuleEngineOption
+	mocks []Mock
+	//coverage 收集覆盖率
+	coverage *engine.CoverageCollector
+	pool     *engine.Pool
+	engine   types.RuleEngine
+	lock     sync.Mutex
+}
+
+// New creates a runner of the rule chain DSL. The options are used to create the rule engine.
+func New(def []byte, opts ...types.RuleEngineOption) (*Runner, error) {
+	parser := &engine.JsonParser{}
+	ruleChain, err := parser.DecodeRuleChain(def)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{
+		id:   strconv.FormatInt(atomic.AddInt64(&runnerSeq, 1), 10),
+		def:  ruleChain,
+		opts: opts,
+		pool: engine.NewPool(),
+	}, nil
+}
+
+// Mock replaces the nodes with the mock. It must be called before Run.
+func (r *Runner) Mock(mocks ...Mock) *Runner {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.mocks = append(r.mocks, mocks...)
+	return r
+}
+
+// Coverage collects the coverage of the runs into the collector, so that the unvisited nodes and edges can be reported.
+func (r *Runner) Coverage(collector *engine.CoverageCollector) *Runner {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.coverage = collector
+	return r
+}
+
+// Run processes the input message synchronously and returns the execution record.
+func (r *Runner) Run(msg types.RuleMsg) (*Result, error) {
+	ruleEngine, err := r.getEngine()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Calls: make(map[string]int)}
+	opts := []types.RuleContextOption{
+		types.WithContext(context.WithValue(context.Background(), runKey{}, result)),
+		types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			result.addEnd(End{NodeId: ctx.GetSelfId(), Msg: msg, RelationType: relationType, Err: err})
+		}),
+	}
+	if r.coverage != nil {
+		opts = append(opts, r.coverage.WithCoverage())
+	}
+	ruleEngine.OnMsgAndWait(msg, opts...)
+	return result, nil
+}
+
+// Engine returns the rule engine with the mocked nodes, it is created on first use.
+func (r *Runner) Engine() (types.RuleEngine, error) {
+	return r.getEngine()
+}
+
+// Stop releases the rule engine and the mocks.
+func (r *Runner) Stop() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pool.Stop()
+	r.engine = nil
+	for _, node := range r.def.Metadata.Nodes {
+		mocks.Delete(r.mockKey(node.Id))
+	}
+}
+
+// getEngine 替换节点并创建规则引擎
+func (r *Runner) getEngine() (types.RuleEngine, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.engine != nil {
+		return r.engine, nil
+	}
+	def := r.def
+	def.Metadata.Nodes = nil
+	for _, item := range r.def.Metadata.Nodes {
+		node := *item
+		for i := len(r.mocks) - 1; i >= 0; i-- {
+			if r.mocks[i].match(item) {
+				key := r.mockKey(node.Id)
+				mocks.Store(key, &mockState{mock: r.mocks[i]})
+				node.Type = MockNodeType
+				node.Configuration = types.Configuration{"key": key}
+				break
+			}
+		}
+		def.Metadata.Nodes = append(def.Metadata.Nodes, &node)
+	}
+	if def.RuleChain.ID == "" {
+		def.RuleChain.ID = "chainTest" + r.id
+	}
+	dsl, err := (&engine.JsonParser{}).EncodeRuleChain(def)
+	if err != nil {
+		return nil, err
+	}
+	opts := append(append([]types.RuleEngineOption{}, r.opts...), withMockRegistry(), withRecorder())
+	ruleEngine, err := r.pool.New(def.RuleChain.ID, dsl, opts...)
+	if err != nil {
+		return nil, err
+	}
+	r.engine = ruleEngine
+	return ruleEngine, nil
+}
+
+func (r *Runner) mockKey(nodeId string) string {
+	return r.id + ":" + nodeId
+}
+
+// withRecorder 增加记录执行路径的切面
+func withRecorder() types.RuleEngineOption {
+	return func(re types.RuleEngine) error {
+		e, ok := re.(*engine.RuleEngine)
+		if !ok {
+			return errors.New("not support this rule engine")
+		}
+		e.Aspects = append(e.Aspects, &recorderAspect{})
+		return nil
+	}
+}
+
+// recorderAspect 记录节点执行路径和调用次数
+type recorderAspect struct {
+}
+
+var _ types.BeforeAspect = (*recorderAspect)(nil)
+
+func (a *recorderAspect) Order() int {
+	return 1000
+}
+
+func (a *recorderAspect) New() types.Aspect {
+	return &recorderAspect{}
+}
+
+func (a *recorderAspect) PointCut(ctx types.RuleContext, msg types.RuleMsg, relationType string) bool {
+	return true
+}
+
+func (a *recorderAspect) Before(ctx types.RuleContext, msg types.RuleMsg, relationType string) types.RuleMsg {
+	if c := ctx.GetContext(); c != nil {
+		if result, ok := c.Value(runKey{}).(*Result); ok {
+			result.addNode(ctx.GetSelfId())
+		}
+	}
+	return msg
+}
+
+// Expect is the expected execution record of an input message.
+type Expect struct {
+	// Path is the expected ids of the executed nodes in execution order. Not checked if it is empty.
+	Path []string `json:"path,omitempty"`
+	// Calls is the expected call count per node id, 0 means the node is not executed.
+	Calls map[string]int `json:"calls,omitempty"`
+	// Ends is the expected final message per end node id.
+	Ends map[string]ExpectEnd `json:"ends,omitempty"`
+}
+
+// ExpectEnd is the expected final message of a branch. Empty fields are not checked.
+type ExpectEnd struct {
+	RelationType string `json:"relationType,omitempty"`
+	MsgType      string `json:"msgType,omitempty"`
+	Data         string `json:"data,omitempty"`
+	// Metadata is checked as a subset of the message metadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Err      string            `json:"err,omitempty"`
+}
+
+// Check compares the execution record with the expectation, and returns an error describing the first difference.
+func (r *Result) Check(expect Expect) error {
+	if len(expect.Path) > 0 {
+		if fmt.Sprint(expect.Path) != fmt.Sprint(r.Path) {
+			return fmt.Errorf("path expected %v, but got %v", expect.Path, r.Path)
+		}
+	}
+	for nodeId, calls := range expect.Calls {
+		if r.Calls[nodeId] != calls {
+			return fmt.Errorf("node %s calls expected %d, but got %d", nodeId, calls, r.Calls[nodeId])
+		}
+	}
+	for nodeId, expectEnd := range expect.Ends {
+		end, ok := r.End(nodeId)
+		if !ok {
+			return fmt.Errorf("end node %s not found", nodeId)
+		}
+		if err := expectEnd.check(end); err != nil {
+			return fmt.Errorf("end node %s %s", nodeId, err)
+		}
+	}
+	return nil
+}
+
+func (e ExpectEnd) check(end End) error {
+	if e.RelationType != "" && e.RelationType != end.RelationType {
+		return fmt.Errorf("relationType expected %s, but got %s", e.RelationType, end.RelationType)
+	}
+	if e.MsgType != "" && e.MsgType != end.Msg.Type {
+		return fmt.Errorf("msgType expected %s, but got %s", e.MsgType, end.Msg.Type)
+	}
+	if e.Data != "" && e.Data != end.Msg.Data {
+		return fmt.Errorf("data expected %s, but got %s", e.Data, end.Msg.Data)
+	}
+	for k, v := range e.Metadata {
+		if end.Msg.Metadata.GetValue(k) != v {
+			return fmt.Errorf("metadata %s expected %s, but got %s", k, v, end.Msg.Metadata.GetValue(k))
+		}
+	}
+	if e.Err != "" && (end.Err == nil || e.Err != end.Err.Error()) {
+		return fmt.Errorf("err expected %s, but got %v", e.Err, end.Err)
+	}
+	return nil
+}
@@ -24,6 +24,7 @@ import (
 	reflect2 "github.com/rulego/rulego/utils/reflect"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"testing"
@@ -157,30 +158,105 @@ func NodeOnMsgWithChildren(t *testing.T, node types.Node, msgList []Msg, childre
 func NodeOnMsgWithChildrenAndConfig(t *testing.T, config types.Config, node types.Node, msgList []Msg, childrenNodes map[string]types.Node, callback func(msg types.RuleMsg, relationType string, err error)) {
 	ctx := NewRuleContextFull(config, node, childrenNodes, callback)
 	for _, item := range msgList {
-		dataType := types.JSON
-		if item.DataType != "" {
-			dataType = item.DataType
-		}
-		if item.Id == "" {
-			uuId, _ := uuid.NewV4()
-			item.Id = uuId.String()
-		}
-		if item.Ts == 0 {
-			item.Ts = time.Now().UnixMilli()
-		}
-		msg := types.RuleMsg{
-			Id:       item.Id,
-			Ts:       item.Ts,
-			Type:     item.MsgType,
-			Data:     item.Data,
-			DataType: dataType,
-			Metadata: types.BuildMetadata(item.MetaData),
+		go node.OnMsg(ctx, newRuleMsg(item))
+		if item.AfterSleep > 0 {
+			time.Sleep(item.AfterSleep)
 		}
-		go node.OnMsg(ctx, msg)
+	}
+}
+
+// newRuleMsg 把测试消息转换成规则引擎消息
+func newRuleMsg(item Msg) types.RuleMsg {
+	dataType := types.JSON
+	if item.DataType != "" {
+		dataType = item.DataType
+	}
+	if item.Id == "" {
+		uuId, _ := uuid.NewV4()
+		item.Id = uuId.String()
+	}
+	if item.Ts == 0 {
+		item.Ts = time.Now().UnixMilli()
+	}
+	return types.RuleMsg{
+		Id:       item.Id,
+		Ts:       item.Ts,
+		Type:     item.MsgType,
+		Data:     item.Data,
+		DataType: dataType,
+		Metadata: types.BuildMetadata(item.MetaData),
+	}
+}
+
+// DefaultWaitTimeout NodeCollector 等待节点输出的默认超时时间
+var DefaultWaitTimeout = time.Second * 5
+
+// NodeResult 节点发送到下一个节点的消息
+type NodeResult struct {
+	Msg          types.RuleMsg
+	RelationType string
+	Err          error
+}
+
+// NodeCollector 按顺序向节点发送消息，并收集节点的输出
+// 节点异步输出（例如定时器触发）时，使用 Wait 等待输出，而不是固定时间的暂停
+type NodeCollector struct {
+	ctx     types.RuleContext
+	results []NodeResult
+	notify  chan struct{}
+	lock    sync.Mutex
+}
+
+// NewNodeCollector 创建节点输出收集器
+func NewNodeCollector(node types.Node) *NodeCollector {
+	c := &NodeCollector{notify: make(chan struct{}, 1)}
+	c.ctx = NewRuleContextFull(types.NewConfig(), node, nil, c.add)
+	return c
+}
+
+// OnMsg 按顺序同步调用节点的 OnMsg，AfterSleep 大于0时发送后暂停
+func (c *NodeCollector) OnMsg(node types.Node, msgList ...Msg) *NodeCollector {
+	for _, item := range msgList {
+		node.OnMsg(c.ctx, newRuleMsg(item))
 		if item.AfterSleep > 0 {
 			time.Sleep(item.AfterSleep)
 		}
 	}
+	return c
+}
+
+// Results 返回已经收集的输出
+func (c *NodeCollector) Results() []NodeResult {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]NodeResult(nil), c.results...)
+}
+
+// Wait 等待收集到至少count个输出，超时则测试失败
+func (c *NodeCollector) Wait(t *testing.T, count int) []NodeResult {
+	timer := time.NewTimer(DefaultWaitTimeout)
+	defer timer.Stop()
+	for {
+		if results := c.Results(); len(results) >= count {
+			return results
+		}
+		select {
+		case <-c.notify:
+		case <-timer.C:
+			t.Fatalf("wait node results timeout, expected %d, but got %d", count, len(c.Results()))
+			return nil
+		}
+	}
+}
+
+func (c *NodeCollector) add(msg types.RuleMsg, relationType string, err error) {
+	c.lock.Lock()
+	c.results = append(c.results, NodeResult{Msg: msg, RelationType: relationType, Err: err})
+	c.lock.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
 }
 
 // UpperNode A plugin that converts the message data to uppercase
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cache provides the in-memory implementation of types.Cache.
+//
+// MemoryCache is a LRU cache with per-key expiration. Expired keys are removed lazily
+// when they are accessed, or evicted when the cache is full, so no background goroutine is needed.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+)
+
+// DefaultMaxSize is the default maximum number of keys in the memory cache.
+const DefaultMaxSize = 100000
+
+var _ types.Cache = (*MemoryCache)(nil)
+
+// MemoryCache in-memory LRU cache with TTL
+// 进程内带过期时间的LRU缓存，超过最大容量时淘汰最久未使用的key
+type MemoryCache struct {
+	// MaxSize 最大容量，<=0表示不限制
+	MaxSize int
+	items   map[string]*list.Element
+	lru     *list.List
+	lock    sync.Mutex
+}
+
+type memoryItem struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func (item *memoryItem) expired(now time.Time) bool {
+	return !item.expiresAt.IsZero() && now.After(item.expiresAt)
+}
+
+// NewMemoryCache creates a memory cache with the given maximum number of keys
+func NewMemoryCache(maxSize int) *MemoryCache {
+	return &MemoryCache{
+		MaxSize: maxSize,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Set sets the value of the key. ttl<=0 means the key never expires.
+func (c *MemoryCache) Set(key string, value interface{}, ttl time.Duration) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.set(key, value, ttl)
+	return nil
+}
+
+// SetIfAbsent sets the value only if the key does not exist or has expired.
+func (c *MemoryCache) SetIfAbsent(key string, value interface{}, ttl time.Duration) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.get(key); ok {
+		return false, nil
+	}
+	c.set(key, value, ttl)
+	return true, nil
+}
+
+// Get returns the value of the key, and false if the key does not exist or has expired.
+func (c *MemoryCache) Get(key string) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.get(key)
+}
+
+// Delete deletes the key.
+func (c *MemoryCache) Delete(key string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	return nil
+}
+
+// Len returns the number of keys in the cache, including the expired keys that have not been removed yet.
+func (c *MemoryCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+func (c *MemoryCache) get(key string) (interface{}, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*memoryItem)
+	if item.expired(time.Now()) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return item.value, true
+}
+
+func (c *MemoryCache) set(key string, value interface{}, ttl time.Duration) {
+	if c.items == nil {
+		c.items = make(map[string]*list.Element)
+		c.lru = list.New()
+	}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	if elem, ok := c.items[key]; ok {
+		item := elem.Value.(*memoryItem)
+		item.value = value
+		item.expiresAt = expiresAt
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.lru.PushFront(&memoryItem{key: key, value: value, expiresAt: expiresAt})
+	if c.MaxSize > 0 {
+		for c.lru.Len() > c.MaxSize {
+			c.remove(c.lru.Back())
+		}
+	}
+}
+
+func (c *MemoryCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, elem.Value.(*memoryItem).key)
+}
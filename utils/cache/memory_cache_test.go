@@ -0,0 +1,65 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego/test/assert"
+)
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(2)
+	_ = c.Set("a", 1, 0)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	ok, _ = c.SetIfAbsent("a", 2, 0)
+	assert.False(t, ok)
+	ok, _ = c.SetIfAbsent("b", 2, 0)
+	assert.True(t, ok)
+
+	//淘汰最久未使用的b
+	_, _ = c.Get("a")
+	_ = c.Set("c", 3, 0)
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+
+	_ = c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+
+	//过期
+	_ = c.Set("d", 4, time.Millisecond*20)
+	_, ok = c.Get("d")
+	assert.True(t, ok)
+	time.Sleep(time.Millisecond * 30)
+	_, ok = c.Get("d")
+	assert.False(t, ok)
+	ok, _ = c.SetIfAbsent("d", 5, 0)
+	assert.True(t, ok)
+
+	var zero MemoryCache
+	_ = zero.Set("a", 1, 0)
+	_, ok = zero.Get("a")
+	assert.True(t, ok)
+}
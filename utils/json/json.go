@@ -55,6 +55,11 @@ func Unmarshal(b []byte, m interface{}) error {
 	return json.Unmarshal(b, m)
 }
 
+// Valid 检查是否是合法的json数据
+func Valid(data []byte) bool {
+	return json.Valid(data)
+}
+
 // Format json格式化
 func Format(jsonStr []byte) ([]byte, error) {
 	var buf bytes.Buffer
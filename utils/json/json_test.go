@@ -70,3 +70,9 @@ func TestFormat(t *testing.T) {
 
 	assert.Equal(t, buf.Bytes(), result)
 }
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid([]byte(`{"name":"test"}`)))
+	assert.True(t, Valid([]byte(`[1,2]`)))
+	assert.False(t, Valid([]byte(`abc`)))
+}
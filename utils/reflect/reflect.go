@@ -25,7 +25,7 @@
 // Key features:
 // - GetComponentForm: Generates a form structure for a given component
 // - GetComponentConfig: Extracts configuration information from a component
-// - GetFields: Retrieves field information from struct types
+// - GetFields: Retrieves field information and form tags from struct types
 // - SetField: Sets field values in structs using reflection
 //
 // The functions in this package are designed to work with the RuleGo
@@ -35,6 +35,7 @@ package reflect
 
 import (
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/rulego/rulego/api/types"
@@ -122,41 +123,111 @@ func GetComponentConfig(component types.Node) (reflect.Type, reflect.StructField
 }
 
 // GetFields 获取组件config字段
+// 通过以下tag补充字段的表单信息：
+//
+//	label:"显示名称" desc:"说明" validate:"required" required:"true" enum:"GET,POST" min:"0" max:"100" widget:"js" i18n:"restApiCall.method"
 func GetFields(configField reflect.StructField, configValue reflect.Value) []types.ComponentFormField {
+	if configField.Type == nil {
+		return nil
+	}
+	return getFields(configField.Type, configValue, map[reflect.Type]bool{})
+}
+
+// getFields 获取结构体的字段，configValue可以是无效值，这时字段没有默认值
+// visited 记录当前递归路径上的结构体类型，自引用的类型不再展开子字段
+func getFields(t reflect.Type, configValue reflect.Value, visited map[reflect.Type]bool) []types.ComponentFormField {
+	if visited[t] {
+		return nil
+	}
+	visited[t] = true
+	defer delete(visited, t)
 	var fields []types.ComponentFormField
-	if configField.Type != nil {
-		for i := 0; i < configField.Type.NumField(); i++ {
-			field := configField.Type.Field(i)
-			var defaultValue interface{}
-			if configValue.Field(i).CanInterface() {
-				defaultValue = configValue.Field(i).Interface()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		var defaultValue interface{}
+		var fieldValue reflect.Value
+		if configValue.IsValid() {
+			fieldValue = configValue.Field(i)
+			if fieldValue.CanInterface() {
+				defaultValue = fieldValue.Interface()
+			}
+		}
+		typeName := field.Type.Name()
+		var subFields []types.ComponentFormField
+		switch field.Type.Kind() {
+		case reflect.Map:
+			typeName = "map"
+		case reflect.Slice, reflect.Array:
+			typeName = "array"
+			//结构体数组，获取元素的字段
+			if elem := indirectType(field.Type.Elem()); elem.Kind() == reflect.Struct {
+				subFields = getFields(elem, reflect.Value{}, visited)
 			}
-			label := field.Tag.Get("label")
-			desc := field.Tag.Get("desc")
-			validate := field.Tag.Get("validate")
-			typeName := field.Type.Name()
-			var subFields []types.ComponentFormField
-			if field.Type.Kind() == reflect.Map {
-				typeName = "map"
-			} else if field.Type.Kind() == reflect.Slice || field.Type.Kind() == reflect.Array {
-				typeName = "array"
-			} else if field.Type.Kind() == reflect.Struct {
+		case reflect.Struct:
+			typeName = "struct"
+			//如果字段类型是结构体，那么递归获取子字段的信息
+			subFields = getFields(field.Type, fieldValue, visited)
+		case reflect.Ptr:
+			if elem := field.Type.Elem(); elem.Kind() == reflect.Struct {
 				typeName = "struct"
-				//如果字段类型是结构体，那么递归调用 GetFields 函数，传入字段的类型对象和值对象，获取子字段的信息
-				subFields = GetFields(field, configValue.Field(i))
+				var elemValue reflect.Value
+				if fieldValue.IsValid() && !fieldValue.IsNil() {
+					elemValue = fieldValue.Elem()
+				}
+				subFields = getFields(elem, elemValue, visited)
 			}
-
-			fields = append(fields,
-				types.ComponentFormField{
-					Name:         str.ToLowerFirst(field.Name),
-					Type:         typeName,
-					DefaultValue: defaultValue,
-					Label:        label,
-					Desc:         desc,
-					Validate:     validate,
-					Fields:       subFields,
-				})
 		}
+		validate := field.Tag.Get("validate")
+		formField := types.ComponentFormField{
+			Name:         str.ToLowerFirst(field.Name),
+			Type:         typeName,
+			DefaultValue: defaultValue,
+			Label:        field.Tag.Get("label"),
+			Desc:         field.Tag.Get("desc"),
+			Validate:     validate,
+			Required:     field.Tag.Get("required") == "true" || hasRule(validate, "required"),
+			Widget:       field.Tag.Get("widget"),
+			I18n:         field.Tag.Get("i18n"),
+			Fields:       subFields,
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			for _, item := range strings.Split(enum, ",") {
+				formField.Enum = append(formField.Enum, strings.TrimSpace(item))
+			}
+		}
+		formField.Min = parseFloatTag(field.Tag.Get("min"))
+		formField.Max = parseFloatTag(field.Tag.Get("max"))
+		fields = append(fields, formField)
 	}
 	return fields
 }
+
+// indirectType 如果是指针类型，返回指向的类型
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// hasRule validate是否包含指定规则，多个规则使用逗号分隔
+func hasRule(validate, rule string) bool {
+	for _, item := range strings.Split(validate, ",") {
+		if strings.TrimSpace(item) == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFloatTag 解析数值tag，空值或者格式错误返回nil
+func parseFloatTag(value string) *float64 {
+	if value == "" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
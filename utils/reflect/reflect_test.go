@@ -0,0 +1,189 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reflect
+
+import (
+	"testing"
+
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/json"
+)
+
+type testHeader struct {
+	Key   string `label:"Key" required:"true"`
+	Value string
+}
+
+type testServer struct {
+	Host string `validate:"required,hostname"`
+	Port int    `min:"1" max:"65535"`
+}
+
+type testNodeConfiguration struct {
+	Url     string            `label:"URL" desc:"server url" required:"true" i18n:"test.url"`
+	Method  string            `enum:"GET, POST"`
+	Retries int               `enum:"0,1,3"`
+	Script  string            `widget:"js" min:"1" max:"abc"`
+	Headers []testHeader      `label:"Headers"`
+	Server  *testServer       `label:"Server"`
+	Tags    map[string]string `max:"10"`
+	Options struct {
+		Debug bool
+	}
+}
+
+type testNode struct {
+	Config testNodeConfiguration
+}
+
+func (x *testNode) Type() string {
+	return "test/formNode"
+}
+
+func (x *testNode) New() types.Node {
+	return &testNode{Config: testNodeConfiguration{Method: "POST", Server: &testServer{Port: 8080}}}
+}
+
+func (x *testNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *testNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+}
+
+func (x *testNode) Destroy() {
+}
+
+func TestGetComponentForm(t *testing.T) {
+	form := GetComponentForm((&testNode{}).New())
+	assert.Equal(t, "test/formNode", form.Type)
+	assert.Equal(t, 8, len(form.Fields))
+
+	url, _ := form.Fields.GetField("url")
+	assert.Equal(t, "URL", url.Label)
+	assert.Equal(t, "server url", url.Desc)
+	assert.Equal(t, "test.url", url.I18n)
+	assert.True(t, url.Required)
+
+	method, _ := form.Fields.GetField("method")
+	assert.Equal(t, []string{"GET", "POST"}, method.Enum)
+	assert.Equal(t, "POST", method.DefaultValue)
+
+	script, _ := form.Fields.GetField("script")
+	assert.Equal(t, "js", script.Widget)
+	assert.Equal(t, float64(1), *script.Min)
+	assert.True(t, script.Max == nil)
+
+	headers, _ := form.Fields.GetField("headers")
+	assert.Equal(t, "array", headers.Type)
+	assert.Equal(t, 2, len(headers.Fields))
+	assert.True(t, headers.Fields[0].Required)
+
+	server, _ := form.Fields.GetField("server")
+	assert.Equal(t, "struct", server.Type)
+	host, _ := server.Fields.GetField("host")
+	assert.True(t, host.Required)
+	port, _ := server.Fields.GetField("port")
+	assert.Equal(t, 8080, port.DefaultValue)
+	assert.Equal(t, float64(65535), *port.Max)
+
+	options, _ := form.Fields.GetField("options")
+	assert.Equal(t, "struct", options.Type)
+	assert.Equal(t, 1, len(options.Fields))
+}
+
+type testTreeNode struct {
+	Name     string
+	Children []testTreeNode
+	Parent   *testTreeNode
+}
+
+type testRecursiveNode struct {
+	testNode
+	Config struct {
+		Root testTreeNode
+	}
+}
+
+func (x *testRecursiveNode) New() types.Node {
+	return &testRecursiveNode{}
+}
+
+func TestGetComponentFormRecursive(t *testing.T) {
+	form := GetComponentForm((&testRecursiveNode{}).New())
+	root, ok := form.Fields.GetField("root")
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(root.Fields))
+	//自引用的类型不再展开
+	children, _ := root.Fields.GetField("children")
+	assert.Equal(t, "array", children.Type)
+	assert.Equal(t, 0, len(children.Fields))
+	parent, _ := root.Fields.GetField("parent")
+	assert.Equal(t, "struct", parent.Type)
+	assert.Equal(t, 0, len(parent.Fields))
+}
+
+func TestJSONSchema(t *testing.T) {
+	form := GetComponentForm((&testNode{}).New())
+	schema := form.JSONSchema()
+	b, err := json.Marshal(schema)
+	assert.Nil(t, err)
+	var result map[string]interface{}
+	assert.Nil(t, json.Unmarshal(b, &result))
+
+	assert.Equal(t, types.JSONSchemaVersion, result["$schema"])
+	assert.Equal(t, "testNode", result["title"])
+	assert.Equal(t, "object", result["type"])
+	assert.Equal(t, []interface{}{"url"}, result["required"])
+
+	properties := result["properties"].(map[string]interface{})
+	url := properties["url"].(map[string]interface{})
+	assert.Equal(t, "string", url["type"])
+	assert.Equal(t, "URL", url["title"])
+	assert.Equal(t, "test.url", url["x-i18n"])
+
+	method := properties["method"].(map[string]interface{})
+	assert.Equal(t, "POST", method["default"])
+	assert.Equal(t, []interface{}{"GET", "POST"}, method["enum"])
+
+	retries := properties["retries"].(map[string]interface{})
+	assert.Equal(t, "integer", retries["type"])
+	assert.Equal(t, []interface{}{float64(0), float64(1), float64(3)}, retries["enum"])
+	_, ok := retries["default"]
+	assert.False(t, ok)
+
+	script := properties["script"].(map[string]interface{})
+	assert.Equal(t, float64(1), script["minLength"])
+	assert.Equal(t, "js", script["x-widget"])
+
+	headers := properties["headers"].(map[string]interface{})
+	assert.Equal(t, "array", headers["type"])
+	items := headers["items"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"key"}, items["required"])
+
+	server := properties["server"].(map[string]interface{})
+	assert.Equal(t, "object", server["type"])
+	assert.Equal(t, []interface{}{"host"}, server["required"])
+	port := server["properties"].(map[string]interface{})["port"].(map[string]interface{})
+	assert.Equal(t, float64(1), port["minimum"])
+	assert.Equal(t, float64(65535), port["maximum"])
+
+	tags := properties["tags"].(map[string]interface{})
+	assert.Equal(t, "object", tags["type"])
+	assert.Equal(t, float64(10), tags["maxProperties"])
+}